@@ -0,0 +1,121 @@
+package actionlog
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hermes-carpooling/database"
+	"strconv"
+	"time"
+)
+
+// Действия администраторов (admin_actions.action)
+const (
+	ActionUserSuspend         = "user.suspend"
+	ActionUserBan             = "user.ban"
+	ActionUserReinstate       = "user.reinstate"
+	ActionPartnerGrant        = "user.partner_grant"
+	ActionPartnerRevoke       = "user.partner_revoke"
+	ActionPartnerMemberAdd    = "user.partner_member_add"
+	ActionPartnerMemberRemove = "user.partner_member_remove"
+	ActionTripCancel          = "trip.cancel"
+	ActionBookingCancel       = "booking.cancel"
+	ActionReviewEdit          = "review.edit"
+	ActionReviewHide          = "review.hide"
+	ActionReviewRestore       = "review.restore"
+	ActionReviewDelete        = "review.delete"
+	ActionUserReportClose     = "user_report.close"
+)
+
+// Action — запись журнала действий администраторов
+type Action struct {
+	ID         int             `json:"id"`
+	AdminID    int             `json:"adminId"`
+	AdminName  string          `json:"adminName"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetID   int             `json:"targetId"`
+	Reason     string          `json:"reason"`
+	Details    json.RawMessage `json:"details"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// Log записывает действие администратора в той же транзакции, что и само изменение
+func Log(tx *sql.Tx, adminID int, action, targetType string, targetID int, reason string, details interface{}) error {
+	if details == nil {
+		details = struct{}{}
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO admin_actions (admin_id, action, target_type, target_id, reason, details)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, adminID, action, targetType, targetID, reason, data)
+	return err
+}
+
+// Filter — параметры выборки журнала; нулевые поля не фильтруют
+type Filter struct {
+	AdminID    int
+	Action     string
+	TargetType string
+	TargetID   int
+	Limit      int
+	Offset     int
+}
+
+// List возвращает страницу журнала, новые записи первыми, и общее число записей
+func List(f Filter) ([]Action, int, error) {
+	where := "TRUE"
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if f.AdminID != 0 {
+		where += " AND a.admin_id = " + arg(f.AdminID)
+	}
+	if f.Action != "" {
+		where += " AND a.action = " + arg(f.Action)
+	}
+	if f.TargetType != "" {
+		where += " AND a.target_type = " + arg(f.TargetType)
+	}
+	if f.TargetID != 0 {
+		where += " AND a.target_id = " + arg(f.TargetID)
+	}
+
+	var total int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM admin_actions a WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit, offset := arg(f.Limit), arg(f.Offset)
+	rows, err := database.DB.Query(`
+		SELECT a.id, COALESCE(a.admin_id, 0), COALESCE(u.full_name, ''), a.action, a.target_type, a.target_id,
+		       COALESCE(a.reason, ''), a.details, a.created_at
+		FROM admin_actions a
+		LEFT JOIN users u ON a.admin_id = u.id
+		WHERE `+where+`
+		ORDER BY a.id DESC
+		LIMIT `+limit+` OFFSET `+offset, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	actions := []Action{}
+	for rows.Next() {
+		var a Action
+		err := rows.Scan(&a.ID, &a.AdminID, &a.AdminName, &a.Action, &a.TargetType, &a.TargetID,
+			&a.Reason, &a.Details, &a.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		actions = append(actions, a)
+	}
+	return actions, total, rows.Err()
+}
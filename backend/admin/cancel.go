@@ -0,0 +1,197 @@
+package admin
+
+import (
+	"database/sql"
+	"hermes-carpooling/actionlog"
+	"hermes-carpooling/audit"
+	"hermes-carpooling/cancellation"
+	"hermes-carpooling/events"
+	"time"
+)
+
+// CancelTrip принудительно отменяет поездку: всем подтверждённым пассажирам полный возврат,
+// водителю штраф не начисляется. Возвращает сумму возвратов. Возврат, как и в CancelBooking, —
+// то, что пассажир заплатил картой и бонусами: скидка по промокоду не возвращается.
+func CancelTrip(tripID int, actor audit.Actor, reason string) (int, error) {
+	tx, err := audit.Begin(actor)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var driverID int
+	var status string
+	var departureAt time.Time
+	err = tx.QueryRow(`
+		SELECT driver_id, status, departure_at FROM trips WHERE id = $1 FOR UPDATE
+	`, tripID).Scan(&driverID, &status, &departureAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrTripNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if status != "active" {
+		return 0, ErrNotCancellable
+	}
+
+	passengerIDs, err := tripPassengers(tx, tripID)
+	if err != nil {
+		return 0, err
+	}
+
+	var refunded int
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(total_price - discount_amount), 0) FROM bookings WHERE trip_id = $1 AND status = 'confirmed'
+	`, tripID).Scan(&refunded)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`UPDATE trips SET status = 'cancelled' WHERE id = $1`, tripID); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO cancellations (booking_id, trip_id, cancelled_by, party, rule, hours_before_departure, fee, refund)
+		SELECT id, trip_id, $2, 'admin', $3, $4, 0, total_price - discount_amount
+		FROM bookings WHERE trip_id = $1 AND status = 'confirmed'
+	`, tripID, actor.UserID, cancellation.RuleAdminCancelled, time.Until(departureAt).Hours())
+	if err != nil {
+		return 0, err
+	}
+
+	// Блокировки оплаты и бонусы вернут обработчики TripCancelled
+	_, err = tx.Exec(`
+		UPDATE bookings SET status = 'cancelled', updated_at = NOW()
+		WHERE trip_id = $1 AND status IN ('pending', 'confirmed')
+	`, tripID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = events.Record(tx, events.TripCancelled, "trip", tripID, events.TripPayload{
+		TripID:       tripID,
+		DriverID:     driverID,
+		PassengerIDs: passengerIDs,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = actionlog.Log(tx, actor.UserID, actionlog.ActionTripCancel, "trip", tripID, reason, map[string]interface{}{
+		"driverId":     driverID,
+		"passengerIds": passengerIDs,
+		"refunded":     refunded,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	events.Wake()
+	return refunded, nil
+}
+
+// tripPassengers — пассажиры с действующими бронированиями на поездку
+func tripPassengers(tx *sql.Tx, tripID int) ([]int, error) {
+	rows, err := tx.Query(`
+		SELECT passenger_id FROM bookings
+		WHERE trip_id = $1 AND status IN ('pending', 'confirmed')
+	`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CancelBooking принудительно отменяет бронирование с полным возвратом пассажиру.
+// Возвращает сумму возврата.
+func CancelBooking(bookingID int, actor audit.Actor, reason string) (int, error) {
+	tx, err := audit.Begin(actor)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var p events.BookingPayload
+	var status, tripStatus string
+	var departureAt time.Time
+	err = tx.QueryRow(`
+		SELECT b.id, b.trip_id, t.driver_id, b.passenger_id, b.seats_booked, b.total_price - b.discount_amount,
+		       b.status, t.status, t.departure_at
+		FROM bookings b
+		JOIN trips t ON b.trip_id = t.id
+		WHERE b.id = $1
+		FOR UPDATE OF b
+	`, bookingID).Scan(&p.BookingID, &p.TripID, &p.DriverID, &p.PassengerID, &p.SeatsBooked, &p.TotalPrice,
+		&status, &tripStatus, &departureAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrBookingNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if (status != "pending" && status != "confirmed") || tripStatus == "completed" {
+		return 0, ErrNotCancellable
+	}
+
+	_, err = tx.Exec(`UPDATE bookings SET status = 'cancelled', updated_at = NOW() WHERE id = $1`, bookingID)
+	if err != nil {
+		return 0, err
+	}
+
+	// За неподтверждённую заявку деньги не блокировались и места не занимались
+	refund := 0
+	if status == "confirmed" {
+		refund = p.TotalPrice
+		_, err = tx.Exec(`
+			UPDATE trips SET available_seats = available_seats + $1 WHERE id = $2
+		`, p.SeatsBooked, p.TripID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO cancellations (booking_id, trip_id, cancelled_by, party, rule, hours_before_departure, fee, refund)
+		VALUES ($1, $2, $3, 'admin', $4, $5, 0, $6)
+	`, bookingID, p.TripID, actor.UserID, cancellation.RuleAdminCancelled, time.Until(departureAt).Hours(), refund)
+	if err != nil {
+		return 0, err
+	}
+
+	p.CancelledBy = actor.UserID
+	p.CancelledByAdmin = true
+	if err := events.Record(tx, events.BookingCancelled, "booking", bookingID, p); err != nil {
+		return 0, err
+	}
+
+	err = actionlog.Log(tx, actor.UserID, actionlog.ActionBookingCancel, "booking", bookingID, reason, map[string]interface{}{
+		"previousStatus": status,
+		"tripId":         p.TripID,
+		"passengerId":    p.PassengerID,
+		"refund":         refund,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	events.Wake()
+	return refund, nil
+}
@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"hermes-carpooling/actionlog"
+	"hermes-carpooling/audit"
+	"hermes-carpooling/database"
+)
+
+// Ошибки управления сотрудниками партнёра; текст ошибки отдаётся клиенту
+var (
+	ErrNotPartner = errors.New("user is not a partner")
+	ErrNotMember  = errors.New("user is not a member of this partner")
+)
+
+// PartnerMembers возвращает сотрудников партнёра, добавленных последними первыми
+func PartnerMembers(partnerID int) ([]UserSummary, error) {
+	if err := checkPartner(database.DB.QueryRow(`SELECT is_partner FROM users WHERE id = $1`, partnerID)); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT `+userColumns+`
+		FROM partner_members m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.partner_id = $1
+		ORDER BY m.created_at DESC, u.id DESC
+	`, partnerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserSummary{}
+	for rows.Next() {
+		var u UserSummary
+		if err := scanUser(rows, &u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// AddPartnerMember добавляет пользователя в сотрудники партнёра: события его поездок
+// и бронирований будут доставляться на вебхуки партнёра. Повторное добавление не ошибка.
+func AddPartnerMember(partnerID, userID int, actor audit.Actor, reason string) error {
+	tx, err := audit.Begin(actor)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkPartner(tx.QueryRow(`SELECT is_partner FROM users WHERE id = $1 FOR SHARE`, partnerID)); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO partner_members (partner_id, user_id)
+		SELECT $1, id FROM users WHERE id = $2
+		ON CONFLICT (partner_id, user_id) DO NOTHING
+	`, partnerID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUserNotFound
+		}
+		return nil
+	}
+
+	details := map[string]int{"userId": userID}
+	if err := actionlog.Log(tx, actor.UserID, actionlog.ActionPartnerMemberAdd, "user", partnerID, reason, details); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemovePartnerMember исключает пользователя из сотрудников партнёра
+func RemovePartnerMember(partnerID, userID int, actor audit.Actor, reason string) error {
+	tx, err := audit.Begin(actor)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM partner_members WHERE partner_id = $1 AND user_id = $2`, partnerID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotMember
+	}
+
+	details := map[string]int{"userId": userID}
+	if err := actionlog.Log(tx, actor.UserID, actionlog.ActionPartnerMemberRemove, "user", partnerID, reason, details); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkPartner проверяет, что пользователь существует и имеет доступ к партнёрскому API
+func checkPartner(row *sql.Row) error {
+	var isPartner bool
+	err := row.Scan(&isPartner)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !isPartner {
+		return ErrNotPartner
+	}
+	return nil
+}
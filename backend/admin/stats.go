@@ -0,0 +1,116 @@
+package admin
+
+import (
+	"hermes-carpooling/database"
+	"time"
+)
+
+// Stats — сводка по платформе за период [From, To)
+type Stats struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	Users struct {
+		Total     int `json:"total"`
+		New       int `json:"new"`
+		Drivers   int `json:"drivers"`
+		Suspended int `json:"suspended"`
+		Banned    int `json:"banned"`
+	} `json:"users"`
+
+	// Поездки и бронирования — по дате отправления
+	Trips struct {
+		Total     int `json:"total"`
+		Active    int `json:"active"`
+		Completed int `json:"completed"`
+		Cancelled int `json:"cancelled"`
+	} `json:"trips"`
+
+	Bookings struct {
+		Total     int `json:"total"`
+		Pending   int `json:"pending"`
+		Confirmed int `json:"confirmed"`
+		Cancelled int `json:"cancelled"`
+		Seats     int `json:"seats"` // подтверждённые места
+	} `json:"bookings"`
+
+	// Деньги — по выплатам водителям за период
+	Revenue struct {
+		Gross       int `json:"gross"`
+		PlatformFee int `json:"platformFee"`
+		Refunded    int `json:"refunded"`
+	} `json:"revenue"`
+
+	// Очереди модерации сейчас
+	Moderation struct {
+		OpenReviewReports int `json:"openReviewReports"`
+		OpenUserReports   int `json:"openUserReports"`
+	} `json:"moderation"`
+}
+
+// PlatformStats считает сводку по платформе
+func PlatformStats(from, to time.Time) (Stats, error) {
+	s := Stats{From: from, To: to}
+
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE u.created_at >= $1 AND u.created_at < $2),
+		       COUNT(*) FILTER (WHERE u.role = 'driver'),
+		       COUNT(*) FILTER (WHERE `+statusSQL+` = 'suspended'),
+		       COUNT(*) FILTER (WHERE u.account_status = 'banned')
+		FROM users u
+	`, from, to).Scan(&s.Users.Total, &s.Users.New, &s.Users.Drivers, &s.Users.Suspended, &s.Users.Banned)
+	if err != nil {
+		return s, err
+	}
+
+	err = database.DB.QueryRow(`
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE status = 'active'),
+		       COUNT(*) FILTER (WHERE status = 'completed'),
+		       COUNT(*) FILTER (WHERE status = 'cancelled')
+		FROM trips
+		WHERE departure_at >= $1 AND departure_at < $2
+	`, from, to).Scan(&s.Trips.Total, &s.Trips.Active, &s.Trips.Completed, &s.Trips.Cancelled)
+	if err != nil {
+		return s, err
+	}
+
+	err = database.DB.QueryRow(`
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE b.status = 'pending'),
+		       COUNT(*) FILTER (WHERE b.status = 'confirmed'),
+		       COUNT(*) FILTER (WHERE b.status = 'cancelled'),
+		       COALESCE(SUM(b.seats_booked) FILTER (WHERE b.status = 'confirmed'), 0)
+		FROM bookings b
+		JOIN trips t ON b.trip_id = t.id
+		WHERE t.departure_at >= $1 AND t.departure_at < $2
+	`, from, to).Scan(&s.Bookings.Total, &s.Bookings.Pending, &s.Bookings.Confirmed, &s.Bookings.Cancelled,
+		&s.Bookings.Seats)
+	if err != nil {
+		return s, err
+	}
+
+	err = database.DB.QueryRow(`
+		SELECT COALESCE(SUM(gross_amount), 0), COALESCE(SUM(fee), 0)
+		FROM payouts
+		WHERE created_at >= $1 AND created_at < $2
+	`, from, to).Scan(&s.Revenue.Gross, &s.Revenue.PlatformFee)
+	if err != nil {
+		return s, err
+	}
+
+	err = database.DB.QueryRow(`
+		SELECT COALESCE(SUM(refund), 0) FROM cancellations
+		WHERE created_at >= $1 AND created_at < $2
+	`, from, to).Scan(&s.Revenue.Refunded)
+	if err != nil {
+		return s, err
+	}
+
+	err = database.DB.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM review_reports WHERE status = 'open'),
+		       (SELECT COUNT(*) FROM user_reports WHERE status = 'open')
+	`).Scan(&s.Moderation.OpenReviewReports, &s.Moderation.OpenUserReports)
+	return s, err
+}
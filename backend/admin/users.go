@@ -0,0 +1,294 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"hermes-carpooling/actionlog"
+	"hermes-carpooling/audit"
+	"hermes-carpooling/database"
+	"strconv"
+	"time"
+)
+
+// Статусы аккаунта (users.account_status)
+const (
+	StatusActive    = "active"
+	StatusSuspended = "suspended" // временно, до suspended_until
+	StatusBanned    = "banned"
+)
+
+// Ошибки бэк-офиса; текст ошибки отдаётся клиенту
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrSelf            = errors.New("you cannot change your own account status")
+	ErrInvalidUntil    = errors.New("suspension end must be in the future")
+	ErrTripNotFound    = errors.New("trip not found")
+	ErrBookingNotFound = errors.New("booking not found")
+	ErrNotCancellable  = errors.New("only pending or active items can be cancelled")
+)
+
+// IsUserError — ошибка запроса администратора, а не базы
+func IsUserError(err error) bool {
+	return err == ErrUserNotFound || err == ErrSelf || err == ErrInvalidUntil ||
+		err == ErrTripNotFound || err == ErrBookingNotFound || err == ErrNotCancellable ||
+		err == ErrNotPartner || err == ErrNotMember
+}
+
+// statusSQL — действующий статус: истёкшая приостановка считается снятой
+const statusSQL = `CASE WHEN u.account_status = 'suspended' AND u.suspended_until <= NOW()
+	THEN 'active' ELSE u.account_status END`
+
+// Restriction — действующее ограничение аккаунта
+type Restriction struct {
+	Status string     `json:"status"`
+	Until  *time.Time `json:"until,omitempty"`
+	Reason string     `json:"reason"`
+}
+
+// Message — текст ошибки для заблокированного пользователя
+func (r Restriction) Message() string {
+	if r.Status == StatusSuspended && r.Until != nil {
+		return "Account suspended until " + r.Until.UTC().Format(time.RFC3339)
+	}
+	return "Account banned"
+}
+
+// AccountRestriction возвращает ограничение аккаунта или nil, если аккаунт активен
+func AccountRestriction(userID int) (*Restriction, error) {
+	var r Restriction
+	err := database.DB.QueryRow(`
+		SELECT `+statusSQL+`, u.suspended_until, COALESCE(u.status_reason, '')
+		FROM users u WHERE u.id = $1
+	`, userID).Scan(&r.Status, &r.Until, &r.Reason)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Status == StatusActive {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// UserSummary — пользователь в поиске бэк-офиса
+type UserSummary struct {
+	ID             int        `json:"id"`
+	FullName       string     `json:"fullName"`
+	Email          string     `json:"email"`
+	Phone          string     `json:"phone"`
+	Role           string     `json:"role"`
+	IsAdmin        bool       `json:"isAdmin"`
+	IsPartner      bool       `json:"isPartner"`
+	IsVerified     bool       `json:"isVerified"`
+	Status         string     `json:"status"`
+	SuspendedUntil *time.Time `json:"suspendedUntil"`
+	Rating         float64    `json:"rating"`
+	ReviewsCount   int        `json:"reviewsCount"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// UserFilter — параметры поиска пользователей
+type UserFilter struct {
+	Query  string // имя, email, телефон или ID
+	Role   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+const userColumns = `u.id, u.full_name, u.email, u.phone, u.role, u.is_admin, u.is_partner, COALESCE(u.is_verified, FALSE),
+	` + statusSQL + `, u.suspended_until, COALESCE(u.rating, 0), COALESCE(u.reviews_count, 0), u.created_at`
+
+// scanner — общий интерфейс *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner, u *UserSummary) error {
+	return row.Scan(&u.ID, &u.FullName, &u.Email, &u.Phone, &u.Role, &u.IsAdmin, &u.IsPartner, &u.IsVerified,
+		&u.Status, &u.SuspendedUntil, &u.Rating, &u.ReviewsCount, &u.CreatedAt)
+}
+
+// SearchUsers ищет пользователей, новые первыми, и возвращает общее число найденных
+func SearchUsers(f UserFilter) ([]UserSummary, int, error) {
+	where := "TRUE"
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if f.Query != "" {
+		pattern := arg("%" + f.Query + "%")
+		cond := "u.full_name ILIKE " + pattern + " OR u.email ILIKE " + pattern + " OR u.phone ILIKE " + pattern
+		if id, err := strconv.Atoi(f.Query); err == nil {
+			cond += " OR u.id = " + arg(id)
+		}
+		where += " AND (" + cond + ")"
+	}
+	if f.Role != "" {
+		where += " AND u.role = " + arg(f.Role)
+	}
+	if f.Status != "" {
+		where += " AND " + statusSQL + " = " + arg(f.Status)
+	}
+
+	var total int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM users u WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	limit, offset := arg(f.Limit), arg(f.Offset)
+	rows, err := database.DB.Query(`
+		SELECT `+userColumns+`
+		FROM users u
+		WHERE `+where+`
+		ORDER BY u.id DESC
+		LIMIT `+limit+` OFFSET `+offset, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []UserSummary{}
+	for rows.Next() {
+		var u UserSummary
+		if err := scanUser(rows, &u); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	return users, total, rows.Err()
+}
+
+// UserDetail — карточка пользователя в бэк-офисе
+type UserDetail struct {
+	UserSummary
+	StatusReason      string `json:"statusReason"`
+	TripsDriven       int    `json:"tripsDriven"`
+	TripsCancelled    int    `json:"tripsCancelled"`
+	Bookings          int    `json:"bookings"`
+	BookingsCancelled int    `json:"bookingsCancelled"`
+	OpenReports       int    `json:"openReports"` // открытые жалобы на пользователя
+	BlockedByOthers   int    `json:"blockedByOthers"`
+}
+
+// GetUser возвращает карточку пользователя со счётчиками активности
+func GetUser(userID int) (UserDetail, error) {
+	var d UserDetail
+	err := database.DB.QueryRow(`
+		SELECT `+userColumns+`, COALESCE(u.status_reason, ''),
+		       (SELECT COUNT(*) FROM trips WHERE driver_id = u.id AND status = 'completed'),
+		       (SELECT COUNT(*) FROM trips WHERE driver_id = u.id AND status = 'cancelled'),
+		       (SELECT COUNT(*) FROM bookings WHERE passenger_id = u.id),
+		       (SELECT COUNT(*) FROM bookings WHERE passenger_id = u.id AND status = 'cancelled'),
+		       (SELECT COUNT(*) FROM user_reports WHERE reported_id = u.id AND status = 'open'),
+		       (SELECT COUNT(*) FROM user_blocks WHERE blocked_id = u.id)
+		FROM users u WHERE u.id = $1
+	`, userID).Scan(&d.ID, &d.FullName, &d.Email, &d.Phone, &d.Role, &d.IsAdmin, &d.IsPartner, &d.IsVerified,
+		&d.Status, &d.SuspendedUntil, &d.Rating, &d.ReviewsCount, &d.CreatedAt,
+		&d.StatusReason, &d.TripsDriven, &d.TripsCancelled, &d.Bookings, &d.BookingsCancelled,
+		&d.OpenReports, &d.BlockedByOthers)
+	if err == sql.ErrNoRows {
+		return d, ErrUserNotFound
+	}
+	return d, err
+}
+
+// Suspend приостанавливает аккаунт до until
+func Suspend(userID int, actor audit.Actor, until time.Time, reason string) error {
+	if !until.After(time.Now()) {
+		return ErrInvalidUntil
+	}
+	return setStatus(userID, actor, StatusSuspended, &until, reason, actionlog.ActionUserSuspend)
+}
+
+// Ban блокирует аккаунт бессрочно
+func Ban(userID int, actor audit.Actor, reason string) error {
+	return setStatus(userID, actor, StatusBanned, nil, reason, actionlog.ActionUserBan)
+}
+
+// Reinstate снимает приостановку или блокировку
+func Reinstate(userID int, actor audit.Actor, reason string) error {
+	return setStatus(userID, actor, StatusActive, nil, reason, actionlog.ActionUserReinstate)
+}
+
+// SetPartner выдаёт или отзывает доступ к партнёрскому API (вебхукам).
+// При отзыве вебхуки пользователя отключаются.
+func SetPartner(userID int, actor audit.Actor, partner bool, reason string) error {
+	tx, err := audit.Begin(actor)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE users SET is_partner = $2, updated_at = NOW() WHERE id = $1`, userID, partner)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+
+	if !partner {
+		if _, err := tx.Exec(`UPDATE webhooks SET active = FALSE WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+	}
+
+	action := actionlog.ActionPartnerGrant
+	if !partner {
+		action = actionlog.ActionPartnerRevoke
+	}
+	if err := actionlog.Log(tx, actor.UserID, action, "user", userID, reason, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setStatus меняет статус аккаунта и записывает действие в журнал
+func setStatus(userID int, actor audit.Actor, status string, until *time.Time, reason, action string) error {
+	if userID == actor.UserID {
+		return ErrSelf
+	}
+
+	tx, err := audit.Begin(actor)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRow(`
+		SELECT `+statusSQL+` FROM users u WHERE u.id = $1 FOR UPDATE
+	`, userID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	statusReason := &reason
+	if status == StatusActive {
+		statusReason = nil
+	}
+	_, err = tx.Exec(`
+		UPDATE users SET account_status = $2, suspended_until = $3, status_reason = $4, updated_at = NOW()
+		WHERE id = $1
+	`, userID, status, until, statusReason)
+	if err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{"previousStatus": previous, "status": status}
+	if until != nil {
+		details["until"] = until
+	}
+	if err := actionlog.Log(tx, actor.UserID, action, "user", userID, reason, details); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
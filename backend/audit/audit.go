@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"database/sql"
+	"hermes-carpooling/database"
+	"strconv"
+)
+
+// Actor — кто меняет данные: пользователь (0 — система или фоновая задача)
+// и ID HTTP-запроса, в рамках которого выполняется изменение
+type Actor struct {
+	UserID    int
+	RequestID string
+}
+
+// System — изменения без пользователя: фоновые задачи и обработчики событий
+var System = Actor{}
+
+// Tag помечает транзакцию автором изменений. Записи журнала пишут триггеры базы
+// (audit_row_change), они читают автора из настроек транзакции.
+func Tag(tx *sql.Tx, actor Actor) error {
+	actorID := ""
+	if actor.UserID != 0 {
+		actorID = strconv.Itoa(actor.UserID)
+	}
+	_, err := tx.Exec(`
+		SELECT set_config('audit.actor_id', $1, true), set_config('audit.request_id', $2, true)
+	`, actorID, actor.RequestID)
+	return err
+}
+
+// Begin начинает транзакцию, помеченную автором изменений
+func Begin(actor Actor) (*sql.Tx, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if err := Tag(tx, actor); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Exec выполняет одиночное изменение в транзакции, помеченной автором
+func Exec(actor Actor, query string, args ...interface{}) (sql.Result, error) {
+	tx, err := Begin(actor)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return result, tx.Commit()
+}
@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"encoding/json"
+	"hermes-carpooling/database"
+	"strconv"
+	"time"
+)
+
+// Сущности, изменения которых попадают в журнал (audit_log.entity_type)
+var EntityTypes = []string{"trips", "bookings", "reviews", "users"}
+
+// ValidEntityType проверяет тип сущности
+func ValidEntityType(entityType string) bool {
+	for _, t := range EntityTypes {
+		if t == entityType {
+			return true
+		}
+	}
+	return false
+}
+
+// Кто выполнил изменение — для истории пользователя, без раскрытия ID сотрудников
+const (
+	ActorSelf   = "self"
+	ActorUser   = "user"
+	ActorAdmin  = "admin"
+	ActorSystem = "system"
+)
+
+// Entry — запись журнала изменений. OldValues и NewValues содержат только
+// изменившиеся поля; при создании записи OldValues пуст, при удалении — NewValues.
+type Entry struct {
+	ID         int64           `json:"id"`
+	EntityType string          `json:"entityType"`
+	EntityID   int             `json:"entityId"`
+	Action     string          `json:"action"` // insert, update, delete
+	ActorID    *int            `json:"actorId,omitempty"`
+	ActorName  string          `json:"actorName,omitempty"`
+	Actor      string          `json:"actor"`
+	RequestID  string          `json:"requestId,omitempty"`
+	OldValues  json.RawMessage `json:"oldValues"`
+	NewValues  json.RawMessage `json:"newValues"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// Filter — параметры выборки журнала; нулевые поля не фильтруют
+type Filter struct {
+	EntityType string
+	EntityID   int
+	ActorID    int
+	RequestID  string
+	UserID     int // изменения, касающиеся пользователя: его аккаунт, поездки, бронирования и отзывы
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+// where собирает условия выборки и аргументы
+func (f Filter) where() (string, []interface{}) {
+	where := "TRUE"
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if f.EntityType != "" {
+		where += " AND l.entity_type = " + arg(f.EntityType)
+	}
+	if f.EntityID != 0 {
+		where += " AND l.entity_id = " + arg(f.EntityID)
+	}
+	if f.ActorID != 0 {
+		where += " AND l.actor_id = " + arg(f.ActorID)
+	}
+	if f.RequestID != "" {
+		where += " AND l.request_id = " + arg(f.RequestID)
+	}
+	if f.UserID != 0 {
+		where += " AND " + arg(f.UserID) + " = ANY(l.user_ids)"
+	}
+	if !f.From.IsZero() {
+		where += " AND l.created_at >= " + arg(f.From)
+	}
+	if !f.To.IsZero() {
+		where += " AND l.created_at < " + arg(f.To)
+	}
+	return where, args
+}
+
+// List возвращает страницу журнала, новые записи первыми, и общее число записей
+func List(f Filter) ([]Entry, int, error) {
+	where, args := f.where()
+
+	var total int
+	err := database.DB.QueryRow(`SELECT COUNT(*) FROM audit_log l WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	rows, err := database.DB.Query(`
+		SELECT l.id, l.entity_type, l.entity_id, l.action, l.actor_id, COALESCE(u.full_name, ''),
+		       COALESCE(u.is_admin, FALSE), COALESCE(l.request_id, ''), l.old_values, l.new_values, l.created_at
+		FROM audit_log l
+		LEFT JOIN users u ON l.actor_id = u.id
+		WHERE `+where+`
+		ORDER BY l.id DESC
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var actorIsAdmin bool
+		err := rows.Scan(&e.ID, &e.EntityType, &e.EntityID, &e.Action, &e.ActorID, &e.ActorName,
+			&actorIsAdmin, &e.RequestID, &e.OldValues, &e.NewValues, &e.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch {
+		case e.ActorID == nil:
+			e.Actor = ActorSystem
+		case f.UserID != 0 && *e.ActorID == f.UserID:
+			e.Actor = ActorSelf
+		case actorIsAdmin:
+			e.Actor = ActorAdmin
+		default:
+			e.Actor = ActorUser
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// History — изменения, касающиеся пользователя. Кто именно из других пользователей
+// или сотрудников их выполнил, не раскрывается — только вид автора; ID запроса
+// остаётся у собственных изменений, чтобы на него можно было сослаться в поддержке.
+func History(userID int, f Filter) ([]Entry, int, error) {
+	f.UserID = userID
+	f.ActorID = 0
+	f.RequestID = ""
+
+	entries, total, err := List(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range entries {
+		entries[i].ActorID = nil
+		entries[i].ActorName = ""
+		if entries[i].Actor != ActorSelf {
+			entries[i].RequestID = ""
+		}
+		if fields, ok := moderatorFields[entries[i].EntityType]; ok {
+			entries[i].OldValues = withoutFields(entries[i].OldValues, fields)
+			entries[i].NewValues = withoutFields(entries[i].NewValues, fields)
+		}
+	}
+	return entries, total, nil
+}
+
+// moderatorFields — поля, которые заполняет модератор; в историю пользователя не попадают
+var moderatorFields = map[string][]string{
+	"reviews": {"hidden_by", "hidden_reason"},
+}
+
+// withoutFields убирает поля из значений записи журнала
+func withoutFields(values json.RawMessage, fields []string) json.RawMessage {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(values, &m); err != nil || m == nil {
+		return values
+	}
+	for _, f := range fields {
+		delete(m, f)
+	}
+	out, err := json.Marshal(m)
+	if err != nil {
+		return values
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+package cancellation
+
+import (
+	"math"
+	"time"
+)
+
+// Правила, по которым рассчитана отмена (cancellations.rule)
+const (
+	RulePending        = "pending"         // заявка ещё не подтверждена — денег не блокировали
+	RuleFreeWindow     = "free_window"     // отмена заранее — полный возврат
+	RuleLateFee        = "late_fee"        // поздняя отмена пассажиром — удерживается часть стоимости
+	RuleTripCancelled  = "trip_cancelled"  // поездку отменил водитель — пассажиру полный возврат
+	RuleDriverPenalty  = "driver_penalty"  // штраф водителю за позднюю отмену поездки
+	RuleAdminCancelled = "admin_cancelled" // отменено администратором — пассажиру полный возврат
+)
+
+// Policy — политика отмены бронирования пассажиром; водитель выбирает её для поездки
+type Policy struct {
+	Key            string  `json:"key"`
+	Label          string  `json:"label"`
+	FreeHours      float64 `json:"freeHours"`      // бесплатно, если до отправления не меньше стольких часов
+	LateFeePercent float64 `json:"lateFeePercent"` // доля стоимости, удерживаемая при более поздней отмене
+}
+
+// Policies — доступные политики отмены
+var Policies = []Policy{
+	{Key: "flexible", Label: "Гибкая", FreeHours: 3, LateFeePercent: 25},
+	{Key: "moderate", Label: "Умеренная", FreeHours: 24, LateFeePercent: 50},
+	{Key: "strict", Label: "Строгая", FreeHours: 72, LateFeePercent: 100},
+}
+
+// DefaultPolicy — политика для поездок, где водитель её не выбрал
+var DefaultPolicy = "moderate"
+
+// DriverRules — штраф водителю за отмену поездки с подтверждёнными пассажирами
+// менее чем за WindowHours до отправления
+type DriverRules struct {
+	WindowHours    float64
+	PenaltyPercent float64 // от стоимости подтверждённых бронирований
+	MinPenalty     int
+}
+
+// Driver — текущие правила для водителей, задаются в main из конфигурации
+var Driver = DriverRules{WindowHours: 24, PenaltyPercent: 20, MinPenalty: 100}
+
+// Charge — результат расчёта отмены
+type Charge struct {
+	Rule   string `json:"rule"`
+	Fee    int    `json:"fee"`    // удерживается с отменившей стороны
+	Refund int    `json:"refund"` // возвращается пассажиру
+}
+
+// Find ищет политику по ключу
+func Find(key string) (Policy, bool) {
+	for _, p := range Policies {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return Policy{}, false
+}
+
+// Get возвращает политику по ключу или политику по умолчанию
+func Get(key string) Policy {
+	if p, ok := Find(key); ok {
+		return p
+	}
+	p, _ := Find(DefaultPolicy)
+	return p
+}
+
+// PassengerCharge — сколько удержать и вернуть пассажиру, отменяющему подтверждённое бронирование
+func (p Policy) PassengerCharge(total int, untilDeparture time.Duration) Charge {
+	if untilDeparture.Hours() >= p.FreeHours {
+		return Charge{Rule: RuleFreeWindow, Refund: total}
+	}
+
+	fee := percentOf(total, p.LateFeePercent)
+	return Charge{Rule: RuleLateFee, Fee: fee, Refund: total - fee}
+}
+
+// DriverPenalty — штраф водителю, отменяющему поездку; confirmedTotal — стоимость
+// подтверждённых бронирований. Пассажирам в любом случае возвращается всё.
+func DriverPenalty(confirmedTotal int, untilDeparture time.Duration) Charge {
+	if confirmedTotal == 0 || untilDeparture.Hours() >= Driver.WindowHours {
+		return Charge{Rule: RuleTripCancelled, Refund: confirmedTotal}
+	}
+
+	fee := percentOf(confirmedTotal, Driver.PenaltyPercent)
+	if fee < Driver.MinPenalty {
+		fee = Driver.MinPenalty
+	}
+	return Charge{Rule: RuleDriverPenalty, Fee: fee, Refund: confirmedTotal}
+}
+
+// SplitFee делит удержание между частями стоимости, оплаченными картой и бонусами:
+// сначала удерживается из блокировки на карте, остаток — из списанных бонусов
+func SplitFee(fee, cardAmount, walletAmount int) (cardFee, walletFee int) {
+	if fee <= 0 {
+		return 0, 0
+	}
+	cardFee = fee
+	if cardFee > cardAmount {
+		cardFee = cardAmount
+	}
+	if cardFee < 0 {
+		cardFee = 0
+	}
+	walletFee = fee - cardFee
+	if walletFee > walletAmount {
+		walletFee = walletAmount
+	}
+	if walletFee < 0 {
+		walletFee = 0
+	}
+	return cardFee, walletFee
+}
+
+func percentOf(amount int, percent float64) int {
+	fee := int(math.Round(float64(amount) * percent / 100))
+	if fee > amount {
+		return amount
+	}
+	return fee
+}
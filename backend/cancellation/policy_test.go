@@ -0,0 +1,91 @@
+package cancellation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPassengerCharge(t *testing.T) {
+	moderate := Get("moderate") // 24 ч бесплатно, потом 50%
+	strict := Get("strict")     // 72 ч бесплатно, потом 100%
+
+	tests := []struct {
+		name           string
+		policy         Policy
+		total          int
+		untilDeparture time.Duration
+		want           Charge
+	}{
+		{"well before the window", moderate, 1000, 48 * time.Hour, Charge{Rule: RuleFreeWindow, Refund: 1000}},
+		{"exactly at the window", moderate, 1000, 24 * time.Hour, Charge{Rule: RuleFreeWindow, Refund: 1000}},
+		{"just inside the window", moderate, 1000, 24*time.Hour - time.Minute, Charge{Rule: RuleLateFee, Fee: 500, Refund: 500}},
+		{"after departure", moderate, 1000, -time.Hour, Charge{Rule: RuleLateFee, Fee: 500, Refund: 500}},
+		{"rounded fee", moderate, 999, time.Hour, Charge{Rule: RuleLateFee, Fee: 500, Refund: 499}},
+		{"strict keeps everything", strict, 1000, 71 * time.Hour, Charge{Rule: RuleLateFee, Fee: 1000, Refund: 0}},
+		{"strict before the window", strict, 1000, 72 * time.Hour, Charge{Rule: RuleFreeWindow, Refund: 1000}},
+		{"free booking", moderate, 0, time.Hour, Charge{Rule: RuleLateFee}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.PassengerCharge(tt.total, tt.untilDeparture); got != tt.want {
+				t.Errorf("PassengerCharge(%d, %v) = %+v, want %+v", tt.total, tt.untilDeparture, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDriverPenalty(t *testing.T) {
+	saved := Driver
+	defer func() { Driver = saved }()
+	Driver = DriverRules{WindowHours: 24, PenaltyPercent: 20, MinPenalty: 100}
+
+	tests := []struct {
+		name           string
+		confirmedTotal int
+		untilDeparture time.Duration
+		want           Charge
+	}{
+		{"no confirmed passengers", 0, time.Hour, Charge{Rule: RuleTripCancelled}},
+		{"before the window", 3000, 25 * time.Hour, Charge{Rule: RuleTripCancelled, Refund: 3000}},
+		{"exactly at the window", 3000, 24 * time.Hour, Charge{Rule: RuleTripCancelled, Refund: 3000}},
+		{"just inside the window", 3000, 24*time.Hour - time.Minute, Charge{Rule: RuleDriverPenalty, Fee: 600, Refund: 3000}},
+		{"minimum penalty", 300, time.Hour, Charge{Rule: RuleDriverPenalty, Fee: 100, Refund: 300}},
+		{"after departure", 1000, -time.Hour, Charge{Rule: RuleDriverPenalty, Fee: 200, Refund: 1000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DriverPenalty(tt.confirmedTotal, tt.untilDeparture); got != tt.want {
+				t.Errorf("DriverPenalty(%d, %v) = %+v, want %+v", tt.confirmedTotal, tt.untilDeparture, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitFee(t *testing.T) {
+	tests := []struct {
+		name          string
+		fee           int
+		card, wallet  int
+		wantCard      int
+		wantWalletFee int
+	}{
+		{"no fee", 0, 800, 200, 0, 0},
+		{"card covers the fee", 500, 800, 200, 500, 0},
+		{"fee exceeds the card", 500, 300, 700, 300, 200},
+		{"paid with bonuses only", 500, 0, 1000, 0, 500},
+		{"fee of the whole price", 1000, 600, 400, 600, 400},
+		{"nothing left to keep", 1000, 300, 200, 300, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, wallet := SplitFee(tt.fee, tt.card, tt.wallet)
+			if card != tt.wantCard || wallet != tt.wantWalletFee {
+				t.Errorf("SplitFee(%d, %d, %d) = %d, %d; want %d, %d",
+					tt.fee, tt.card, tt.wallet, card, wallet, tt.wantCard, tt.wantWalletFee)
+			}
+			if refund := tt.wallet - wallet; refund < 0 {
+				t.Errorf("wallet refund %d is negative", refund)
+			}
+		})
+	}
+}
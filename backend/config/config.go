@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 )
 
 type Config struct {
@@ -12,6 +13,47 @@ type Config struct {
 	DBName     string
 	JWTSecret  string
 	ServerPort string
+
+	// Часовой пояс для городов, которых нет в справочнике
+	DefaultTimezone string
+
+	// Адрес сервера OSRM; если пусто, маршрут оценивается офлайн
+	RoutingURL string
+
+	// Расчёт рекомендованной цены
+	FuelPricePerLiter  float64
+	FuelConsumption    float64
+	PriceCeilingFactor float64
+
+	// Потолок цены за место для маршрутов, которые не удалось оценить
+	PriceCeilingFallback int
+
+	// SMTP для email-уведомлений; если SMTP_HOST пуст, письма пишутся в лог
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Платежи: комиссия сервиса и удержание денег до выплаты водителю
+	PlatformFeePercent float64
+	PayoutHoldHours    float64
+
+	// Отмены: политика по умолчанию и штраф водителю за позднюю отмену поездки
+	DefaultCancellationPolicy string
+	DriverPenaltyHours        float64
+	DriverPenaltyPercent      float64
+
+	// Бонус на кошелёк обоим участникам реферальной программы
+	ReferralBonus int
+
+	// Сколько дней после завершения поездки можно оставить отзыв
+	ReviewWindowDays int
+
+	// Рейтинг: априорная оценка, её вес и период полураспада веса отзыва
+	RatingPriorMean    float64
+	RatingPriorWeight  float64
+	RatingHalfLifeDays float64
 }
 
 func Load() *Config {
@@ -23,6 +65,35 @@ func Load() *Config {
 		DBName:     getEnv("DB_NAME", "hermes_carpooling"),
 		JWTSecret:  getEnv("JWT_SECRET", "default-secret-key"),
 		ServerPort: getEnv("SERVER_PORT", ":8080"),
+
+		DefaultTimezone: getEnv("DEFAULT_TIMEZONE", "Europe/Moscow"),
+		RoutingURL:      getEnv("ROUTING_URL", ""),
+
+		FuelPricePerLiter:    getEnvFloat("FUEL_PRICE_PER_LITER", 60),
+		FuelConsumption:      getEnvFloat("FUEL_CONSUMPTION_L_100KM", 8),
+		PriceCeilingFactor:   getEnvFloat("PRICE_CEILING_FACTOR", 1.0),
+		PriceCeilingFallback: int(getEnvFloat("PRICE_CEILING_FALLBACK", 1500)),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUser:     getEnv("SMTP_USER", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "no-reply@hermes.local"),
+
+		PlatformFeePercent: getEnvFloat("PLATFORM_FEE_PERCENT", 10),
+		PayoutHoldHours:    getEnvFloat("PAYOUT_HOLD_HOURS", 24),
+
+		DefaultCancellationPolicy: getEnv("DEFAULT_CANCELLATION_POLICY", "moderate"),
+		DriverPenaltyHours:        getEnvFloat("DRIVER_PENALTY_HOURS", 24),
+		DriverPenaltyPercent:      getEnvFloat("DRIVER_PENALTY_PERCENT", 20),
+
+		ReferralBonus: int(getEnvFloat("REFERRAL_BONUS", 200)),
+
+		ReviewWindowDays: int(getEnvFloat("REVIEW_WINDOW_DAYS", 14)),
+
+		RatingPriorMean:    getEnvFloat("RATING_PRIOR_MEAN", 4.0),
+		RatingPriorWeight:  getEnvFloat("RATING_PRIOR_WEIGHT", 5),
+		RatingHalfLifeDays: getEnvFloat("RATING_HALF_LIFE_DAYS", 365),
 	}
 }
 
@@ -32,3 +103,10 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,217 @@
+package events
+
+import (
+	"fmt"
+	"hermes-carpooling/database"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Handler обрабатывает событие; ошибка приводит к повторной доставке
+type Handler func(Event) error
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+const (
+	// MaxAttempts — после стольких неудачных попыток событие больше не доставляется
+	MaxAttempts = 10
+	batchSize   = 50
+)
+
+var (
+	mu          sync.RWMutex
+	subscribers = map[string][]subscriber{}
+	wake        = make(chan struct{}, 1)
+)
+
+// Subscribe подписывает обработчик на тип события. Имя подписчика должно быть
+// уникальным и стабильным: по нему отмечается, кому событие уже доставлено.
+func Subscribe(eventType, name string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers[eventType] = append(subscribers[eventType], subscriber{name: name, handler: handler})
+}
+
+// Wake просит диспетчер не ждать следующего тика — вызывается после коммита
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start запускает фоновую доставку событий из outbox
+func Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			for dispatchBatch() == batchSize {
+			}
+			select {
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// claimTimeout — на сколько событие резервируется за экземпляром сервера.
+// Если экземпляр упал, не доставив событие, его подхватят после истечения резерва.
+const claimTimeout = 5 * time.Minute
+
+type pending struct {
+	event    Event
+	attempts int
+}
+
+// dispatchBatch доставляет очередную порцию событий и возвращает их количество.
+// События резервируются коротким запросом, а подписчики вызываются вне транзакции:
+// блокировки строк не держатся, пока идут уведомления, вебхуки и платежи.
+func dispatchBatch() int {
+	batch, err := claimBatch()
+	if err != nil {
+		log.Println("⚠️ Outbox: ошибка чтения событий:", err)
+		return 0
+	}
+
+	for _, p := range batch {
+		deliveryErr := deliver(p.event)
+		if err := finish(p, deliveryErr); err != nil {
+			log.Printf("⚠️ Outbox: не удалось сохранить результат доставки события %d: %v", p.event.ID, err)
+		}
+	}
+	return len(batch)
+}
+
+// claimBatch резервирует готовые к доставке события, сдвигая next_attempt_at.
+// SKIP LOCKED позволяет запускать несколько экземпляров сервера.
+func claimBatch() ([]pending, error) {
+	rows, err := database.DB.Query(`
+		UPDATE outbox_events SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE processed_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_type, aggregate_id, payload, created_at, attempts
+	`, batchSize, int(claimTimeout.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		err := rows.Scan(&p.event.ID, &p.event.Type, &p.event.AggregateType, &p.event.AggregateID,
+			&p.event.Payload, &p.event.CreatedAt, &p.attempts)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING не сохраняет порядок подзапроса
+	sort.Slice(batch, func(i, j int) bool { return batch[i].event.ID < batch[j].event.ID })
+	return batch, nil
+}
+
+// finish отмечает событие доставленным или планирует повтор с задержкой
+func finish(p pending, deliveryErr error) error {
+	if deliveryErr == nil {
+		_, err := database.DB.Exec(`
+			UPDATE outbox_events SET processed_at = NOW(), last_error = NULL WHERE id = $1
+		`, p.event.ID)
+		return err
+	}
+
+	attempts := p.attempts + 1
+	log.Printf("⚠️ Outbox: событие %d (%s), попытка %d: %v", p.event.ID, p.event.Type, attempts, deliveryErr)
+
+	if attempts >= MaxAttempts {
+		_, err := database.DB.Exec(`
+			UPDATE outbox_events SET attempts = $1, last_error = $2, failed_at = NOW() WHERE id = $3
+		`, attempts, deliveryErr.Error(), p.event.ID)
+		return err
+	}
+
+	_, err := database.DB.Exec(`
+		UPDATE outbox_events
+		SET attempts = $1, last_error = $2, next_attempt_at = NOW() + $3 * INTERVAL '1 second'
+		WHERE id = $4
+	`, attempts, deliveryErr.Error(), int(backoff(attempts).Seconds()), p.event.ID)
+	return err
+}
+
+// deliver вызывает подписчиков, которым событие ещё не доставлено. Доставка каждому
+// подписчику отмечается отдельным запросом сразу после успешного вызова. Если отметка
+// не сохранилась, подписчик будет вызван повторно, поэтому подписчики, двигающие
+// деньги, идемпотентны по ID события.
+func deliver(e Event) error {
+	mu.RLock()
+	subs := subscribers[e.Type]
+	mu.RUnlock()
+
+	var failed []string
+	for _, s := range subs {
+		var done bool
+		err := database.DB.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM outbox_deliveries WHERE event_id = $1 AND subscriber = $2)
+		`, e.ID, s.name).Scan(&done)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", s.name, err))
+			continue
+		}
+		if done {
+			continue
+		}
+
+		if err := safeCall(s.handler, e); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", s.name, err))
+			continue
+		}
+
+		_, err = database.DB.Exec(`
+			INSERT INTO outbox_deliveries (event_id, subscriber) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, e.ID, s.name)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: delivery not recorded: %v", s.name, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%v", failed)
+	}
+	return nil
+}
+
+// safeCall не даёт панике в подписчике остановить диспетчер
+func safeCall(h Handler, e Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(e)
+}
+
+// backoff — экспоненциальная задержка перед повтором: 2, 4, 8 ... секунд, не больше часа
+func backoff(attempts int) time.Duration {
+	d := time.Duration(1<<uint(attempts)) * time.Second
+	if d > time.Hour {
+		return time.Hour
+	}
+	return d
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Типы доменных событий
+const (
+	TripCreated      = "TripCreated"
+	TripCancelled    = "TripCancelled"
+	TripCompleted    = "TripCompleted"
+	BookingCreated   = "BookingCreated"
+	BookingConfirmed = "BookingConfirmed"
+	BookingCancelled = "BookingCancelled"
+	ReviewCreated    = "ReviewCreated"
+	ReviewUpdated    = "ReviewUpdated"
+	ReviewPublished  = "ReviewPublished" // отзыв стал виден адресату и учитывается в рейтинге
+	ReviewReplied    = "ReviewReplied"
+	ReviewHidden     = "ReviewHidden" // скрыт модератором
+	ReviewRestored   = "ReviewRestored"
+	ReviewDeleted    = "ReviewDeleted"
+)
+
+// Event — запись из таблицы outbox_events
+type Event struct {
+	ID            int64           `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregateType"`
+	AggregateID   int             `json:"aggregateId"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
+
+// Decode разбирает полезную нагрузку события
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// TripPayload — данные событий поездки
+type TripPayload struct {
+	TripID       int   `json:"tripId"`
+	DriverID     int   `json:"driverId"`
+	PassengerIDs []int `json:"passengerIds,omitempty"`
+}
+
+// BookingPayload — данные событий бронирования
+type BookingPayload struct {
+	BookingID   int `json:"bookingId"`
+	TripID      int `json:"tripId"`
+	DriverID    int `json:"driverId"`
+	PassengerID int `json:"passengerId"`
+	SeatsBooked int `json:"seatsBooked"`
+	TotalPrice  int `json:"totalPrice"`
+	CancelledBy int `json:"cancelledBy,omitempty"` // кто отменил: водитель, сам пассажир или администратор
+	Fee         int `json:"fee,omitempty"`         // удержание по политике отмены
+	// Отменено администратором: извещаются и водитель, и пассажир
+	CancelledByAdmin bool `json:"cancelledByAdmin,omitempty"`
+}
+
+// ReviewPayload — данные событий отзыва
+type ReviewPayload struct {
+	ReviewID int `json:"reviewId"`
+	TripID   int `json:"tripId"`
+	AuthorID int `json:"authorId"`
+	TargetID int `json:"targetId"`
+	Rating   int `json:"rating"`
+}
+
+// Record записывает событие в outbox в той же транзакции, что и само изменение:
+// событие появится тогда и только тогда, когда изменение зафиксировано
+func Record(tx *sql.Tx, eventType, aggregateType string, aggregateID int, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO outbox_events (event_type, aggregate_type, aggregate_id, payload)
+		VALUES ($1, $2, $3, $4)
+	`, eventType, aggregateType, aggregateID, data)
+	return err
+}
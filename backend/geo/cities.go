@@ -0,0 +1,135 @@
+package geo
+
+import (
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// City — запись справочника городов
+type City struct {
+	Name     string
+	Timezone string
+	Lat      float64
+	Lon      float64
+}
+
+// DefaultTimezone используется для городов, которых нет в справочнике
+var DefaultTimezone = "Europe/Moscow"
+
+var cities = []City{
+	{"Москва", "Europe/Moscow", 55.7558, 37.6173},
+	{"Санкт-Петербург", "Europe/Moscow", 59.9343, 30.3351},
+	{"Нижний Новгород", "Europe/Moscow", 56.2965, 43.9361},
+	{"Казань", "Europe/Moscow", 55.7963, 49.1088},
+	{"Ярославль", "Europe/Moscow", 57.6261, 39.8845},
+	{"Тверь", "Europe/Moscow", 56.8587, 35.9176},
+	{"Владимир", "Europe/Moscow", 56.1291, 40.4066},
+	{"Рязань", "Europe/Moscow", 54.6296, 39.7425},
+	{"Тула", "Europe/Moscow", 54.1931, 37.6173},
+	{"Калуга", "Europe/Moscow", 54.5293, 36.2754},
+	{"Смоленск", "Europe/Moscow", 54.7826, 32.0453},
+	{"Воронеж", "Europe/Moscow", 51.6720, 39.1843},
+	{"Белгород", "Europe/Moscow", 50.5997, 36.5983},
+	{"Курск", "Europe/Moscow", 51.7304, 36.1926},
+	{"Липецк", "Europe/Moscow", 52.6031, 39.5708},
+	{"Ростов-на-Дону", "Europe/Moscow", 47.2357, 39.7015},
+	{"Краснодар", "Europe/Moscow", 45.0355, 38.9753},
+	{"Сочи", "Europe/Moscow", 43.5855, 39.7231},
+	{"Ставрополь", "Europe/Moscow", 45.0448, 41.9691},
+	{"Великий Новгород", "Europe/Moscow", 58.5215, 31.2755},
+	{"Псков", "Europe/Moscow", 57.8194, 28.3318},
+	{"Архангельск", "Europe/Moscow", 64.5393, 40.5187},
+	{"Мурманск", "Europe/Moscow", 68.9585, 33.0827},
+	{"Петрозаводск", "Europe/Moscow", 61.7849, 34.3469},
+	{"Вологда", "Europe/Moscow", 59.2181, 39.8886},
+	{"Кострома", "Europe/Moscow", 57.7677, 40.9264},
+	{"Иваново", "Europe/Moscow", 57.0004, 40.9739},
+	{"Пенза", "Europe/Moscow", 53.1959, 45.0183},
+	{"Киров", "Europe/Kirov", 58.6036, 49.6680},
+	{"Калининград", "Europe/Kaliningrad", 54.7104, 20.4522},
+	{"Волгоград", "Europe/Volgograd", 48.7080, 44.5133},
+	{"Самара", "Europe/Samara", 53.1959, 50.1002},
+	{"Тольятти", "Europe/Samara", 53.5078, 49.4204},
+	{"Ижевск", "Europe/Samara", 56.8526, 53.2045},
+	{"Саратов", "Europe/Saratov", 51.5336, 46.0343},
+	{"Ульяновск", "Europe/Ulyanovsk", 54.3142, 48.4031},
+	{"Астрахань", "Europe/Astrakhan", 46.3497, 48.0408},
+	{"Екатеринбург", "Asia/Yekaterinburg", 56.8389, 60.6057},
+	{"Челябинск", "Asia/Yekaterinburg", 55.1644, 61.4368},
+	{"Пермь", "Asia/Yekaterinburg", 58.0105, 56.2502},
+	{"Уфа", "Asia/Yekaterinburg", 54.7388, 55.9721},
+	{"Оренбург", "Asia/Yekaterinburg", 51.7682, 55.0970},
+	{"Тюмень", "Asia/Yekaterinburg", 57.1530, 65.5343},
+	{"Омск", "Asia/Omsk", 54.9885, 73.3242},
+	{"Новосибирск", "Asia/Novosibirsk", 55.0084, 82.9357},
+	{"Томск", "Asia/Tomsk", 56.4846, 84.9476},
+	{"Барнаул", "Asia/Barnaul", 53.3548, 83.7698},
+	{"Кемерово", "Asia/Novokuznetsk", 55.3547, 86.0873},
+	{"Новокузнецк", "Asia/Novokuznetsk", 53.7596, 87.1216},
+	{"Красноярск", "Asia/Krasnoyarsk", 56.0153, 92.8932},
+	{"Иркутск", "Asia/Irkutsk", 52.2870, 104.3050},
+	{"Улан-Удэ", "Asia/Irkutsk", 51.8335, 107.5841},
+	{"Чита", "Asia/Chita", 52.0340, 113.4994},
+	{"Якутск", "Asia/Yakutsk", 62.0355, 129.6755},
+	{"Хабаровск", "Asia/Vladivostok", 48.4827, 135.0838},
+	{"Владивосток", "Asia/Vladivostok", 43.1155, 131.8855},
+}
+
+var citiesByName = func() map[string]City {
+	m := make(map[string]City, len(cities))
+	for _, c := range cities {
+		m[normalize(c.Name)] = c
+	}
+	return m
+}()
+
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.ReplaceAll(name, "ё", "е")
+}
+
+// FindCity ищет город в справочнике без учёта регистра
+func FindCity(name string) (City, bool) {
+	c, ok := citiesByName[normalize(name)]
+	return c, ok
+}
+
+// Cities возвращает весь справочник
+func Cities() []City {
+	return cities
+}
+
+// TimezoneFor возвращает часовой пояс города отправления
+func TimezoneFor(city string) string {
+	if c, ok := FindCity(city); ok {
+		return c.Timezone
+	}
+	return DefaultTimezone
+}
+
+// Location загружает часовой пояс; при ошибке используется пояс по умолчанию
+func Location(tz string) *time.Location {
+	if loc, err := time.LoadLocation(tz); err == nil {
+		return loc
+	}
+	if loc, err := time.LoadLocation(DefaultTimezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// LocalTime переводит момент времени в часовой пояс города
+func LocalTime(t time.Time, tz string) time.Time {
+	return t.In(Location(tz))
+}
+
+// ParseDeparture разбирает локальные дату и время отправления в часовом поясе tz
+func ParseDeparture(date, clock, tz string) (time.Time, error) {
+	loc := Location(tz)
+	t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, loc)
+	if err != nil {
+		t, err = time.ParseInLocation("2006-01-02 15:04:05", date+" "+clock, loc)
+	}
+	return t, err
+}
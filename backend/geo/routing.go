@@ -0,0 +1,134 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Route — расстояние и расчётное время в пути между двумя городами
+type Route struct {
+	DistanceKm float64
+	Duration   time.Duration
+}
+
+// RoutingProvider строит маршрут между двумя городами справочника
+type RoutingProvider interface {
+	Route(from, to City) (Route, error)
+}
+
+// Provider — внешний провайдер маршрутов; если nil или он вернул ошибку,
+// используется офлайн-оценка по координатам
+var Provider RoutingProvider
+
+// Offline используется как запасной вариант, когда внешний провайдер недоступен
+var Offline = OfflineProvider{RoadFactor: 1.25, AvgSpeedKmh: 70}
+
+// EstimateRoute оценивает маршрут между городами по названиям.
+// ok = false, если хотя бы одного города нет в справочнике.
+func EstimateRoute(fromCity, toCity string) (route Route, ok bool) {
+	from, ok := FindCity(fromCity)
+	if !ok {
+		return Route{}, false
+	}
+	to, ok := FindCity(toCity)
+	if !ok {
+		return Route{}, false
+	}
+
+	if Provider != nil {
+		route, err := Provider.Route(from, to)
+		if err == nil {
+			return route, true
+		}
+		log.Println("⚠️ Провайдер маршрутов недоступен, используем офлайн-оценку:", err)
+	}
+
+	route, err := Offline.Route(from, to)
+	return route, err == nil
+}
+
+// OfflineProvider оценивает маршрут по расстоянию по прямой,
+// умноженному на коэффициент извилистости дорог, и средней скорости
+type OfflineProvider struct {
+	RoadFactor  float64
+	AvgSpeedKmh float64
+}
+
+func (p OfflineProvider) Route(from, to City) (Route, error) {
+	distance := haversineKm(from.Lat, from.Lon, to.Lat, to.Lon) * p.RoadFactor
+	hours := distance / p.AvgSpeedKmh
+
+	return Route{
+		DistanceKm: math.Round(distance*10) / 10,
+		Duration:   time.Duration(hours * float64(time.Hour)).Round(time.Minute),
+	}, nil
+}
+
+// OSRMProvider запрашивает маршрут у сервера OSRM (http://project-osrm.org)
+type OSRMProvider struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func (p OSRMProvider) Route(from, to City) (Route, error) {
+	url := fmt.Sprintf("%s/route/v1/driving/%f,%f;%f,%f?overview=false",
+		p.BaseURL, from.Lon, from.Lat, to.Lon, to.Lat)
+
+	resp, err := p.Client.Get(url)
+	if err != nil {
+		return Route{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Route{}, fmt.Errorf("osrm: unexpected status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Code   string `json:"code"`
+		Routes []struct {
+			Distance float64 `json:"distance"` // метры
+			Duration float64 `json:"duration"` // секунды
+		} `json:"routes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Route{}, err
+	}
+	if body.Code != "Ok" || len(body.Routes) == 0 {
+		return Route{}, fmt.Errorf("osrm: no route (%s)", body.Code)
+	}
+
+	return Route{
+		DistanceKm: math.Round(body.Routes[0].Distance/100) / 10,
+		Duration:   (time.Duration(body.Routes[0].Duration) * time.Second).Round(time.Minute),
+	}, nil
+}
+
+// haversineKm — расстояние по большому кругу между двумя точками
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*math.Pi/180)*math.Cos(lat2*math.Pi/180)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// FormatDuration форматирует длительность для отображения: «5 ч 30 мин»
+func FormatDuration(minutes int) string {
+	h, m := minutes/60, minutes%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%d мин", m)
+	case m == 0:
+		return fmt.Sprintf("%d ч", h)
+	default:
+		return fmt.Sprintf("%d ч %d мин", h, m)
+	}
+}
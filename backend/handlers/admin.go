@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"hermes-carpooling/actionlog"
+	"hermes-carpooling/admin"
+	"hermes-carpooling/reviews"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindAdminReason читает необязательную причину действия администратора;
+// тело запроса может быть пустым. При ошибке ответ уже отправлен.
+func bindAdminReason(c *gin.Context) (string, bool) {
+	var req struct {
+		Reason string `json:"reason" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return req.Reason, true
+}
+
+// respondAdminError отвечает клиенту по ошибке действия администратора
+func respondAdminError(c *gin.Context, err error, message string) {
+	switch {
+	case err == admin.ErrUserNotFound || err == admin.ErrTripNotFound || err == admin.ErrBookingNotFound ||
+		err == admin.ErrNotMember:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case admin.IsUserError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("❌ Ошибка действия администратора:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// SearchUsers — поиск пользователей по имени, email, телефону или ID (?q, ?role, ?status)
+func SearchUsers(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != admin.StatusActive && status != admin.StatusSuspended && status != admin.StatusBanned {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	role := c.Query("role")
+	if role != "" && role != "driver" && role != "passenger" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	limit, offset := parsePagination(c, 50, 200)
+	users, total, err := admin.SearchUsers(admin.UserFilter{
+		Query:  c.Query("q"),
+		Role:   role,
+		Status: status,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		log.Println("❌ Ошибка поиска пользователей:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":  users,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetUserDetails — карточка пользователя с последними действиями администраторов по нему
+func GetUserDetails(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := admin.GetUser(userID)
+	if err != nil {
+		respondAdminError(c, err, "Failed to get user")
+		return
+	}
+
+	actions, _, err := actionlog.List(actionlog.Filter{TargetType: "user", TargetID: userID, Limit: 20})
+	if err != nil {
+		log.Println("❌ Ошибка получения журнала действий:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":    user,
+		"actions": actions,
+	})
+}
+
+// SuspendUser — приостановить аккаунт до даты until или на days дней
+func SuspendUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Until  *time.Time `json:"until"`
+		Days   int        `json:"days" binding:"min=0,max=3650"`
+		Reason string     `json:"reason" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var until time.Time
+	switch {
+	case req.Until != nil:
+		until = *req.Until
+	case req.Days > 0:
+		until = time.Now().AddDate(0, 0, req.Days)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "until or days is required"})
+		return
+	}
+
+	if err := admin.Suspend(userID, auditActor(c), until, req.Reason); err != nil {
+		respondAdminError(c, err, "Failed to suspend user")
+		return
+	}
+
+	log.Printf("⛔ Пользователь %d приостановлен до %s", userID, until.Format(time.RFC3339))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User suspended",
+		"until":   until,
+	})
+}
+
+// BanUser — заблокировать аккаунт бессрочно
+func BanUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := admin.Ban(userID, auditActor(c), req.Reason); err != nil {
+		respondAdminError(c, err, "Failed to ban user")
+		return
+	}
+
+	log.Println("⛔ Пользователь заблокирован:", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "User banned"})
+}
+
+// ReinstateUser — снять приостановку или блокировку аккаунта
+func ReinstateUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	reason, ok := bindAdminReason(c)
+	if !ok {
+		return
+	}
+
+	if err := admin.Reinstate(userID, auditActor(c), reason); err != nil {
+		respondAdminError(c, err, "Failed to reinstate user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User reinstated"})
+}
+
+// SetUserPartner — выдать или отозвать доступ к партнёрскому API (вебхукам)
+func SetUserPartner(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Partner *bool  `json:"partner" binding:"required"`
+		Reason  string `json:"reason" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := admin.SetPartner(userID, auditActor(c), *req.Partner, req.Reason); err != nil {
+		respondAdminError(c, err, "Failed to update partner access")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Partner access updated", "partner": *req.Partner})
+}
+
+// GetPartnerMembers — сотрудники партнёра, чьи события приходят на его вебхуки
+func GetPartnerMembers(c *gin.Context) {
+	partnerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	members, err := admin.PartnerMembers(partnerID)
+	if err != nil {
+		respondAdminError(c, err, "Failed to get partner members")
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// AddPartnerMember — добавить пользователя в сотрудники партнёра
+func AddPartnerMember(c *gin.Context) {
+	partnerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		UserID int    `json:"userId" binding:"required"`
+		Reason string `json:"reason" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := admin.AddPartnerMember(partnerID, req.UserID, auditActor(c), req.Reason); err != nil {
+		respondAdminError(c, err, "Failed to add partner member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Partner member added"})
+}
+
+// RemovePartnerMember — исключить пользователя из сотрудников партнёра
+func RemovePartnerMember(c *gin.Context) {
+	partnerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID"})
+		return
+	}
+
+	reason, ok := bindAdminReason(c)
+	if !ok {
+		return
+	}
+
+	if err := admin.RemovePartnerMember(partnerID, userID, auditActor(c), reason); err != nil {
+		respondAdminError(c, err, "Failed to remove partner member")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Partner member removed"})
+}
+
+// ForceCancelTrip — отменить поездку от имени платформы: пассажирам полный возврат, без штрафа водителю
+func ForceCancelTrip(c *gin.Context) {
+	tripID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trip ID"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refunded, err := admin.CancelTrip(tripID, auditActor(c), req.Reason)
+	if err != nil {
+		respondAdminError(c, err, "Failed to cancel trip")
+		return
+	}
+
+	log.Println("✅ Поездка отменена администратором:", tripID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Trip cancelled",
+		"refunded": refunded,
+	})
+}
+
+// ForceCancelBooking — отменить бронирование от имени платформы с полным возвратом пассажиру
+func ForceCancelBooking(c *gin.Context) {
+	bookingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := admin.CancelBooking(bookingID, auditActor(c), req.Reason)
+	if err != nil {
+		respondAdminError(c, err, "Failed to cancel booking")
+		return
+	}
+
+	log.Println("✅ Бронирование отменено администратором:", bookingID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Booking cancelled",
+		"refund":  refund,
+	})
+}
+
+// EditReview — правка оценки или текста отзыва администратором
+func EditReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	var req struct {
+		Rating  *int    `json:"rating" binding:"omitempty,min=1,max=5"`
+		Comment *string `json:"comment" binding:"omitempty,max=2000"`
+		Reason  string  `json:"reason" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Rating == nil && req.Comment == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rating or comment is required"})
+		return
+	}
+
+	if err := reviews.Edit(reviewID, auditActor(c), req.Rating, req.Comment, req.Reason); err != nil {
+		respondModerationError(c, err, "Failed to edit review")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review updated"})
+}
+
+// GetPlatformStats — сводка по платформе за период (?from, ?to в формате YYYY-MM-DD)
+func GetPlatformStats(c *gin.Context) {
+	r, ok := parseReportRange(c)
+	if !ok {
+		return
+	}
+
+	stats, err := admin.PlatformStats(r.From, r.To)
+	if err != nil {
+		log.Println("❌ Ошибка расчёта статистики платформы:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetAdminActions — журнал действий администраторов (?adminId, ?action, ?targetType, ?targetId)
+func GetAdminActions(c *gin.Context) {
+	limit, offset := parsePagination(c, 50, 200)
+	filter := actionlog.Filter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("targetType"),
+		Limit:      limit,
+		Offset:     offset,
+	}
+	filter.AdminID, _ = strconv.Atoi(c.Query("adminId"))
+	filter.TargetID, _ = strconv.Atoi(c.Query("targetId"))
+
+	actions, total, err := actionlog.List(filter)
+	if err != nil {
+		log.Println("❌ Ошибка получения журнала действий:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get actions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"actions": actions,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
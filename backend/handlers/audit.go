@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"hermes-carpooling/audit"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditActor — автор изменений для журнала: текущий пользователь и ID запроса
+func auditActor(c *gin.Context) audit.Actor {
+	return audit.Actor{UserID: c.GetInt("userID"), RequestID: c.GetString("requestID")}
+}
+
+// parseAuditFilter читает общие параметры журнала: ?entityType, ?entityId, ?from, ?to
+// (YYYY-MM-DD), ?limit, ?offset. При ошибке ответ уже отправлен.
+func parseAuditFilter(c *gin.Context) (audit.Filter, bool) {
+	limit, offset := parsePagination(c, 50, 200)
+	f := audit.Filter{
+		EntityType: c.Query("entityType"),
+		Limit:      limit,
+		Offset:     offset,
+	}
+
+	if f.EntityType != "" && !audit.ValidEntityType(f.EntityType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entity type", "entityTypes": audit.EntityTypes})
+		return f, false
+	}
+	if id := c.Query("entityId"); id != "" {
+		entityID, err := strconv.Atoi(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entity ID"})
+			return f, false
+		}
+		f.EntityID = entityID
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(reportDateLayout, from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return f, false
+		}
+		f.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(reportDateLayout, to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return f, false
+		}
+		f.To = t.AddDate(0, 0, 1)
+	}
+	return f, true
+}
+
+// sendAuditEntries отдаёт страницу журнала
+func sendAuditEntries(c *gin.Context, f audit.Filter, entries []audit.Entry, total int) {
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   f.Limit,
+		"offset":  f.Offset,
+	})
+}
+
+// GetAuditLog — журнал изменений для администраторов; кроме общих параметров
+// фильтрует по ?actorId, ?requestId и ?userId (изменения, касающиеся пользователя)
+func GetAuditLog(c *gin.Context) {
+	f, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	f.RequestID = c.Query("requestId")
+	if id := c.Query("actorId"); id != "" {
+		actorID, err := strconv.Atoi(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor ID"})
+			return
+		}
+		f.ActorID = actorID
+	}
+	if id := c.Query("userId"); id != "" {
+		userID, err := strconv.Atoi(id)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		f.UserID = userID
+	}
+
+	entries, total, err := audit.List(f)
+	if err != nil {
+		log.Println("❌ Ошибка получения журнала изменений:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get audit log"})
+		return
+	}
+
+	sendAuditEntries(c, f, entries, total)
+}
+
+// GetMyHistory — история изменений моего аккаунта, поездок, бронирований и отзывов
+func GetMyHistory(c *gin.Context) {
+	f, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := audit.History(c.GetInt("userID"), f)
+	if err != nil {
+		log.Println("❌ Ошибка получения истории изменений:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get history"})
+		return
+	}
+
+	sendAuditEntries(c, f, entries, total)
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+    "log"
     "net/http"
     "time"
     
+    "hermes-carpooling/admin"
+    "hermes-carpooling/audit"
     "hermes-carpooling/config"
     "hermes-carpooling/database"
     "hermes-carpooling/models"
+    "hermes-carpooling/promotions"
     
     "github.com/gin-gonic/gin"
     "github.com/golang-jwt/jwt/v4"
@@ -32,13 +36,37 @@ func Register(c *gin.Context) {
         role = "driver"
     }
     
-    // Сохраняем пользователя в БД
+    // Пригласивший пользователь, если регистрация по реферальному коду
+    var referredBy *int
+    if userReq.ReferralCode != "" {
+        referrerID, ok := promotions.FindReferrer(userReq.ReferralCode)
+        if !ok {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid referral code"})
+            return
+        }
+        referredBy = &referrerID
+    }
+
+    referralCode, err := promotions.NewReferralCode()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+        return
+    }
+
+    // ID выделяем заранее: журнал изменений записывает регистрацию от имени нового пользователя
     var userID int
-    err := database.DB.QueryRow(`
-        INSERT INTO users (full_name, email, phone, password_hash, role) 
-        VALUES ($1, $2, $3, $4, $5) 
-        RETURNING id
-    `, userReq.FullName, userReq.Email, userReq.Phone, userReq.Password, role).Scan(&userID)
+    err = database.DB.QueryRow(`SELECT nextval(pg_get_serial_sequence('users', 'id'))`).Scan(&userID)
+    if err != nil {
+        log.Println("❌ Ошибка выделения ID пользователя:", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+        return
+    }
+
+    // Сохраняем пользователя в БД
+    _, err = audit.Exec(audit.Actor{UserID: userID, RequestID: c.GetString("requestID")}, `
+        INSERT INTO users (id, full_name, email, phone, password_hash, role, referral_code, referred_by) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, userID, userReq.FullName, userReq.Email, userReq.Phone, userReq.Password, role, referralCode, referredBy)
     
     if err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": "User already exists"})
@@ -100,6 +128,17 @@ func Login(c *gin.Context) {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
         return
     }
+
+    // Приостановленные и заблокированные аккаунты не входят
+    restriction, err := admin.AccountRestriction(user.ID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check account"})
+        return
+    }
+    if restriction != nil {
+        c.JSON(http.StatusForbidden, gin.H{"error": restriction.Message(), "reason": restriction.Reason})
+        return
+    }
     
     // Генерируем JWT токен
     cfg := config.Load()
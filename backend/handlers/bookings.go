@@ -2,10 +2,19 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"hermes-carpooling/audit"
+	"hermes-carpooling/cancellation"
 	"hermes-carpooling/database"
+	"hermes-carpooling/events"
+	"hermes-carpooling/geo"
 	"hermes-carpooling/models"
+	"hermes-carpooling/payments"
+	"hermes-carpooling/promotions"
+	"hermes-carpooling/safety"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,11 +34,12 @@ func CreateBooking(c *gin.Context) {
 	// Проверяем доступность мест и цену поездки
 	var availableSeats int
 	var price int
+	var driverID int
 	err := database.DB.QueryRow(`
-		SELECT available_seats, price 
+		SELECT available_seats, price, driver_id
 		FROM trips 
-		WHERE id = $1 AND status = 'active'
-	`, bookingReq.TripID).Scan(&availableSeats, &price)
+		WHERE id = $1 AND status = 'active' AND departure_at > NOW()
+	`, bookingReq.TripID).Scan(&availableSeats, &price, &driverID)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found or not active"})
@@ -41,26 +51,120 @@ func CreateBooking(c *gin.Context) {
 		return
 	}
 
+	// Водитель и пассажир, заблокировавшие друг друга, вместе не едут
+	blocked, err := safety.BlockedBetween(database.DB, driverID, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot book this driver's trips"})
+		return
+	}
+
 	totalPrice := price * bookingReq.SeatsBooked
 
+	tx, err := audit.Begin(auditActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Скидка по промокоду
+	var promo promotions.PromoCode
+	discount := 0
+	if bookingReq.PromoCode != "" {
+		promo, err = promotions.Check(tx, bookingReq.PromoCode, userID.(int))
+		if err != nil {
+			if promotions.IsUserError(err) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else {
+				log.Println("❌ Ошибка проверки промокода:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+			}
+			return
+		}
+		discount = promo.Discount(totalPrice)
+	}
+
 	// Создаем бронирование со статусом pending (ожидает подтверждения водителя)
 	var bookingID int
-	err = database.DB.QueryRow(`
-		INSERT INTO bookings (trip_id, passenger_id, seats_booked, total_price, status)
-		VALUES ($1, $2, $3, $4, 'pending')
+	err = tx.QueryRow(`
+		INSERT INTO bookings (trip_id, passenger_id, seats_booked, total_price, discount_amount, status)
+		VALUES ($1, $2, $3, $4, $5, 'pending')
 		RETURNING id
-	`, bookingReq.TripID, userID, bookingReq.SeatsBooked, totalPrice).Scan(&bookingID)
+	`, bookingReq.TripID, userID, bookingReq.SeatsBooked, totalPrice, discount).Scan(&bookingID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+		return
+	}
+
+	if discount > 0 {
+		if err := promotions.Redeem(tx, promo.ID, userID.(int), bookingID, discount); err != nil {
+			log.Println("❌ Ошибка применения промокода:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+			return
+		}
+	}
+
+	// Бонусы списываются сразу и возвращаются на кошелёк, если бронирование отменят
+	walletAmount := 0
+	if bookingReq.UseWallet {
+		walletAmount, err = promotions.Debit(tx, userID.(int), bookingID, totalPrice-discount)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE bookings SET wallet_amount = $1 WHERE id = $2`, walletAmount, bookingID)
+		}
+		if err != nil {
+			log.Println("❌ Ошибка списания с кошелька:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+			return
+		}
+	}
+
+	// Привязываем переписку, начатую до бронирования, к заявке
+	_, err = tx.Exec(`
+		UPDATE conversations SET booking_id = $1
+		WHERE trip_id = $2 AND passenger_id = $3
+	`, bookingID, bookingReq.TripID, userID)
+
+	if err != nil {
+		log.Println("❌ Ошибка привязки переписки:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+		return
+	}
+
+	// Водитель узнает о заявке через подписчиков события (уведомления, realtime)
+	err = events.Record(tx, events.BookingCreated, "booking", bookingID, events.BookingPayload{
+		BookingID:   bookingID,
+		TripID:      bookingReq.TripID,
+		DriverID:    driverID,
+		PassengerID: userID.(int),
+		SeatsBooked: bookingReq.SeatsBooked,
+		TotalPrice:  totalPrice,
+	})
 
 	if err != nil {
+		log.Println("❌ Ошибка записи события:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create booking"})
+		return
+	}
+	events.Wake()
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message":    "Booking created successfully",
-		"bookingId":  bookingID,
-		"totalPrice": totalPrice,
-		"status":     "pending",
+		"message":      "Booking created successfully",
+		"bookingId":    bookingID,
+		"totalPrice":   totalPrice,
+		"discount":     discount,
+		"walletAmount": walletAmount,
+		"amountDue":    totalPrice - discount - walletAmount,
+		"status":       "pending",
 	})
 }
 
@@ -69,9 +173,10 @@ func GetMyBookings(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	rows, err := database.DB.Query(`
-		SELECT b.id, b.trip_id, b.seats_booked, b.total_price, b.status, b.created_at,
-			   t.from_city, t.to_city, t.trip_date, t.trip_time,
-			   u.full_name as driver_name
+		SELECT b.id, b.trip_id, b.seats_booked, b.total_price, b.status, b.payment_status, b.created_at,
+			   b.discount_amount, b.wallet_amount,
+			   t.from_city, t.to_city, t.trip_date, t.departure_at, t.timezone,
+			   u.full_name as driver_name, u.phone as driver_phone
 		FROM bookings b
 		JOIN trips t ON b.trip_id = t.id
 		JOIN users u ON t.driver_id = u.id
@@ -88,37 +193,58 @@ func GetMyBookings(c *gin.Context) {
 	var bookings []gin.H
 	for rows.Next() {
 		var (
-			id          int
-			tripID      int
-			seatsBooked int
-			totalPrice  int
-			status      string
-			createdAt   time.Time
-			fromCity    string
-			toCity      string
-			tripDate    time.Time
-			tripTime    string
-			driverName  string
+			id            int
+			tripID        int
+			seatsBooked   int
+			totalPrice    int
+			status        string
+			paymentStatus string
+			createdAt     time.Time
+			discount      int
+			walletAmount  int
+			fromCity      string
+			toCity        string
+			tripDate      time.Time
+			departureAt   time.Time
+			timezone      string
+			driverName    string
+			driverPhone   string
 		)
 
-		err := rows.Scan(&id, &tripID, &seatsBooked, &totalPrice, &status, &createdAt,
-			&fromCity, &toCity, &tripDate, &tripTime, &driverName)
+		err := rows.Scan(&id, &tripID, &seatsBooked, &totalPrice, &status, &paymentStatus, &createdAt,
+			&discount, &walletAmount,
+			&fromCity, &toCity, &tripDate, &departureAt, &timezone, &driverName, &driverPhone)
 		if err != nil {
 			continue
 		}
 
+		// Телефон водителя виден пассажиру только после подтверждения
+		phoneRevealed := status == "confirmed"
+		if !phoneRevealed {
+			driverPhone = maskPhone(driverPhone)
+		}
+
+		local := geo.LocalTime(departureAt, timezone)
+
 		bookings = append(bookings, gin.H{
-			"id":          id,
-			"tripId":      tripID,
-			"seatsBooked": seatsBooked,
-			"totalPrice":  totalPrice,
-			"status":      status,
-			"createdAt":   createdAt,
-			"fromCity":    fromCity,
-			"toCity":      toCity,
-			"tripDate":    tripDate.Format("2006-01-02"),
-			"tripTime":    tripTime,
-			"driverName":  driverName,
+			"id":            id,
+			"tripId":        tripID,
+			"seatsBooked":   seatsBooked,
+			"totalPrice":    totalPrice,
+			"status":        status,
+			"paymentStatus": paymentStatus,
+			"discount":      discount,
+			"walletAmount":  walletAmount,
+			"amountDue":     totalPrice - discount - walletAmount,
+			"createdAt":     createdAt,
+			"fromCity":      fromCity,
+			"toCity":        toCity,
+			"tripDate":      tripDate.Format("2006-01-02"),
+			"tripTime":      local.Format("15:04"),
+			"departureAt":   departureAt.UTC(),
+			"driverName":    driverName,
+			"driverPhone":   driverPhone,
+			"phoneRevealed": phoneRevealed,
 		})
 	}
 
@@ -130,8 +256,8 @@ func GetDriverBookings(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	rows, err := database.DB.Query(`
-		SELECT b.id, b.trip_id, b.seats_booked, b.total_price, b.status, b.created_at,
-		       t.from_city, t.to_city, t.trip_date, t.trip_time,
+		SELECT b.id, b.trip_id, b.seats_booked, b.total_price, b.status, b.payment_status, b.created_at,
+		       t.from_city, t.to_city, t.trip_date, t.departure_at, t.timezone,
 		       u.full_name as passenger_name, u.phone as passenger_phone
 		FROM bookings b
 		JOIN trips t ON b.trip_id = t.id
@@ -160,34 +286,47 @@ func GetDriverBookings(c *gin.Context) {
 			seatsBooked    int
 			totalPrice     int
 			status         string
+			paymentStatus  string
 			createdAt      time.Time
 			fromCity       string
 			toCity         string
 			tripDate       time.Time
-			tripTime       string
+			departureAt    time.Time
+			timezone       string
 			passengerName  string
 			passengerPhone string
 		)
 
-		err := rows.Scan(&id, &tripID, &seatsBooked, &totalPrice, &status, &createdAt,
-			&fromCity, &toCity, &tripDate, &tripTime, &passengerName, &passengerPhone)
+		err := rows.Scan(&id, &tripID, &seatsBooked, &totalPrice, &status, &paymentStatus, &createdAt,
+			&fromCity, &toCity, &tripDate, &departureAt, &timezone, &passengerName, &passengerPhone)
 		if err != nil {
 			continue
 		}
 
+		local := geo.LocalTime(departureAt, timezone)
+
+		// Телефон пассажира виден водителю только после подтверждения
+		phoneRevealed := status == "confirmed"
+		if !phoneRevealed {
+			passengerPhone = maskPhone(passengerPhone)
+		}
+
 		bookings = append(bookings, gin.H{
 			"id":             id,
 			"tripId":         tripID,
 			"seatsBooked":    seatsBooked,
 			"totalPrice":     totalPrice,
 			"status":         status,
+			"paymentStatus":  paymentStatus,
 			"createdAt":      createdAt,
 			"fromCity":       fromCity,
 			"toCity":         toCity,
 			"tripDate":       tripDate.Format("2006-01-02"),
-			"tripTime":       tripTime,
+			"tripTime":       local.Format("15:04"),
+			"departureAt":    departureAt.UTC(),
 			"passengerName":  passengerName,
 			"passengerPhone": passengerPhone,
+			"phoneRevealed":  phoneRevealed,
 		})
 	}
 
@@ -210,15 +349,19 @@ func UpdateBookingStatus(c *gin.Context) {
 
 	// Проверяем, что водитель владеет этой поездкой
 	var driverID int
+	var passengerID int
 	var tripID int
 	var seatsBooked int
+	var totalPrice int
+	var subsidy int
 	var currentStatus string
 	err := database.DB.QueryRow(`
-		SELECT t.driver_id, b.trip_id, b.seats_booked, b.status
+		SELECT t.driver_id, b.passenger_id, b.trip_id, b.seats_booked, b.total_price,
+		       b.discount_amount + b.wallet_amount, b.status
 		FROM bookings b
 		JOIN trips t ON b.trip_id = t.id
 		WHERE b.id = $1
-	`, bookingID).Scan(&driverID, &tripID, &seatsBooked, &currentStatus)
+	`, bookingID).Scan(&driverID, &passengerID, &tripID, &seatsBooked, &totalPrice, &subsidy, &currentStatus)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
@@ -236,21 +379,33 @@ func UpdateBookingStatus(c *gin.Context) {
 		return
 	}
 
-	// Обновляем статус бронирования
-	_, err = database.DB.Exec(`
+	tx, err := audit.Begin(auditActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Обновляем статус бронирования, только если его не успели обработать
+	// параллельно (повторное подтверждение или отмена пассажиром)
+	result, err := tx.Exec(`
 		UPDATE bookings 
 		SET status = $1, updated_at = NOW()
-		WHERE id = $2
+		WHERE id = $2 AND status = 'pending'
 	`, req.Status, bookingID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
 		return
 	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Booking status has changed"})
+		return
+	}
 
 	// Если подтверждено — уменьшаем available_seats
 	if req.Status == "confirmed" {
-		_, err = database.DB.Exec(`
+		result, err := tx.Exec(`
 			UPDATE trips 
 			SET available_seats = available_seats - $1
 			WHERE id = $2 AND available_seats >= $1
@@ -260,21 +415,238 @@ func UpdateBookingStatus(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update seats"})
 			return
 		}
+
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough available seats"})
+			return
+		}
+	}
+
+	bookingIDInt, _ := strconv.Atoi(bookingID)
+
+	// При подтверждении блокируем оплату на счёте пассажира до завершения поездки
+	var paymentRef string
+	if req.Status == "confirmed" {
+		paymentRef, err = payments.Authorize(tx, bookingIDInt, passengerID, totalPrice-subsidy, subsidy)
+		if err != nil {
+			if errors.Is(err, payments.ErrDeclined) {
+				c.JSON(http.StatusPaymentRequired, gin.H{"error": "Passenger payment was declined"})
+			} else {
+				log.Println("❌ Ошибка авторизации платежа:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize payment"})
+			}
+			return
+		}
+	}
+
+	eventType := events.BookingConfirmed
+	if req.Status == "cancelled" {
+		eventType = events.BookingCancelled
 	}
 
+	payload := events.BookingPayload{
+		BookingID:   bookingIDInt,
+		TripID:      tripID,
+		DriverID:    driverID,
+		PassengerID: passengerID,
+		SeatsBooked: seatsBooked,
+		TotalPrice:  totalPrice,
+	}
+	if req.Status == "cancelled" {
+		payload.CancelledBy = driverID
+	}
+
+	err = events.Record(tx, eventType, "booking", bookingIDInt, payload)
+
+	if err != nil {
+		log.Println("❌ Ошибка записи события:", err)
+		payments.VoidAuthorization(paymentRef)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		payments.VoidAuthorization(paymentRef)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking"})
+		return
+	}
+	events.Wake()
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Booking status updated",
 		"status":  req.Status,
 	})
 }
+// bookingCancellation — данные бронирования, нужные для расчёта отмены пассажиром
+type bookingCancellation struct {
+	passengerID int
+	driverID    int
+	tripID      int
+	seatsBooked int
+	totalPrice  int // с учётом скидки по промокоду
+	status      string
+	departureAt time.Time
+	policy      cancellation.Policy
+}
+
+// loadBookingCancellation загружает бронирование пассажира и проверяет, что его ещё можно отменить.
+// При ошибке ответ уже отправлен.
+func loadBookingCancellation(c *gin.Context, bookingID string, userID int) (bookingCancellation, bool) {
+	var b bookingCancellation
+	var policyKey string
+	err := database.DB.QueryRow(`
+		SELECT b.passenger_id, t.driver_id, b.trip_id, b.seats_booked, b.total_price - b.discount_amount, b.status,
+		       t.departure_at, t.cancellation_policy
+		FROM bookings b
+		JOIN trips t ON b.trip_id = t.id
+		WHERE b.id = $1
+	`, bookingID).Scan(&b.passengerID, &b.driverID, &b.tripID, &b.seatsBooked, &b.totalPrice, &b.status,
+		&b.departureAt, &policyKey)
+
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return b, false
+	}
+
+	if b.passengerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the passenger of this booking"})
+		return b, false
+	}
+
+	if b.status != "pending" && b.status != "confirmed" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Booking cannot be cancelled"})
+		return b, false
+	}
+
+	if !b.departureAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Trip has already departed"})
+		return b, false
+	}
+
+	b.policy = cancellation.Get(policyKey)
+	return b, true
+}
+
+// charge рассчитывает удержание и возврат; за неподтверждённую заявку деньги не блокировались
+func (b bookingCancellation) charge() cancellation.Charge {
+	if b.status == "pending" {
+		return cancellation.Charge{Rule: cancellation.RulePending}
+	}
+	return b.policy.PassengerCharge(b.totalPrice, time.Until(b.departureAt))
+}
+
+// GetCancellationQuote — сколько пассажир потеряет, если отменит бронирование сейчас
+func GetCancellationQuote(c *gin.Context) {
+	b, ok := loadBookingCancellation(c, c.Param("id"), c.GetInt("userID"))
+	if !ok {
+		return
+	}
+
+	charge := b.charge()
+	c.JSON(http.StatusOK, gin.H{
+		"policy": b.policy,
+		"rule":   charge.Rule,
+		"fee":    charge.Fee,
+		"refund": charge.Refund,
+	})
+}
+
+// CancelBooking — пассажир отменяет своё бронирование по политике отмены поездки
+func CancelBooking(c *gin.Context) {
+	userID := c.GetInt("userID")
+	bookingID := c.Param("id")
+
+	b, ok := loadBookingCancellation(c, bookingID, userID)
+	if !ok {
+		return
+	}
+
+	charge := b.charge()
+	bookingIDInt, _ := strconv.Atoi(bookingID)
+
+	tx, err := audit.Begin(auditActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Статус проверяется ещё раз, чтобы не отменить бронирование дважды
+	result, err := tx.Exec(`
+		UPDATE bookings SET status = 'cancelled', updated_at = NOW()
+		WHERE id = $1 AND status = $2
+	`, bookingID, b.status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Booking status has changed"})
+		return
+	}
+
+	// Подтверждённое бронирование занимало места — возвращаем их в поездку
+	if b.status == "confirmed" {
+		_, err = tx.Exec(`
+			UPDATE trips SET available_seats = available_seats + $1 WHERE id = $2
+		`, b.seatsBooked, b.tripID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO cancellations (booking_id, trip_id, cancelled_by, party, rule, policy, hours_before_departure, fee, refund)
+		VALUES ($1, $2, $3, 'passenger', $4, $5, $6, $7, $8)
+	`, bookingID, b.tripID, userID, charge.Rule, b.policy.Key, time.Until(b.departureAt).Hours(), charge.Fee, charge.Refund)
+	if err != nil {
+		log.Println("❌ Ошибка записи отмены:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+		return
+	}
+
+	// Удержание и возврат по платежу выполнит обработчик BookingCancelled
+	err = events.Record(tx, events.BookingCancelled, "booking", bookingIDInt, events.BookingPayload{
+		BookingID:   bookingIDInt,
+		TripID:      b.tripID,
+		DriverID:    b.driverID,
+		PassengerID: userID,
+		SeatsBooked: b.seatsBooked,
+		TotalPrice:  b.totalPrice,
+		CancelledBy: userID,
+		Fee:         charge.Fee,
+	})
+	if err != nil {
+		log.Println("❌ Ошибка записи события:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel booking"})
+		return
+	}
+	events.Wake()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Booking cancelled",
+		"rule":    charge.Rule,
+		"fee":     charge.Fee,
+		"refund":  charge.Refund,
+	})
+}
+
 // RatePassenger - водитель оценивает пассажира
 func RatePassenger(c *gin.Context) {
 	bookingID := c.Param("id")
 	userID, _ := c.Get("userID")
 
 	var input struct {
-		Rating  int    `json:"rating" binding:"required,min=1,max=5"`
-		Comment string `json:"comment"`
+		Rating  int                 `json:"rating" binding:"required,min=1,max=5"`
+		Comment string              `json:"comment"`
+		Scores  models.ReviewScores `json:"scores"`
+		Tags    models.StringList   `json:"tags"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -314,44 +686,20 @@ func RatePassenger(c *gin.Context) {
 		return
 	}
 
-	// Проверяем, не оценивали ли уже этого пассажира
-	var existingReview int
-	err = database.DB.QueryRow(`
-		SELECT COUNT(*) FROM reviews 
-		WHERE trip_id = $1 AND author_id = $2 AND target_id = $3
-	`, tripID, userID.(int), passengerID).Scan(&existingReview)
-
-	if err == nil && existingReview > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "You have already rated this passenger"})
-		return
-	}
-
-	// Создаём отзыв
-	var reviewID int
-	err = database.DB.QueryRow(`
-		INSERT INTO reviews (trip_id, author_id, target_id, rating, comment, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
-		RETURNING id
-	`, tripID, userID.(int), passengerID, input.Rating, input.Comment).Scan(&reviewID)
-
+	// Создаём отзыв по тем же правилам, что и CreateReview; рейтинг пассажира пересчитает подписчик ReviewPublished
+	reviewID, err := insertReview(auditActor(c), models.ReviewCreate{
+		TripID:   tripID,
+		TargetID: passengerID,
+		Rating:   input.Rating,
+		Comment:  input.Comment,
+		Scores:   input.Scores,
+		Tags:     input.Tags,
+	})
 	if err != nil {
-		log.Println("❌ Ошибка создания отзыва:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
+		respondReviewError(c, err)
 		return
 	}
 
-	// Обновляем рейтинг пассажира
-	_, err = database.DB.Exec(`
-		UPDATE users SET 
-			rating = (SELECT AVG(rating) FROM reviews WHERE target_id = $1),
-			reviews_count = (SELECT COUNT(*) FROM reviews WHERE target_id = $1)
-		WHERE id = $1
-	`, passengerID)
-
-	if err != nil {
-		log.Println("⚠️ Предупреждение: не удалось обновить рейтинг пассажира:", err)
-	}
-
 	log.Println("✅ Пассажир оценён:", passengerID)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Passenger rated successfully",
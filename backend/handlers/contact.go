@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"hermes-carpooling/database"
+	"strings"
+	"unicode"
+)
+
+// maskPhone скрывает номер телефона, оставляя код страны и две последние цифры:
+// "+7 999 123-45-67" → "+7 *** ***-**-67"
+func maskPhone(phone string) string {
+	total := 0
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			total++
+		}
+	}
+
+	var b strings.Builder
+	seen := 0
+	for _, r := range phone {
+		if !unicode.IsDigit(r) {
+			b.WriteRune(r)
+			continue
+		}
+		seen++
+		if seen == 1 || seen > total-2 {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('*')
+		}
+	}
+	return b.String()
+}
+
+// canSeeTripContacts — телефон водителя виден ему самому и пассажирам
+// с подтверждённым бронированием на эту поездку
+func canSeeTripContacts(tripID, driverID, viewerID int) bool {
+	if viewerID == 0 {
+		return false
+	}
+	if viewerID == driverID {
+		return true
+	}
+
+	var count int
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*) FROM bookings
+		WHERE trip_id = $1 AND passenger_id = $2 AND status = 'confirmed'
+	`, tripID, viewerID).Scan(&count)
+
+	return err == nil && count > 0
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"hermes-carpooling/realtime"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Интервал пустых сообщений, чтобы прокси не закрывали простаивающее соединение
+const streamHeartbeat = 25 * time.Second
+
+// CreateStreamTicket — одноразовый билет для подключения к потоку событий (?ticket=)
+func CreateStreamTicket(c *gin.Context) {
+	ticket, err := realtime.IssueTicket(c.GetInt("userID"))
+	if err != nil {
+		log.Println("❌ Ошибка выдачи билета потока событий:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ticket":    ticket,
+		"expiresIn": int(realtime.TicketTTL.Seconds()),
+	})
+}
+
+// StreamEvents — поток событий пользователя (Server-Sent Events):
+// изменения бронирований, новые заявки, отмены поездок, новые сообщения
+func StreamEvents(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	events, unsubscribe := realtime.Default.Subscribe(userID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	log.Println("📡 Подключение к потоку событий:", userID)
+
+	c.SSEvent("ready", gin.H{"userId": userID})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{"time": time.Now().UTC()})
+			return true
+		}
+	})
+
+	log.Println("📡 Отключение от потока событий:", userID)
+}
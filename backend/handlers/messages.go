@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"database/sql"
+	"hermes-carpooling/database"
+	"hermes-carpooling/models"
+	"hermes-carpooling/realtime"
+	"hermes-carpooling/safety"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartConversation — начать (или открыть существующую) переписку по поездке.
+// Пассажир пишет водителю от своего имени, водитель указывает пассажира со своей заявкой.
+func StartConversation(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	var req models.ConversationCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var driverID int
+	var tripStatus string
+	err := database.DB.QueryRow(`
+		SELECT driver_id, status FROM trips WHERE id = $1
+	`, req.TripID).Scan(&driverID, &tripStatus)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get trip"})
+		}
+		return
+	}
+
+	passengerID := userID
+	if userID == driverID {
+		// Водитель может написать только пассажиру, у которого есть бронирование
+		if req.PassengerID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "passengerId is required"})
+			return
+		}
+		var count int
+		database.DB.QueryRow(`
+			SELECT COUNT(*) FROM bookings WHERE trip_id = $1 AND passenger_id = $2
+		`, req.TripID, req.PassengerID).Scan(&count)
+		if count == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This user has no booking on your trip"})
+			return
+		}
+		passengerID = req.PassengerID
+	} else if tripStatus != "active" {
+		// Вопросы до бронирования можно задавать только по активным поездкам
+		var count int
+		database.DB.QueryRow(`
+			SELECT COUNT(*) FROM bookings WHERE trip_id = $1 AND passenger_id = $2
+		`, req.TripID, userID).Scan(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Trip is not active"})
+			return
+		}
+	}
+
+	if !checkNotBlocked(c, driverID, passengerID, "Failed to start conversation") {
+		return
+	}
+
+	var conversationID int
+	err = database.DB.QueryRow(`
+		INSERT INTO conversations (trip_id, booking_id, driver_id, passenger_id)
+		VALUES ($1, (SELECT id FROM bookings WHERE trip_id = $1 AND passenger_id = $3), $2, $3)
+		ON CONFLICT (trip_id, passenger_id)
+			DO UPDATE SET booking_id = COALESCE(conversations.booking_id, EXCLUDED.booking_id)
+		RETURNING id
+	`, req.TripID, driverID, passengerID).Scan(&conversationID)
+
+	if err != nil {
+		log.Println("❌ Ошибка создания переписки:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversationId": conversationID})
+}
+
+// GetConversations — список переписок текущего пользователя с последним сообщением
+func GetConversations(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	rows, err := database.DB.Query(`
+		SELECT cv.id, cv.trip_id, cv.booking_id, cv.driver_id, cv.passenger_id, cv.updated_at,
+		       t.from_city, t.to_city, t.departure_at,
+		       other.id, other.full_name, COALESCE(other.avatar_url, ''),
+		       COALESCE(last.body, ''), last.created_at,
+		       (SELECT COUNT(*) FROM messages m
+		        WHERE m.conversation_id = cv.id AND m.sender_id <> $1 AND m.read_at IS NULL) as unread
+		FROM conversations cv
+		JOIN trips t ON cv.trip_id = t.id
+		JOIN users other ON other.id = CASE WHEN cv.driver_id = $1 THEN cv.passenger_id ELSE cv.driver_id END
+		LEFT JOIN LATERAL (
+			SELECT body, created_at FROM messages
+			WHERE conversation_id = cv.id
+			ORDER BY created_at DESC LIMIT 1
+		) last ON TRUE
+		WHERE cv.driver_id = $1 OR cv.passenger_id = $1
+		ORDER BY cv.updated_at DESC
+	`, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get conversations"})
+		return
+	}
+	defer rows.Close()
+
+	conversations := []gin.H{}
+	for rows.Next() {
+		var (
+			conv          models.Conversation
+			fromCity      string
+			toCity        string
+			departureAt   time.Time
+			otherID       int
+			otherName     string
+			otherAvatar   string
+			lastMessage   string
+			lastMessageAt *time.Time
+			unread        int
+		)
+
+		err := rows.Scan(&conv.ID, &conv.TripID, &conv.BookingID, &conv.DriverID, &conv.PassengerID, &conv.UpdatedAt,
+			&fromCity, &toCity, &departureAt, &otherID, &otherName, &otherAvatar,
+			&lastMessage, &lastMessageAt, &unread)
+		if err != nil {
+			continue
+		}
+
+		conversations = append(conversations, gin.H{
+			"id":            conv.ID,
+			"tripId":        conv.TripID,
+			"bookingId":     conv.BookingID,
+			"fromCity":      fromCity,
+			"toCity":        toCity,
+			"departureAt":   departureAt.UTC(),
+			"userId":        otherID,
+			"userName":      otherName,
+			"userAvatar":    otherAvatar,
+			"lastMessage":   lastMessage,
+			"lastMessageAt": lastMessageAt,
+			"unreadCount":   unread,
+			"updatedAt":     conv.UpdatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, conversations)
+}
+
+// GetConversationMessages — сообщения переписки (только для участников)
+func GetConversationMessages(c *gin.Context) {
+	userID := c.GetInt("userID")
+	conversationID := c.Param("id")
+
+	if _, ok := loadConversation(c, conversationID, userID); !ok {
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, conversation_id, sender_id, body, read_at, created_at
+		FROM messages
+		WHERE conversation_id = $1
+		ORDER BY created_at ASC
+	`, conversationID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get messages"})
+		return
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var m models.Message
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.Body, &m.ReadAt, &m.CreatedAt); err != nil {
+			continue
+		}
+		messages = append(messages, m)
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+// SendMessage — отправить сообщение в переписку
+func SendMessage(c *gin.Context) {
+	userID := c.GetInt("userID")
+	conversationID := c.Param("id")
+
+	var req models.MessageCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message body is empty"})
+		return
+	}
+
+	conv, ok := loadConversation(c, conversationID, userID)
+	if !ok {
+		return
+	}
+	if !checkNotBlocked(c, conv.DriverID, conv.PassengerID, "Failed to send message") {
+		return
+	}
+
+	var message models.Message
+	err := database.DB.QueryRow(`
+		INSERT INTO messages (conversation_id, sender_id, body)
+		VALUES ($1, $2, $3)
+		RETURNING id, conversation_id, sender_id, body, read_at, created_at
+	`, conv.ID, userID, body).Scan(&message.ID, &message.ConversationID, &message.SenderID,
+		&message.Body, &message.ReadAt, &message.CreatedAt)
+
+	if err != nil {
+		log.Println("❌ Ошибка отправки сообщения:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		return
+	}
+
+	database.DB.Exec(`UPDATE conversations SET updated_at = NOW() WHERE id = $1`, conv.ID)
+
+	recipientID := conv.DriverID
+	if userID == conv.DriverID {
+		recipientID = conv.PassengerID
+	}
+	realtime.Publish(recipientID, realtime.MessageCreated, message)
+
+	c.JSON(http.StatusCreated, message)
+}
+
+// MarkConversationRead — отметить входящие сообщения переписки прочитанными
+func MarkConversationRead(c *gin.Context) {
+	userID := c.GetInt("userID")
+	conversationID := c.Param("id")
+
+	conv, ok := loadConversation(c, conversationID, userID)
+	if !ok {
+		return
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE messages SET read_at = NOW()
+		WHERE conversation_id = $1 AND sender_id <> $2 AND read_at IS NULL
+	`, conv.ID, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark messages as read"})
+		return
+	}
+
+	marked, _ := result.RowsAffected()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Messages marked as read",
+		"marked":  marked,
+	})
+}
+
+// GetUnreadMessagesCount — общее число непрочитанных сообщений
+func GetUnreadMessagesCount(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	var unread int
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*) FROM messages m
+		JOIN conversations cv ON m.conversation_id = cv.id
+		WHERE (cv.driver_id = $1 OR cv.passenger_id = $1)
+		  AND m.sender_id <> $1 AND m.read_at IS NULL
+	`, userID).Scan(&unread)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unreadCount": unread})
+}
+
+// checkNotBlocked запрещает переписку, если один из участников заблокировал другого.
+// При ошибке ответ уже отправлен.
+func checkNotBlocked(c *gin.Context, driverID, passengerID int, message string) bool {
+	blocked, err := safety.BlockedBetween(database.DB, driverID, passengerID)
+	if err != nil {
+		log.Println("❌ Ошибка проверки блокировки:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+		return false
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot message this user"})
+		return false
+	}
+	return true
+}
+
+// loadConversation загружает переписку и проверяет, что пользователь в ней участвует.
+// При ошибке ответ уже отправлен.
+func loadConversation(c *gin.Context, conversationID string, userID int) (models.Conversation, bool) {
+	var conv models.Conversation
+	err := database.DB.QueryRow(`
+		SELECT id, trip_id, booking_id, driver_id, passenger_id, created_at, updated_at
+		FROM conversations WHERE id = $1
+	`, conversationID).Scan(&conv.ID, &conv.TripID, &conv.BookingID, &conv.DriverID,
+		&conv.PassengerID, &conv.CreatedAt, &conv.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get conversation"})
+		}
+		return conv, false
+	}
+
+	if conv.DriverID != userID && conv.PassengerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a participant of this conversation"})
+		return conv, false
+	}
+
+	return conv, true
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"hermes-carpooling/reviews"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondModerationError отвечает клиенту по ошибке ответа, жалобы или модерации
+func respondModerationError(c *gin.Context, err error, message string) {
+	switch {
+	case err == reviews.ErrReviewNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err == reviews.ErrNotReviewTarget:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case reviews.IsModerationError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("❌ Ошибка модерации отзыва:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ReplyToReview — публичный ответ адресата на отзыв о нём
+func ReplyToReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	var req struct {
+		Text string `json:"text" binding:"required,max=1000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := reviews.Reply(reviewID, auditActor(c), req.Text); err != nil {
+		respondModerationError(c, err, "Failed to save reply")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Reply posted"})
+}
+
+// ReportReview — пожаловаться на отзыв
+func ReportReview(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	var req struct {
+		Reason  string `json:"reason" binding:"required"`
+		Comment string `json:"comment" binding:"max=1000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reportID, err := reviews.Report(reviewID, userID, req.Reason, req.Comment)
+	if err == reviews.ErrInvalidReason {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "reasons": reviews.ReportReasons})
+		return
+	}
+	if err != nil {
+		respondModerationError(c, err, "Failed to report review")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Report submitted",
+		"reportId": reportID,
+	})
+}
+
+// GetReviewModerationQueue — отзывы с жалобами (?status=open|resolved|dismissed)
+func GetReviewModerationQueue(c *gin.Context) {
+	status := c.DefaultQuery("status", reviews.ReportOpen)
+	if status != reviews.ReportOpen && status != reviews.ReportResolved && status != reviews.ReportDismissed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	limit, offset := parsePagination(c, 50, 200)
+
+	queue, err := reviews.Queue(status, limit, offset)
+	if err != nil {
+		log.Println("❌ Ошибка получения очереди модерации:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get moderation queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, queue)
+}
+
+// HideReview — скрыть отзыв (администратор)
+func HideReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	reason, ok := bindAdminReason(c)
+	if !ok {
+		return
+	}
+
+	if err := reviews.Hide(reviewID, auditActor(c), reason); err != nil {
+		respondModerationError(c, err, "Failed to hide review")
+		return
+	}
+
+	log.Println("🙈 Отзыв скрыт модератором:", reviewID)
+	c.JSON(http.StatusOK, gin.H{"message": "Review hidden"})
+}
+
+// RestoreReview — вернуть скрытый отзыв или отклонить жалобы на него (администратор)
+func RestoreReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	reason, ok := bindAdminReason(c)
+	if !ok {
+		return
+	}
+
+	if err := reviews.Restore(reviewID, auditActor(c), reason); err != nil {
+		respondModerationError(c, err, "Failed to restore review")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review restored"})
+}
+
+// DeleteReview — удалить отзыв (администратор)
+func DeleteReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return
+	}
+
+	reason, ok := bindAdminReason(c)
+	if !ok {
+		return
+	}
+
+	if err := reviews.Delete(reviewID, auditActor(c), reason); err != nil {
+		respondModerationError(c, err, "Failed to delete review")
+		return
+	}
+
+	log.Println("🗑️ Отзыв удалён модератором:", reviewID)
+	c.JSON(http.StatusOK, gin.H{"message": "Review deleted"})
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"hermes-carpooling/audit"
+	"hermes-carpooling/database"
+	"hermes-carpooling/notifications"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifications — входящие уведомления текущего пользователя
+func GetNotifications(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	rows, err := database.DB.Query(`
+		SELECT id, type, title, COALESCE(body, ''), data, is_read, created_at
+		FROM notifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 100
+	`, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+		return
+	}
+	defer rows.Close()
+
+	result := []gin.H{}
+	unread := 0
+	for rows.Next() {
+		var (
+			id        int
+			nType     string
+			title     string
+			body      string
+			data      []byte
+			isRead    bool
+			createdAt time.Time
+		)
+
+		if err := rows.Scan(&id, &nType, &title, &body, &data, &isRead, &createdAt); err != nil {
+			continue
+		}
+
+		if !isRead {
+			unread++
+		}
+
+		result = append(result, gin.H{
+			"id":        id,
+			"type":      nType,
+			"title":     title,
+			"body":      body,
+			"data":      json.RawMessage(data),
+			"isRead":    isRead,
+			"createdAt": createdAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": result,
+		"unreadCount":   unread,
+	})
+}
+
+// MarkNotificationRead — отметить уведомление как прочитанное
+func MarkNotificationRead(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	notificationID := c.Param("id")
+
+	result, err := database.DB.Exec(`
+		UPDATE notifications SET is_read = TRUE WHERE id = $1 AND user_id = $2
+	`, notificationID, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// MarkAllNotificationsRead — отметить все уведомления прочитанными
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	_, err := database.DB.Exec(`
+		UPDATE notifications SET is_read = TRUE WHERE user_id = $1 AND NOT is_read
+	`, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All notifications marked as read"})
+}
+
+// GetUnreadNotificationsCount — число непрочитанных уведомлений
+func GetUnreadNotificationsCount(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var unread int
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND NOT is_read
+	`, userID).Scan(&unread)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unreadCount": unread})
+}
+
+// GetNotificationPreferences — настройки каналов уведомлений и язык
+func GetNotificationPreferences(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	prefs, err := notifications.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get preferences"})
+		return
+	}
+
+	var locale string
+	database.DB.QueryRow(`SELECT COALESCE(locale, 'ru') FROM users WHERE id = $1`, userID).Scan(&locale)
+
+	c.JSON(http.StatusOK, gin.H{
+		"locale":      locale,
+		"defaults":    notifications.DefaultChannels,
+		"preferences": prefs,
+	})
+}
+
+// UpdateNotificationPreferences — изменить настройки каналов и язык уведомлений
+func UpdateNotificationPreferences(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	var req struct {
+		Locale      string                     `json:"locale" binding:"omitempty,oneof=ru en"`
+		Preferences []notifications.Preference `json:"preferences" binding:"dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Locale != "" {
+		if _, err := audit.Exec(auditActor(c), `UPDATE users SET locale = $1 WHERE id = $2`, req.Locale, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update locale"})
+			return
+		}
+	}
+
+	for _, p := range req.Preferences {
+		if err := notifications.SetPreference(userID, p); err != nil {
+			log.Println("❌ Ошибка сохранения настроек уведомлений:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification preferences updated"})
+}
+
+// notifyBooking уведомляет пользователя о событии бронирования
+func notifyBooking(userID int, notificationType string, bookingID int) error {
+	data := map[string]interface{}{"bookingId": bookingID}
+
+	var tripID, seatsBooked, totalPrice int
+	var fromCity, toCity, passengerName string
+	err := database.DB.QueryRow(`
+		SELECT b.trip_id, b.seats_booked, b.total_price, t.from_city, t.to_city, u.full_name
+		FROM bookings b
+		JOIN trips t ON b.trip_id = t.id
+		JOIN users u ON b.passenger_id = u.id
+		WHERE b.id = $1
+	`, bookingID).Scan(&tripID, &seatsBooked, &totalPrice, &fromCity, &toCity, &passengerName)
+
+	if err != nil {
+		log.Println("⚠️ Уведомление о бронировании: не удалось загрузить данные:", err)
+		return err
+	}
+
+	data["tripId"] = tripID
+	data["seatsBooked"] = seatsBooked
+	data["totalPrice"] = totalPrice
+	data["fromCity"] = fromCity
+	data["toCity"] = toCity
+	data["passengerName"] = passengerName
+
+	notifications.Send(notifications.Notification{UserID: userID, Type: notificationType, Data: data})
+	return nil
+}
+
+// notifyTripCancelled уведомляет пассажиров об отмене поездки
+func notifyTripCancelled(tripID int, passengerIDs []int) error {
+	var fromCity, toCity string
+	err := database.DB.QueryRow(`
+		SELECT from_city, to_city FROM trips WHERE id = $1
+	`, tripID).Scan(&fromCity, &toCity)
+
+	if err != nil {
+		log.Println("⚠️ Уведомление об отмене поездки: не удалось загрузить данные:", err)
+		return err
+	}
+
+	for _, passengerID := range passengerIDs {
+		notifications.Send(notifications.Notification{
+			UserID: passengerID,
+			Type:   notifications.TripCancelled,
+			Data:   map[string]interface{}{"tripId": tripID, "fromCity": fromCity, "toCity": toCity},
+		})
+	}
+	return nil
+}
+
+// notifyReview уведомляет пользователя об отзыве о нём: о скрытом — без оценки,
+// об опубликованном — с оценкой
+func notifyReview(notificationType string, targetID, reviewID, tripID, authorID, rating int) {
+	var authorName string
+	database.DB.QueryRow(`SELECT full_name FROM users WHERE id = $1`, authorID).Scan(&authorName)
+
+	data := map[string]interface{}{
+		"reviewId":   reviewID,
+		"tripId":     tripID,
+		"authorName": authorName,
+	}
+	if notificationType == notifications.ReviewReceived {
+		data["rating"] = rating
+	}
+
+	notifications.Send(notifications.Notification{
+		UserID: targetID,
+		Type:   notificationType,
+		Data:   data,
+	})
+}
+
+// notifyReviewReply уведомляет автора отзыва об ответе адресата
+func notifyReviewReply(authorID, reviewID, targetID int) {
+	var targetName string
+	database.DB.QueryRow(`SELECT full_name FROM users WHERE id = $1`, targetID).Scan(&targetName)
+
+	notifications.Send(notifications.Notification{
+		UserID: authorID,
+		Type:   notifications.ReviewReply,
+		Data: map[string]interface{}{
+			"reviewId":   reviewID,
+			"targetName": targetName,
+		},
+	})
+}
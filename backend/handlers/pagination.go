@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parsePagination читает ?limit и ?offset; некорректные значения заменяются значениями по умолчанию
+func parsePagination(c *gin.Context, defaultLimit, maxLimit int) (limit, offset int) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 || limit > maxLimit {
+		limit = defaultLimit
+	}
+	offset, err = strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
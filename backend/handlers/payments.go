@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"hermes-carpooling/database"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyPayouts — выплаты водителю по завершённым поездкам
+func GetMyPayouts(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	rows, err := database.DB.Query(`
+		SELECT p.id, p.trip_id, t.from_city, t.to_city, t.departure_at,
+		       p.gross_amount, p.fee, p.penalty, p.amount, p.status, p.paid_at, p.created_at
+		FROM payouts p
+		JOIN trips t ON p.trip_id = t.id
+		WHERE p.driver_id = $1
+		ORDER BY p.created_at DESC
+	`, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get payouts"})
+		return
+	}
+	defer rows.Close()
+
+	payouts := []gin.H{}
+	for rows.Next() {
+		var (
+			id          int
+			tripID      int
+			fromCity    string
+			toCity      string
+			departureAt time.Time
+			gross       int
+			fee         int
+			penalty     int
+			amount      int
+			status      string
+			paidAt      *time.Time
+			createdAt   time.Time
+		)
+
+		err := rows.Scan(&id, &tripID, &fromCity, &toCity, &departureAt,
+			&gross, &fee, &penalty, &amount, &status, &paidAt, &createdAt)
+		if err != nil {
+			continue
+		}
+
+		payouts = append(payouts, gin.H{
+			"id":          id,
+			"tripId":      tripID,
+			"fromCity":    fromCity,
+			"toCity":      toCity,
+			"departureAt": departureAt.UTC(),
+			"grossAmount": gross,
+			"fee":         fee,
+			"penalty":     penalty,
+			"amount":      amount,
+			"status":      status,
+			"paidAt":      paidAt,
+			"createdAt":   createdAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, payouts)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"hermes-carpooling/admin"
+	"hermes-carpooling/database"
+	"hermes-carpooling/reviews"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Пороги значков публичного профиля
+const (
+	experiencedDriverTrips = 10
+	topRatedScore          = 4.5
+	topRatedMinReviews     = 5
+)
+
+// GetPublicProfile — публичный профиль пользователя для попутчиков.
+// Контакты (email, телефон), госномер машины и история поездок не раскрываются;
+// профили заблокированных и приостановленных аккаунтов не показываются.
+func GetPublicProfile(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	restriction, err := admin.AccountRestriction(userID)
+	if err == admin.ErrUserNotFound || (err == nil && restriction != nil) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		log.Println("❌ Ошибка получения публичного профиля:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+		return
+	}
+
+	var (
+		fullName, role, avatarURL             string
+		carBrand, carModel, carColor          string
+		carYear                               int
+		rating, ratingScore                   float64
+		reviewsCount, tripsDriven, tripsTaken int
+		isVerified                            bool
+		createdAt                             time.Time
+	)
+	err = database.DB.QueryRow(`
+		SELECT u.full_name, u.role, COALESCE(u.avatar_url, ''), COALESCE(u.is_verified, FALSE),
+		       COALESCE(u.car_brand, ''), COALESCE(u.car_model, ''), COALESCE(u.car_year, 0), COALESCE(u.car_color, ''),
+		       COALESCE(u.rating, 0), COALESCE(u.rating_score, $2), COALESCE(u.reviews_count, 0), u.created_at,
+		       (SELECT COUNT(*) FROM trips WHERE driver_id = u.id AND status = 'completed'),
+		       (SELECT COUNT(*) FROM bookings b JOIN trips t ON b.trip_id = t.id
+		        WHERE b.passenger_id = u.id AND b.status = 'confirmed' AND t.status = 'completed')
+		FROM users u WHERE u.id = $1
+	`, userID, reviews.Rating.PriorMean).Scan(&fullName, &role, &avatarURL, &isVerified,
+		&carBrand, &carModel, &carYear, &carColor,
+		&rating, &ratingScore, &reviewsCount, &createdAt,
+		&tripsDriven, &tripsTaken)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		log.Println("❌ Ошибка получения публичного профиля:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+		return
+	}
+
+	summary, err := reviews.Summarize(userID, "")
+	if err != nil {
+		log.Println("❌ Ошибка сводки отзывов:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+		return
+	}
+	details, err := reviews.Details(userID)
+	if err != nil {
+		log.Println("❌ Ошибка статистики отзывов:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+		return
+	}
+	recent, _, err := reviews.List(reviews.ListFilter{TargetID: userID, Sort: "newest", Limit: 5})
+	if err != nil {
+		log.Println("❌ Ошибка получения отзывов:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get profile"})
+		return
+	}
+	recentReviews := make([]reviews.PublicItem, len(recent))
+	for i, item := range recent {
+		recentReviews[i] = item.Public()
+	}
+
+	var vehicle gin.H
+	if carBrand != "" || carModel != "" {
+		vehicle = gin.H{
+			"brand": carBrand,
+			"model": carModel,
+			"year":  carYear,
+			"color": carColor,
+		}
+	}
+
+	badges := []string{}
+	if isVerified {
+		badges = append(badges, "verified")
+	}
+	if vehicle != nil {
+		badges = append(badges, "vehicle")
+	}
+	if tripsDriven >= experiencedDriverTrips {
+		badges = append(badges, "experiencedDriver")
+	}
+	if ratingScore >= topRatedScore && reviewsCount >= topRatedMinReviews {
+		badges = append(badges, "topRated")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":          userID,
+		"fullName":    fullName,
+		"role":        role,
+		"avatarUrl":   avatarURL,
+		"isVerified":  isVerified,
+		"badges":      badges,
+		"memberSince": createdAt.Format("2006-01"),
+		"tripsDriven": tripsDriven,
+		"tripsTaken":  tripsTaken,
+		"vehicle":     vehicle,
+		"rating": gin.H{
+			"average":      rating,
+			"score":        ratingScore,
+			"reviewsCount": reviewsCount,
+			"summary":      summary,
+			"criteria":     details.Criteria,
+			"tags":         details.Tags,
+		},
+		"recentReviews": recentReviews,
+	})
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"hermes-carpooling/database"
+	"hermes-carpooling/promotions"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckPromoCode — проверить промокод и рассчитать скидку для поездки до бронирования
+func CheckPromoCode(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	var req struct {
+		Code        string `json:"code" binding:"required"`
+		TripID      int    `json:"tripId" binding:"required"`
+		SeatsBooked int    `json:"seatsBooked" binding:"required,min=1,max=8"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var price int
+	err := database.DB.QueryRow(`SELECT price FROM trips WHERE id = $1`, req.TripID).Scan(&price)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		return
+	}
+
+	promo, err := promotions.Check(database.DB, req.Code, userID)
+	if err != nil {
+		if promotions.IsUserError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			log.Println("❌ Ошибка проверки промокода:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check promo code"})
+		}
+		return
+	}
+
+	subtotal := price * req.SeatsBooked
+	discount := promo.Discount(subtotal)
+	c.JSON(http.StatusOK, gin.H{
+		"code":       promo.Code,
+		"subtotal":   subtotal,
+		"discount":   discount,
+		"totalPrice": subtotal - discount,
+	})
+}
+
+// GetWallet — баланс бонусного кошелька и история операций
+func GetWallet(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	balance, err := promotions.Balance(database.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get wallet"})
+		return
+	}
+
+	history, err := promotions.History(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get wallet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"balance":      balance,
+		"transactions": history,
+	})
+}
+
+// GetReferrals — реферальный код пользователя и начисленные бонусы
+func GetReferrals(c *gin.Context) {
+	stats, err := promotions.Stats(c.GetInt("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get referrals"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"hermes-carpooling/reports"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const reportDateLayout = "2006-01-02"
+
+// parseReportRange читает ?from, ?to (YYYY-MM-DD, включительно) и ?period.
+// По умолчанию — последние 12 месяцев по месяцам.
+func parseReportRange(c *gin.Context) (reports.Range, bool) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	r := reports.Range{
+		From:   today.AddDate(-1, 0, 1),
+		To:     today.AddDate(0, 0, 1),
+		Period: c.DefaultQuery("period", "month"),
+	}
+
+	if !reports.ValidPeriod(r.Period) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period", "periods": reports.Periods})
+		return r, false
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(reportDateLayout, from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return r, false
+		}
+		r.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(reportDateLayout, to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return r, false
+		}
+		r.To = t.AddDate(0, 0, 1)
+	}
+
+	if !r.From.Before(r.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return r, false
+	}
+	return r, true
+}
+
+// sendCSV отдаёт CSV-файл как вложение
+func sendCSV(c *gin.Context, name string, r reports.Range, write func(*bytes.Buffer) error) {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export report"})
+		return
+	}
+	filename := fmt.Sprintf("%s_%s_%s.csv", name, r.From.Format(reportDateLayout), r.To.AddDate(0, 0, -1).Format(reportDateLayout))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// GetEarningsReport — заработок водителя по периодам (?format=csv для выгрузки)
+func GetEarningsReport(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	r, ok := parseReportRange(c)
+	if !ok {
+		return
+	}
+
+	rows, total, err := reports.Earnings(userID, r)
+	if err != nil {
+		log.Println("❌ Ошибка отчёта о заработке:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		sendCSV(c, "earnings", r, func(buf *bytes.Buffer) error {
+			return reports.WriteEarningsCSV(buf, rows, total)
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"period": r.Period,
+		"from":   r.From.Format(reportDateLayout),
+		"to":     r.To.AddDate(0, 0, -1).Format(reportDateLayout),
+		"rows":   rows,
+		"total":  total,
+	})
+}
+
+// GetSpendingReport — расходы пассажира по периодам (?format=csv для выгрузки)
+func GetSpendingReport(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	r, ok := parseReportRange(c)
+	if !ok {
+		return
+	}
+
+	rows, total, err := reports.Spending(userID, r)
+	if err != nil {
+		log.Println("❌ Ошибка отчёта о расходах:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report"})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		sendCSV(c, "spending", r, func(buf *bytes.Buffer) error {
+			return reports.WriteSpendingCSV(buf, rows, total)
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"period": r.Period,
+		"from":   r.From.Format(reportDateLayout),
+		"to":     r.To.AddDate(0, 0, -1).Format(reportDateLayout),
+		"rows":   rows,
+		"total":  total,
+	})
+}
+
+// GetBookingReceipt — квитанция по бронированию для пассажира или водителя.
+// По умолчанию HTML-страница для печати, ?format=json — данные квитанции.
+func GetBookingReceipt(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	bookingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking ID"})
+		return
+	}
+
+	receipt, ok, err := reports.LoadReceipt(bookingID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	}
+	if err != nil {
+		log.Println("❌ Ошибка формирования квитанции:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build receipt"})
+		return
+	}
+
+	if receipt.PassengerID != userID && receipt.DriverID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Receipt is available only for completed trips"})
+		return
+	}
+
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, receipt)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := reports.WriteReceiptHTML(&buf, receipt); err != nil {
+		log.Println("❌ Ошибка формирования квитанции:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build receipt"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -3,7 +3,12 @@ package handlers
 import (
 	"fmt"
 	"database/sql"
+	"hermes-carpooling/audit"
 	"hermes-carpooling/database"
+	"hermes-carpooling/events"
+	"hermes-carpooling/models"
+	"hermes-carpooling/reviews"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,70 +18,23 @@ import (
 
 // CreateReview — создать отзыв после завершения поездки
 func CreateReview(c *gin.Context) {
-	userID, _ := c.Get("userID")
-
-	var req struct {
-		TripID   int    `json:"tripId" binding:"required"`
-		TargetID int    `json:"targetId" binding:"required"`
-		Rating   int    `json:"rating" binding:"required,min=1,max=5"`
-		Comment  string `json:"comment"`
-	}
-
+	var req models.ReviewCreate
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Проверяем, что пользователь участвовал в этой поездке
-	var count int
-	err := database.DB.QueryRow(`
-		SELECT COUNT(*) FROM bookings b
-		JOIN trips t ON b.trip_id = t.id
-		WHERE b.trip_id = $1 
-		  AND (b.passenger_id = $2 OR t.driver_id = $2)
-		  AND b.status = 'confirmed'
-	`, req.TripID, userID).Scan(&count)
-
-	if err != nil || count == 0 {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You didn't participate in this trip"})
-		return
-	}
-
-	// Проверяем, что отзыв ещё не оставлен
-	err = database.DB.QueryRow(`
-		SELECT COUNT(*) FROM reviews 
-		WHERE trip_id = $1 AND author_id = $2 AND target_id = $3
-	`, req.TripID, userID, req.TargetID).Scan(&count)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check review"})
-		return
-	}
-
-	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Review already exists"})
-		return
-	}
-
-	// Создаём отзыв
-	var reviewID int
-	err = database.DB.QueryRow(`
-		INSERT INTO reviews (trip_id, author_id, target_id, rating, comment)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-	`, req.TripID, userID, req.TargetID, req.Rating, req.Comment).Scan(&reviewID)
-
+	// Создаём отзыв, если автор и адресат ехали вместе; рейтинг пересчитает подписчик ReviewPublished
+	reviewID, err := insertReview(auditActor(c), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
+		respondReviewError(c, err)
 		return
 	}
 
-	// Обновляем рейтинг пользователя
-	updateUserRating(req.TargetID)
-
 	c.JSON(http.StatusCreated, gin.H{
-		"message":  "Review created successfully",
-		"reviewId": reviewID,
+		"message":   "Review created successfully",
+		"reviewId":  reviewID,
+		"published": reviewPublished(reviewID),
 	})
 }
 
@@ -85,15 +43,21 @@ func CheckExistingReview(c *gin.Context) {
 	tripID := c.Param("tripId")
 	userID, _ := c.Get("userID")
 
+	targetID, _ := strconv.Atoi(c.Query("targetId"))
+
 	var reviewID int
 	var rating int
 	var comment sql.NullString
 
+	// Водитель оценивает каждого пассажира отдельно — ?targetId уточняет, о ком отзыв
 	err := database.DB.QueryRow(`
 		SELECT id, rating, comment 
 		FROM reviews 
 		WHERE trip_id = $1 AND author_id = $2
-	`, tripID, userID).Scan(&reviewID, &rating, &comment)
+		  AND ($3 = 0 OR target_id = $3)
+		ORDER BY id
+		LIMIT 1
+	`, tripID, userID, targetID).Scan(&reviewID, &rating, &comment)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusOK, gin.H{"exists": false})
@@ -133,9 +97,11 @@ func UpdateReview(c *gin.Context) {
 	// Проверяем, что отзыв принадлежит текущему пользователю
 	var authorID int
 	var targetID int
+	var tripID int
+	var published bool
 	err := database.DB.QueryRow(`
-		SELECT author_id, target_id FROM reviews WHERE id = $1
-	`, reviewID).Scan(&authorID, &targetID)
+		SELECT author_id, target_id, trip_id, published_at IS NOT NULL FROM reviews WHERE id = $1
+	`, reviewID).Scan(&authorID, &targetID, &tripID, &published)
 
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
@@ -152,238 +118,284 @@ func UpdateReview(c *gin.Context) {
 		return
 	}
 
-	// Обновляем отзыв
-	_, err = database.DB.Exec(`
+	// Изменить отзыв можно, пока он скрыт: после публикации автор уже видел встречный отзыв
+	if published {
+		respondReviewError(c, reviews.ErrPublished)
+		return
+	}
+	if err := reviews.CheckWindow(database.DB, tripID); err != nil {
+		respondReviewError(c, err)
+		return
+	}
+
+	tx, err := audit.Begin(auditActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Обновляем отзыв, только если его не опубликовали, пока шла проверка:
+	// встречный отзыв мог прийти параллельно
+	result, err := tx.Exec(`
 		UPDATE reviews 
 		SET rating = $1, comment = $2 
-		WHERE id = $3
+		WHERE id = $3 AND published_at IS NULL
 	`, req.Rating, req.Comment, reviewID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
 		return
 	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		respondReviewError(c, reviews.ErrPublished)
+		return
+	}
+
+	// Рейтинг целевого пользователя пересчитает подписчик ReviewUpdated
+	reviewIDInt, _ := strconv.Atoi(reviewID)
+	err = events.Record(tx, events.ReviewUpdated, "review", reviewIDInt, events.ReviewPayload{
+		ReviewID: reviewIDInt,
+		TripID:   tripID,
+		AuthorID: authorID,
+		TargetID: targetID,
+		Rating:   req.Rating,
+	})
 
-	// Обновляем рейтинг целевого пользователя
-	updateUserRating(targetID)
+	if err != nil {
+		log.Println("❌ Ошибка записи события:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+	events.Wake()
 
 	c.JSON(http.StatusOK, gin.H{"message": "Review updated successfully"})
 }
 
 // GetUserReviews — получить отзывы о пользователе
 func GetUserReviews(c *gin.Context) {
-	userIDStr := c.Param("id")
-	userID, err := strconv.Atoi(userIDStr)
+	userID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT r.id, r.rating, r.comment, r.created_at,
-		       u.full_name as author_name, COALESCE(u.avatar_url, '') as avatar_url,
-		       t.from_city, t.to_city, t.trip_date
-		FROM reviews r
-		JOIN users u ON r.author_id = u.id
-		JOIN trips t ON r.trip_id = t.id
-		WHERE r.target_id = $1
-		ORDER BY r.created_at DESC
-	`, userID)
+	listReviews(c, reviews.ListFilter{TargetID: userID}, false)
+}
+
+// GetMyReviews — получить отзывы о текущем пользователе
+func GetMyReviews(c *gin.Context) {
+	listReviews(c, reviews.ListFilter{TargetID: c.GetInt("userID")}, true)
+}
 
+// insertReview проверяет право автора на отзыв, оценки и метки для роли адресата
+// и создаёт отзыв вместе с событием ReviewCreated в одной транзакции
+func insertReview(author audit.Actor, req models.ReviewCreate) (int, error) {
+	authorID := author.UserID
+	tx, err := audit.Begin(author)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reviews"})
-		return
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	targetRole, err := reviews.CheckEligibility(tx, req.TripID, authorID, req.TargetID)
+	if err != nil {
+		return 0, err
+	}
+	if err := req.ValidateDetails(targetRole); err != nil {
+		return 0, fmt.Errorf("%w: %v", reviews.ErrInvalidDetails, err)
+	}
+
+	var reviewID int
+	err = tx.QueryRow(`
+		INSERT INTO reviews (trip_id, author_id, target_id, rating, comment, scores, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, req.TripID, authorID, req.TargetID, req.Rating, req.Comment, req.Scores, req.Tags).Scan(&reviewID)
+	if err != nil {
+		return 0, err
 	}
-	defer rows.Close()
-
-	var reviews []gin.H
-	for rows.Next() {
-		var (
-			id         int
-			rating     int
-			comment    string
-			createdAt  time.Time
-			authorName string
-			avatarURL  string
-			fromCity   string
-			toCity     string
-			tripDate   time.Time
-		)
-
-		err := rows.Scan(&id, &rating, &comment, &createdAt,
-			&authorName, &avatarURL, &fromCity, &toCity, &tripDate)
-		if err != nil {
-			continue
-		}
 
-		reviews = append(reviews, gin.H{
-			"id":           id,
-			"rating":       rating,
-			"comment":      comment,
-			"createdAt":    createdAt,
-			"authorName":   authorName,
-			"authorAvatar": avatarURL,
-			"fromCity":     fromCity,
-			"toCity":       toCity,
-			"tripDate":     tripDate.Format("2006-01-02"),
-		})
+	err = events.Record(tx, events.ReviewCreated, "review", reviewID, events.ReviewPayload{
+		ReviewID: reviewID,
+		TripID:   req.TripID,
+		AuthorID: authorID,
+		TargetID: req.TargetID,
+		Rating:   req.Rating,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Если попутчик уже оставил встречный отзыв, оба становятся видны
+	if _, err := reviews.PublishMutual(tx, reviewID); err != nil {
+		return 0, err
 	}
 
-	if reviews == nil {
-		reviews = []gin.H{}
+	if err := tx.Commit(); err != nil {
+		return 0, err
 	}
+	events.Wake()
 
-	c.JSON(http.StatusOK, reviews)
+	return reviewID, nil
 }
 
-// GetMyReviews — получить отзывы о текущем пользователе
-func GetMyReviews(c *gin.Context) {
-	userID, _ := c.Get("userID")
+// GetReviewCatalog — справочник критериев оценки и меток отзывов
+func GetReviewCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"criteria": models.ReviewCriteria,
+		"tags":     models.ReviewTagCatalog,
+	})
+}
 
-	rows, err := database.DB.Query(`
-		SELECT 
-			r.id, r.rating, r.comment, r.created_at,
-			u.full_name as author_name,
-			COALESCE(u.avatar_url, '') as author_avatar,
-			t.from_city, t.to_city, t.trip_date
-		FROM reviews r
-		JOIN users u ON r.author_id = u.id
-		JOIN trips t ON r.trip_id = t.id
-		WHERE r.target_id = $1
-		ORDER BY r.created_at DESC
-	`, userID)
+// GetUserReviewStats — средние оценки по критериям и частые метки пользователя
+func GetUserReviewStats(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
 
+	stats, err := reviews.Details(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reviews"})
+		log.Println("❌ Ошибка получения статистики отзывов:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get review stats"})
 		return
 	}
-	defer rows.Close()
-
-	var reviews []gin.H
-	for rows.Next() {
-		var (
-			id           int
-			rating       int
-			comment      string
-			createdAt    time.Time
-			authorName   string
-			authorAvatar string
-			fromCity     string
-			toCity       string
-			tripDate     time.Time
-		)
-
-		err := rows.Scan(&id, &rating, &comment, &createdAt, &authorName, &authorAvatar, &fromCity, &toCity, &tripDate)
-		if err != nil {
-			continue
-		}
 
-		// ДОБАВЛЯЕМ TIMESTAMP К АВАТАРКЕ
-		if authorAvatar != "" {
-			authorAvatar = fmt.Sprintf("%s?t=%d", authorAvatar, time.Now().Unix())
-		}
+	c.JSON(http.StatusOK, stats)
+}
 
-		reviews = append(reviews, gin.H{
-			"id":           id,
-			"rating":       rating,
-			"comment":      comment,
-			"createdAt":    createdAt,
-			"authorName":   authorName,
-			"authorAvatar": authorAvatar,
-			"fromCity":     fromCity,
-			"toCity":       toCity,
-			"tripDate":     tripDate.Format("2006-01-02"),
-		})
+// respondReviewError отвечает клиенту по ошибке проверки или создания отзыва
+func respondReviewError(c *gin.Context, err error) {
+	switch {
+	case err == reviews.ErrTripNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err == reviews.ErrNotParticipant || err == reviews.ErrNotTripPartner:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case reviews.IsUserError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("❌ Ошибка создания отзыва:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
 	}
+}
 
-	if reviews == nil {
-		reviews = []gin.H{}
+// GetPendingReviews — попутчики, о которых ещё можно оставить отзыв
+func GetPendingReviews(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	pending, err := reviews.Pending(userID)
+	if err != nil {
+		log.Println("❌ Ошибка получения ожидающих отзывов:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get pending reviews"})
+		return
 	}
 
-	c.JSON(http.StatusOK, reviews)
+	c.JSON(http.StatusOK, gin.H{
+		"windowDays": reviews.WindowDays,
+		"pending":    pending,
+	})
 }
 
-
-// updateUserRating — обновление рейтинга пользователя
-func updateUserRating(userID int) {
-	database.DB.Exec(`
-		UPDATE users SET 
-			rating = (SELECT COALESCE(AVG(rating), 0) FROM reviews WHERE target_id = $1),
-			reviews_count = (SELECT COUNT(*) FROM reviews WHERE target_id = $1)
-		WHERE id = $1
-	`, userID)
+// reviewPublished — опубликован ли отзыв
+func reviewPublished(reviewID int) bool {
+	var published bool
+	database.DB.QueryRow(`
+		SELECT published_at IS NOT NULL FROM reviews WHERE id = $1
+	`, reviewID).Scan(&published)
+	return published
 }
 
 // GetMyWrittenReviews — получить отзывы, которые я написал (как автор)
 func GetMyWrittenReviews(c *gin.Context) {
-	userID, _ := c.Get("userID")
+	listReviews(c, reviews.ListFilter{AuthorID: c.GetInt("userID")}, true)
+}
 
-	rows, err := database.DB.Query(`
-		SELECT 
-			r.id, r.trip_id, r.rating, r.comment, r.created_at,
-			u.id as target_id,
-			u.full_name as target_name,
-			COALESCE(u.avatar_url, '') as target_avatar,
-			t.from_city, t.to_city, t.trip_date
-		FROM reviews r
-		JOIN users u ON r.target_id = u.id
-		JOIN trips t ON r.trip_id = t.id
-		WHERE r.author_id = $1
-		ORDER BY r.created_at DESC
-	`, userID)
+// legacyReviewsLimit — потолок списка для запросов без пагинации
+const legacyReviewsLimit = 1000
+
+// listReviews — общая выдача списков отзывов: фильтры ?rating и ?role
+// (driver или passenger — в какой роли был адресат), сортировка ?sort
+// (newest, oldest, highest, lowest), страница ?limit/?offset.
+// Для отзывов о пользователе добавляется сводка с распределением оценок.
+// Без ?limit и ?offset отдаётся прежний ответ — массив отзывов без сводки.
+func listReviews(c *gin.Context, filter reviews.ListFilter, bustAvatarCache bool) {
+	filter.Sort = c.DefaultQuery("sort", "newest")
+	if !reviews.ValidSort(filter.Sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected newest, oldest, highest or lowest"})
+		return
+	}
 
+	if rating := c.Query("rating"); rating != "" {
+		value, err := strconv.Atoi(rating)
+		if err != nil || value < 1 || value > 5 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rating, expected 1-5"})
+			return
+		}
+		filter.Rating = value
+	}
+
+	filter.Role = c.Query("role")
+	if filter.Role != "" && filter.Role != models.ReviewTargetDriver && filter.Role != models.ReviewTargetPassenger {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role, expected driver or passenger"})
+		return
+	}
+
+	// Старые клиенты не передают limit/offset и ждут весь список массивом
+	legacy := c.Query("limit") == "" && c.Query("offset") == ""
+	if legacy {
+		filter.Limit, filter.Offset = legacyReviewsLimit, 0
+	} else {
+		filter.Limit, filter.Offset = parsePagination(c, 20, 100)
+	}
+
+	items, total, err := reviews.List(filter)
 	if err != nil {
+		log.Println("❌ Ошибка получения отзывов:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reviews"})
 		return
 	}
-	defer rows.Close()
-
-	var reviews []gin.H
-	for rows.Next() {
-		var (
-			id           int
-			tripID       int
-			rating       int
-			comment      string
-			createdAt    time.Time
-			targetID     int
-			targetName   string
-			targetAvatar string
-			fromCity     string
-			toCity       string
-			tripDate     time.Time
-		)
-
-		err := rows.Scan(&id, &tripID, &rating, &comment, &createdAt,
-			&targetID, &targetName, &targetAvatar, &fromCity, &toCity, &tripDate)
-		if err != nil {
-			continue
-		}
 
-		// ДОБАВЛЯЕМ TIMESTAMP К АВАТАРКЕ
-		if targetAvatar != "" {
-			targetAvatar = fmt.Sprintf("%s?t=%d", targetAvatar, time.Now().Unix())
+	// ДОБАВЛЯЕМ TIMESTAMP К АВАТАРКАМ
+	if bustAvatarCache {
+		now := time.Now().Unix()
+		for i := range items {
+			if items[i].AuthorAvatar != "" {
+				items[i].AuthorAvatar = fmt.Sprintf("%s?t=%d", items[i].AuthorAvatar, now)
+			}
+			if items[i].TargetAvatar != "" {
+				items[i].TargetAvatar = fmt.Sprintf("%s?t=%d", items[i].TargetAvatar, now)
+			}
 		}
+	}
 
-		reviews = append(reviews, gin.H{
-			"id":           id,
-			"tripId":       tripID,
-			"rating":       rating,
-			"comment":      comment,
-			"createdAt":    createdAt,
-			"targetId":     targetID,
-			"targetName":   targetName,
-			"targetAvatar": targetAvatar,
-			"fromCity":     fromCity,
-			"toCity":       toCity,
-			"tripDate":     tripDate.Format("2006-01-02"),
-		})
+	if legacy {
+		c.JSON(http.StatusOK, items)
+		return
 	}
 
-	if reviews == nil {
-		reviews = []gin.H{}
+	response := gin.H{
+		"reviews": items,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
 	}
 
-	c.JSON(http.StatusOK, reviews)
-}
+	if filter.TargetID != 0 {
+		summary, err := reviews.Summarize(filter.TargetID, filter.Role)
+		if err != nil {
+			log.Println("❌ Ошибка сводки отзывов:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reviews"})
+			return
+		}
+		response["summary"] = summary
+	}
 
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"hermes-carpooling/safety"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondSafetyError отвечает клиенту по ошибке блокировки или жалобы
+func respondSafetyError(c *gin.Context, err error, message string) {
+	switch {
+	case err == safety.ErrUserNotFound || err == safety.ErrReportNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err == safety.ErrReportExists:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err == safety.ErrInvalidCategory:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "categories": safety.ReportCategories})
+	case safety.IsUserError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("❌ Ошибка блокировки или жалобы:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// BlockUser — заблокировать пользователя: он не сможет бронировать мои поездки,
+// а его поездки пропадут из моего поиска
+func BlockUser(c *gin.Context) {
+	targetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := safety.Block(c.GetInt("userID"), targetID); err != nil {
+		respondSafetyError(c, err, "Failed to block user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked"})
+}
+
+// UnblockUser — разблокировать пользователя
+func UnblockUser(c *gin.Context) {
+	targetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := safety.Unblock(c.GetInt("userID"), targetID); err != nil {
+		respondSafetyError(c, err, "Failed to unblock user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+}
+
+// GetBlockedUsers — мой чёрный список
+func GetBlockedUsers(c *gin.Context) {
+	blocked, err := safety.Blocked(c.GetInt("userID"))
+	if err != nil {
+		log.Println("❌ Ошибка получения чёрного списка:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get blocked users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, blocked)
+}
+
+// ReportUser — пожаловаться на пользователя
+func ReportUser(c *gin.Context) {
+	targetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Category    string `json:"category" binding:"required"`
+		Description string `json:"description" binding:"max=2000"`
+		TripID      *int   `json:"tripId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reportID, err := safety.Report(c.GetInt("userID"), targetID, req.Category, req.Description, req.TripID)
+	if err != nil {
+		respondSafetyError(c, err, "Failed to report user")
+		return
+	}
+
+	log.Printf("🚩 Жалоба #%d на пользователя %d", reportID, targetID)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Report submitted",
+		"reportId": reportID,
+	})
+}
+
+// GetUserReportCategories — категории жалоб на пользователя
+func GetUserReportCategories(c *gin.Context) {
+	c.JSON(http.StatusOK, safety.ReportCategories)
+}
+
+// GetUserReports — очередь жалоб на пользователей (?status=open|resolved|dismissed)
+func GetUserReports(c *gin.Context) {
+	status := c.DefaultQuery("status", safety.ReportOpen)
+	if status != safety.ReportOpen && status != safety.ReportResolved && status != safety.ReportDismissed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	limit, offset := parsePagination(c, 50, 200)
+	reports, err := safety.Reports(status, limit, offset)
+	if err != nil {
+		log.Println("❌ Ошибка получения жалоб на пользователей:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// ResolveUserReport — закрыть жалобу на пользователя (администратор)
+func ResolveUserReport(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	var req struct {
+		Status     string `json:"status" binding:"required,oneof=resolved dismissed"`
+		Resolution string `json:"resolution" binding:"max=2000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := safety.ResolveReport(reportID, c.GetInt("userID"), req.Status, req.Resolution); err != nil {
+		respondSafetyError(c, err, "Failed to resolve report")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report " + req.Status})
+}
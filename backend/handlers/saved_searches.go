@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"fmt"
+	"hermes-carpooling/database"
+	"hermes-carpooling/models"
+	"hermes-carpooling/notifications"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Дайджест по сохранённым поискам отправляется не чаще раза в сутки
+const savedSearchDigestPeriod = 24 * time.Hour
+
+// CreateSavedSearch — сохранить поиск для уведомлений о новых поездках
+func CreateSavedSearch(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req models.SavedSearchCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fromCity := nullIfEmpty(req.FromCity)
+	toCity := nullIfEmpty(req.ToCity)
+	if fromCity == nil && toCity == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fromCity or toCity is required"})
+		return
+	}
+
+	var dateFrom, dateTo *time.Time
+	if req.DateFrom != "" {
+		d, err := time.Parse("2006-01-02", req.DateFrom)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dateFrom format"})
+			return
+		}
+		dateFrom = &d
+	}
+	if req.DateTo != "" {
+		d, err := time.Parse("2006-01-02", req.DateTo)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dateTo format"})
+			return
+		}
+		dateTo = &d
+	}
+	if dateFrom != nil && dateTo != nil && dateTo.Before(*dateFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dateTo must not be before dateFrom"})
+		return
+	}
+
+	var searchID int
+	err := database.DB.QueryRow(`
+		INSERT INTO saved_searches (user_id, from_city, to_city, date_from, date_to, max_price, min_seats,
+			no_smoking, animals_allowed, music_allowed, digest)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`, userID, fromCity, toCity, dateFrom, dateTo, req.MaxPrice, req.MinSeats,
+		req.NoSmoking, req.AnimalsAllowed, req.MusicAllowed, req.Digest).Scan(&searchID)
+
+	if err != nil {
+		log.Println("❌ Ошибка сохранения поиска:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save search"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Search saved successfully",
+		"searchId": searchID,
+	})
+}
+
+// GetMySavedSearches — список сохранённых поисков текущего пользователя
+func GetMySavedSearches(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	rows, err := database.DB.Query(`
+		SELECT id, user_id, from_city, to_city, date_from, date_to, max_price, min_seats,
+			no_smoking, animals_allowed, music_allowed, digest, created_at
+		FROM saved_searches
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get saved searches"})
+		return
+	}
+	defer rows.Close()
+
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		var s models.SavedSearch
+		err := rows.Scan(&s.ID, &s.UserID, &s.FromCity, &s.ToCity, &s.DateFrom, &s.DateTo,
+			&s.MaxPrice, &s.MinSeats, &s.NoSmoking, &s.AnimalsAllowed, &s.MusicAllowed,
+			&s.Digest, &s.CreatedAt)
+		if err != nil {
+			continue
+		}
+		searches = append(searches, s)
+	}
+
+	c.JSON(http.StatusOK, searches)
+}
+
+// DeleteSavedSearch — удалить сохранённый поиск
+func DeleteSavedSearch(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	searchID := c.Param("id")
+
+	result, err := database.DB.Exec(`
+		DELETE FROM saved_searches WHERE id = $1 AND user_id = $2
+	`, searchID, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete saved search"})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted successfully"})
+}
+
+// matchSavedSearches находит сохранённые поиски, подходящие под новую поездку,
+// и уведомляет подписчиков. Подписчики с дайджестом получат её позже одним письмом.
+// Ошибка возвращается диспетчеру событий для повторной попытки.
+func matchSavedSearches(tripID int) error {
+	var trip models.Trip
+	err := database.DB.QueryRow(`
+		SELECT id, driver_id, from_city, to_city, trip_date, TO_CHAR(trip_time, 'HH24:MI'),
+			price, available_seats, no_smoking, animals_allowed, music_allowed
+		FROM trips WHERE id = $1
+	`, tripID).Scan(&trip.ID, &trip.DriverID, &trip.FromCity, &trip.ToCity, &trip.TripDate, &trip.TripTime,
+		&trip.Price, &trip.AvailableSeats, &trip.NoSmoking, &trip.AnimalsAllowed, &trip.MusicAllowed)
+
+	if err != nil {
+		log.Println("⚠️ Сохранённые поиски: не удалось загрузить поездку:", err)
+		return err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.user_id, s.digest
+		FROM saved_searches s
+		JOIN trips t ON t.id = $1
+		WHERE s.user_id <> t.driver_id
+		  AND (s.from_city IS NULL OR LOWER(t.from_city) LIKE '%' || LOWER(s.from_city) || '%')
+		  AND (s.to_city IS NULL OR LOWER(t.to_city) LIKE '%' || LOWER(s.to_city) || '%')
+		  AND (s.date_from IS NULL OR t.trip_date >= s.date_from)
+		  AND (s.date_to IS NULL OR t.trip_date <= s.date_to)
+		  AND (s.max_price IS NULL OR t.price <= s.max_price)
+		  AND (s.min_seats IS NULL OR t.available_seats >= s.min_seats)
+		  AND (NOT s.no_smoking OR t.no_smoking)
+		  AND (NOT s.animals_allowed OR t.animals_allowed)
+		  AND (NOT s.music_allowed OR t.music_allowed)
+	`, tripID)
+
+	if err != nil {
+		log.Println("⚠️ Сохранённые поиски: ошибка поиска подписчиков:", err)
+		return err
+	}
+
+	type match struct {
+		searchID int
+		userID   int
+		digest   bool
+	}
+
+	var matches []match
+	for rows.Next() {
+		var m match
+		if err := rows.Scan(&m.searchID, &m.userID, &m.digest); err != nil {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	rows.Close()
+
+	notified := map[int]bool{}
+	for _, m := range matches {
+		_, err := database.DB.Exec(`
+			INSERT INTO saved_search_matches (saved_search_id, trip_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, m.searchID, tripID)
+		if err != nil {
+			log.Println("⚠️ Сохранённые поиски: не удалось записать совпадение:", err)
+			continue
+		}
+
+		// Один пользователь может иметь несколько подходящих поисков — уведомляем один раз
+		if m.digest || notified[m.userID] {
+			continue
+		}
+		notified[m.userID] = true
+
+		notifications.Send(notifications.Notification{
+			UserID: m.userID,
+			Type:   notifications.SavedSearchMatch,
+			Data: map[string]interface{}{
+				"tripId":        tripID,
+				"savedSearchId": m.searchID,
+				"fromCity":      trip.FromCity,
+				"toCity":        trip.ToCity,
+				"tripDate":      trip.TripDate.Format("2006-01-02"),
+				"tripTime":      trip.TripTime,
+				"price":         trip.Price,
+			},
+		})
+
+		database.DB.Exec(`
+			UPDATE saved_search_matches SET notified_at = NOW()
+			WHERE trip_id = $1 AND saved_search_id IN (SELECT id FROM saved_searches WHERE user_id = $2 AND NOT digest)
+		`, tripID, m.userID)
+	}
+
+	if len(matches) > 0 {
+		log.Printf("🔔 Поездка %d совпала с %d сохранёнными поисками", tripID, len(matches))
+	}
+	return nil
+}
+
+// sendSavedSearchDigests отправляет накопившиеся совпадения одним уведомлением
+// пользователям, выбравшим дайджест, если с прошлого дайджеста прошли сутки
+func sendSavedSearchDigests() {
+	rows, err := database.DB.Query(`
+		SELECT s.user_id, COUNT(DISTINCT m.trip_id), ARRAY_AGG(DISTINCT m.trip_id)::TEXT
+		FROM saved_search_matches m
+		JOIN saved_searches s ON s.id = m.saved_search_id
+		JOIN trips t ON t.id = m.trip_id
+		WHERE s.digest AND m.notified_at IS NULL AND t.status = 'active' AND t.departure_at > NOW()
+		  AND (s.last_digest_at IS NULL OR s.last_digest_at < NOW() - $1 * INTERVAL '1 second')
+		GROUP BY s.user_id
+	`, int(savedSearchDigestPeriod.Seconds()))
+
+	if err != nil {
+		log.Println("⚠️ Дайджест сохранённых поисков:", err)
+		return
+	}
+
+	type digest struct {
+		userID  int
+		count   int
+		tripIDs string
+	}
+
+	var digests []digest
+	for rows.Next() {
+		var d digest
+		if err := rows.Scan(&d.userID, &d.count, &d.tripIDs); err != nil {
+			continue
+		}
+		digests = append(digests, d)
+	}
+	rows.Close()
+
+	for _, d := range digests {
+		notifications.Send(notifications.Notification{
+			UserID: d.userID,
+			Type:   notifications.SavedSearchDigest,
+			Data:   map[string]interface{}{"tripIds": parseIntArray(d.tripIDs), "count": d.count},
+		})
+
+		database.DB.Exec(`
+			UPDATE saved_search_matches SET notified_at = NOW()
+			WHERE notified_at IS NULL
+			  AND saved_search_id IN (SELECT id FROM saved_searches WHERE user_id = $1 AND digest)
+		`, d.userID)
+		database.DB.Exec(`
+			UPDATE saved_searches SET last_digest_at = NOW() WHERE user_id = $1 AND digest
+		`, d.userID)
+	}
+}
+
+// StartSavedSearchDigest запускает фоновую отправку дайджестов
+func StartSavedSearchDigest(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sendSavedSearchDigests()
+		}
+	}()
+}
+
+// nullIfEmpty возвращает nil для пустой строки, чтобы в БД записался NULL
+func nullIfEmpty(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// parseIntArray разбирает массив Postgres вида {1,2,3}
+func parseIntArray(s string) []int {
+	var result []int
+	for _, part := range strings.Split(strings.Trim(s, "{}"), ",") {
+		var n int
+		if _, err := fmt.Sscan(part, &n); err == nil {
+			result = append(result, n)
+		}
+	}
+	return result
+}
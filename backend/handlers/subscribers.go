@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"hermes-carpooling/events"
+	"hermes-carpooling/notifications"
+	"hermes-carpooling/payments"
+	"hermes-carpooling/promotions"
+	"hermes-carpooling/realtime"
+	"hermes-carpooling/reviews"
+	"hermes-carpooling/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterEventSubscribers подписывает побочные эффекты (уведомления, realtime,
+// пересчёт рейтинга, сохранённые поиски, вебхуки, платежи, бонусы) на доменные события из outbox
+func RegisterEventSubscribers() {
+	for eventType := range webhooks.EventTypes {
+		events.Subscribe(eventType, "webhooks", webhooks.Enqueue)
+	}
+
+	events.Subscribe(events.TripCreated, "saved_searches", func(e events.Event) error {
+		var p events.TripPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		return matchSavedSearches(p.TripID)
+	})
+
+	events.Subscribe(events.TripCancelled, "realtime", func(e events.Event) error {
+		var p events.TripPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		for _, passengerID := range p.PassengerIDs {
+			realtime.Publish(passengerID, realtime.TripCancelled, gin.H{"tripId": p.TripID})
+		}
+		return nil
+	})
+
+	events.Subscribe(events.TripCancelled, "notifications", func(e events.Event) error {
+		var p events.TripPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		return notifyTripCancelled(p.TripID, p.PassengerIDs)
+	})
+
+	// Деньги списываются после завершения поездки, при отмене блокировка снимается
+	events.Subscribe(events.TripCompleted, "payments", func(e events.Event) error {
+		var p events.TripPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		return payments.CaptureTrip(p.TripID, e.ID)
+	})
+
+	events.Subscribe(events.TripCancelled, "payments", func(e events.Event) error {
+		var p events.TripPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		return payments.VoidTrip(p.TripID, e.ID)
+	})
+
+	// Бонусы, потраченные на отменённые бронирования, возвращаются на кошелёк
+	events.Subscribe(events.TripCancelled, "wallet", func(e events.Event) error {
+		var p events.TripPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		return promotions.RefundTrip(p.TripID)
+	})
+
+	// Удержание, не покрытое оплатой картой, остаётся из бонусов
+	events.Subscribe(events.BookingCancelled, "wallet", func(e events.Event) error {
+		var p events.BookingPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		return promotions.RefundBooking(p.BookingID, p.Fee)
+	})
+
+	// Реферальный бонус начисляется после первой завершённой поездки приглашённого
+	events.Subscribe(events.TripCompleted, "referrals", func(e events.Event) error {
+		var p events.TripPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		for _, userID := range append([]int{p.DriverID}, p.PassengerIDs...) {
+			if err := promotions.RewardReferral(userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	events.Subscribe(events.BookingCreated, "realtime", func(e events.Event) error {
+		var p events.BookingPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		realtime.Publish(p.DriverID, realtime.BookingCreated, gin.H{
+			"bookingId":   p.BookingID,
+			"tripId":      p.TripID,
+			"passengerId": p.PassengerID,
+			"seatsBooked": p.SeatsBooked,
+			"totalPrice":  p.TotalPrice,
+		})
+		return nil
+	})
+
+	events.Subscribe(events.BookingCreated, "notifications", func(e events.Event) error {
+		var p events.BookingPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		return notifyBooking(p.DriverID, notifications.BookingCreated, p.BookingID)
+	})
+
+	bookingStatus := map[string]struct {
+		status           string
+		notificationType string
+	}{
+		events.BookingConfirmed: {"confirmed", notifications.BookingConfirmed},
+		events.BookingCancelled: {"cancelled", notifications.BookingCancelled},
+	}
+	for eventType, s := range bookingStatus {
+		s := s
+		events.Subscribe(eventType, "realtime", func(e events.Event) error {
+			var p events.BookingPayload
+			if err := e.Decode(&p); err != nil {
+				return err
+			}
+			data := gin.H{
+				"bookingId": p.BookingID,
+				"tripId":    p.TripID,
+				"status":    s.status,
+			}
+			if p.CancelledByAdmin {
+				realtime.Publish(p.DriverID, realtime.BookingStatusChanged, data)
+				realtime.Publish(p.PassengerID, realtime.BookingStatusChanged, data)
+				return nil
+			}
+			realtime.Publish(bookingCounterparty(p), realtime.BookingStatusChanged, data)
+			return nil
+		})
+
+		events.Subscribe(eventType, "notifications", func(e events.Event) error {
+			var p events.BookingPayload
+			if err := e.Decode(&p); err != nil {
+				return err
+			}
+			if p.CancelledByAdmin {
+				if err := notifyBooking(p.PassengerID, notifications.BookingCancelledByAdmin, p.BookingID); err != nil {
+					return err
+				}
+				return notifyBooking(p.DriverID, notifications.BookingCancelledByAdmin, p.BookingID)
+			}
+			if p.CancelledBy == p.PassengerID {
+				return notifyBooking(p.DriverID, notifications.BookingWithdrawn, p.BookingID)
+			}
+			return notifyBooking(p.PassengerID, s.notificationType, p.BookingID)
+		})
+	}
+
+	// Удержание по политике отмены списывается, остаток блокировки возвращается пассажиру
+	events.Subscribe(events.BookingCancelled, "payments", func(e events.Event) error {
+		var p events.BookingPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		return payments.SettleCancelledBooking(p.BookingID, p.Fee, e.ID)
+	})
+
+	// В рейтинге учитываются только опубликованные отзывы
+	ratingEvents := []string{
+		events.ReviewPublished, events.ReviewUpdated,
+		events.ReviewHidden, events.ReviewRestored, events.ReviewDeleted,
+	}
+	for _, eventType := range ratingEvents {
+		events.Subscribe(eventType, "rating", func(e events.Event) error {
+			var p events.ReviewPayload
+			if err := e.Decode(&p); err != nil {
+				return err
+			}
+			return reviews.Recompute(p.TargetID)
+		})
+	}
+
+	// Скрытый отзыв — повод оставить встречный; оценка видна только после публикации
+	reviewNotification := map[string]string{
+		events.ReviewCreated:   notifications.ReviewPending,
+		events.ReviewPublished: notifications.ReviewReceived,
+	}
+	for eventType, notificationType := range reviewNotification {
+		notificationType := notificationType
+		events.Subscribe(eventType, "notifications", func(e events.Event) error {
+			var p events.ReviewPayload
+			if err := e.Decode(&p); err != nil {
+				return err
+			}
+			if notificationType == notifications.ReviewPending && reviewPublished(p.ReviewID) {
+				return nil
+			}
+			notifyReview(notificationType, p.TargetID, p.ReviewID, p.TripID, p.AuthorID, p.Rating)
+			return nil
+		})
+	}
+
+	// Автор отзыва узнаёт об ответе адресата
+	events.Subscribe(events.ReviewReplied, "notifications", func(e events.Event) error {
+		var p events.ReviewPayload
+		if err := e.Decode(&p); err != nil {
+			return err
+		}
+		notifyReviewReply(p.AuthorID, p.ReviewID, p.TargetID)
+		return nil
+	})
+}
+
+// bookingCounterparty — кому сообщить об изменении бронирования: пассажиру,
+// если его бронирование обработал водитель, и водителю, если пассажир отменил сам
+func bookingCounterparty(p events.BookingPayload) int {
+	if p.CancelledBy == p.PassengerID {
+		return p.DriverID
+	}
+	return p.PassengerID
+}
@@ -2,11 +2,19 @@ package handlers
 
 import (
 	"database/sql"
+	"hermes-carpooling/audit"
+	"hermes-carpooling/cancellation"
 	"hermes-carpooling/database"
+	"hermes-carpooling/events"
+	"hermes-carpooling/geo"
 	"hermes-carpooling/models"
+	"hermes-carpooling/pricing"
+	"hermes-carpooling/reviews"
+	"hermes-carpooling/safety"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,16 +23,19 @@ import (
 // CreateTrip создает новую поездку
 func CreateTrip(c *gin.Context) {
 	var req struct {
-		FromCity        string `json:"fromCity" binding:"required"`
-		ToCity          string `json:"toCity" binding:"required"`
-		TripDate        string `json:"tripDate" binding:"required"`
-		TripTime        string `json:"tripTime" binding:"required"`
-		Seats           int    `json:"seats" binding:"required"`
-		Price           int    `json:"price" binding:"required"`
-		Description     string `json:"description"`
-		NoSmoking       bool   `json:"noSmoking"`
-		AnimalsAllowed  bool   `json:"animalsAllowed"`
-		MusicAllowed    bool   `json:"musicAllowed"`
+		FromCity        string           `json:"fromCity" binding:"required"`
+		ToCity          string           `json:"toCity" binding:"required"`
+		TripDate        string           `json:"tripDate" binding:"required"`
+		TripTime        string           `json:"tripTime" binding:"required"`
+		Seats           int              `json:"seats" binding:"required"`
+		Price           int              `json:"price" binding:"required"`
+		Description     string           `json:"description"`
+		NoSmoking       bool             `json:"noSmoking"`
+		AnimalsAllowed  bool             `json:"animalsAllowed"`
+		MusicAllowed    bool             `json:"musicAllowed"`
+		Amenities       models.Amenities `json:"amenities"`
+
+		CancellationPolicy string `json:"cancellationPolicy"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -40,7 +51,8 @@ func CreateTrip(c *gin.Context) {
 	}
 
 	var userRole string
-	err := database.DB.QueryRow("SELECT role FROM users WHERE id = $1", userID).Scan(&userRole)
+	var defaultAmenities models.Amenities
+	err := database.DB.QueryRow("SELECT role, default_amenities FROM users WHERE id = $1", userID).Scan(&userRole, &defaultAmenities)
 	if err != nil {
 		log.Println("❌ Ошибка проверки роли:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user role"})
@@ -58,14 +70,81 @@ func CreateTrip(c *gin.Context) {
 		return
 	}
 
+	// Удобства поездки: значения из профиля водителя, переопределённые для этой поездки
+	if err := req.Amenities.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amenities := defaultAmenities.Merge(req.Amenities)
+
+	if req.CancellationPolicy == "" {
+		req.CancellationPolicy = cancellation.DefaultPolicy
+	}
+	if _, ok := cancellation.Find(req.CancellationPolicy); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown cancellation policy"})
+		return
+	}
+
+	// Время отправления указывается по местному времени города отправления
+	timezone := geo.TimezoneFor(req.FromCity)
+	departureAt, err := geo.ParseDeparture(req.TripDate, req.TripTime, timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format"})
+		return
+	}
+
+	if !departureAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Departure time must be in the future"})
+		return
+	}
+
+	// Расстояние и время в пути; для городов вне справочника остаются пустыми
+	var distanceKm *float64
+	var durationMinutes *int
+	var arrivalAt *time.Time
+	route, routeKnown := geo.EstimateRoute(req.FromCity, req.ToCity)
+
+	// Некоммерческая платформа: пассажиры в сумме не могут платить больше расходов на поездку.
+	// Если маршрут не оценить (город вне справочника), действует общий потолок за место.
+	maxPrice := pricing.Config.FallbackMaxPrice
+	if routeKnown {
+		maxPrice = pricing.MaxPriceForRoute(route, req.Seats)
+	}
+	if req.Price > maxPrice {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Price exceeds the cost share limit for this route",
+			"maxPrice": maxPrice,
+		})
+		return
+	}
+
+	if routeKnown {
+		minutes := int(route.Duration.Minutes())
+		arrival := departureAt.Add(route.Duration)
+		distanceKm = &route.DistanceKm
+		durationMinutes = &minutes
+		arrivalAt = &arrival
+	}
+
+	tx, err := audit.Begin(auditActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create trip"})
+		return
+	}
+	defer tx.Rollback()
+
 	var tripID int
-	err = database.DB.QueryRow(`
-		INSERT INTO trips (driver_id, from_city, to_city, trip_date, trip_time, seats, available_seats, 
-			price, description, no_smoking, animals_allowed, music_allowed, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $6, $7, $8, $9, $10, $11, 'active', NOW())
+	err = tx.QueryRow(`
+		INSERT INTO trips (driver_id, from_city, to_city, trip_date, trip_time, departure_at, timezone,
+			distance_km, duration_minutes, arrival_at,
+			seats, available_seats, price, description, no_smoking, animals_allowed, music_allowed, amenities,
+			cancellation_policy, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11, $12, $13, $14, $15, $16, $17, $18, 'active', NOW())
 		RETURNING id
-	`, userID, req.FromCity, req.ToCity, tripDate, req.TripTime, req.Seats, req.Price,
-		req.Description, req.NoSmoking, req.AnimalsAllowed, req.MusicAllowed).Scan(&tripID)
+	`, userID, req.FromCity, req.ToCity, tripDate, req.TripTime, departureAt, timezone,
+		distanceKm, durationMinutes, arrivalAt, req.Seats, req.Price,
+		req.Description, req.NoSmoking, req.AnimalsAllowed, req.MusicAllowed, amenities,
+		req.CancellationPolicy).Scan(&tripID)
 
 	if err != nil {
 		log.Println("❌ Ошибка создания поездки:", err)
@@ -73,7 +152,25 @@ func CreateTrip(c *gin.Context) {
 		return
 	}
 
+	// Подписчиков сохранённых поисков уведомит обработчик TripCreated
+	err = events.Record(tx, events.TripCreated, "trip", tripID, events.TripPayload{
+		TripID:   tripID,
+		DriverID: userID.(int),
+	})
+	if err != nil {
+		log.Println("❌ Ошибка записи события:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create trip"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create trip"})
+		return
+	}
+	events.Wake()
+
 	log.Println("✅ Поездка создана с ID:", tripID)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Trip created successfully",
 		"tripId":  tripID,
@@ -91,22 +188,28 @@ func SearchTrips(c *gin.Context) {
 	// Базовый запрос
 	query := `
 		SELECT 
-			t.id, t.from_city, t.to_city, t.trip_date, t.trip_time,
+			t.id, t.from_city, t.to_city, t.trip_date, t.departure_at, t.timezone,
+			t.distance_km, t.duration_minutes, t.arrival_at,
 			t.seats, t.available_seats, t.price, t.description,
+			t.no_smoking, t.animals_allowed, t.music_allowed, t.amenities,
 			t.driver_id, t.status, t.created_at,
 			u.full_name as driver_name,
 			COALESCE(u.car_brand, '') as car_brand,
 			COALESCE(u.car_model, '') as car_model,
 			COALESCE(u.car_color, '') as car_color,
 			COALESCE(u.car_number, '') as car_number,
-			COALESCE(u.rating, 0) as driver_rating
+			COALESCE(u.rating, 0) as driver_rating,
+			u.reviews_count,
+			COALESCE(u.rating_score, $1) as driver_rating_score
 		FROM trips t
 		JOIN users u ON t.driver_id = u.id
-		WHERE t.status = 'active' AND t.available_seats > 0
+		WHERE t.status = 'active' AND t.available_seats > 0 AND t.departure_at > NOW()
+		  AND (u.account_status = 'active' OR (u.account_status = 'suspended' AND u.suspended_until <= NOW()))
 	`
 
-	args := []interface{}{}
-	argCount := 1
+	// $1 — рейтинг водителя без отзывов
+	args := []interface{}{reviews.Rating.PriorMean}
+	argCount := 2
 
 	// Фильтр по городу отправления (обязательный для поиска)
 	if fromCity != "" {
@@ -129,7 +232,73 @@ func SearchTrips(c *gin.Context) {
 		argCount++
 	}
 
-	query += " ORDER BY t.trip_date ASC, t.trip_time ASC"
+	// Поездки водителей, с которыми пользователь заблокировал друг друга, не показываем
+	if userID := c.GetInt("userID"); userID != 0 {
+		query += " AND " + safety.NotBlockedSQL("t.driver_id", "$"+strconv.Itoa(argCount))
+		args = append(args, userID)
+		argCount++
+	}
+
+	// Фильтры по условиям поездки
+	if c.Query("noSmoking") == "true" {
+		query += " AND t.no_smoking"
+	}
+	if c.Query("animalsAllowed") == "true" {
+		query += " AND t.animals_allowed"
+	}
+	if c.Query("musicAllowed") == "true" {
+		query += " AND t.music_allowed"
+	}
+
+	// Фильтры по удобствам: флаг должен быть включён, уровень — не ниже запрошенного
+	for _, amenity := range models.AmenityCatalog {
+		value := c.Query(amenity.Key)
+		if value == "" {
+			continue
+		}
+
+		switch amenity.Type {
+		case models.AmenityFlag:
+			if value != "true" {
+				continue
+			}
+			query += " AND (t.amenities->>$" + strconv.Itoa(argCount) + ")::boolean IS TRUE"
+			args = append(args, amenity.Key)
+			argCount++
+		case models.AmenityLevel:
+			level := amenity.LevelIndex(value)
+			if level < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid value for " + amenity.Key})
+				return
+			}
+			keyArg := "$" + strconv.Itoa(argCount)
+			args = append(args, amenity.Key)
+			argCount++
+
+			placeholders := []string{}
+			for _, option := range amenity.Options[level:] {
+				placeholders = append(placeholders, "$"+strconv.Itoa(argCount))
+				args = append(args, option)
+				argCount++
+			}
+			query += " AND t.amenities->>" + keyArg + " IN (" + strings.Join(placeholders, ", ") + ")"
+		}
+	}
+
+	// Сортировка: по времени отправления (по умолчанию), по рейтингу водителя или по цене.
+	// По рейтингу ранжируем сглаженной оценкой, а не средним: новичок с одной пятёркой
+	// не обгоняет водителей с долгой хорошей историей.
+	switch c.DefaultQuery("sort", "departure") {
+	case "departure":
+		query += " ORDER BY t.departure_at ASC"
+	case "rating":
+		query += " ORDER BY driver_rating_score DESC, t.departure_at ASC"
+	case "price":
+		query += " ORDER BY t.price ASC, t.departure_at ASC"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected departure, rating or price"})
+		return
+	}
 
 	log.Printf("📊 SQL: %s", query)
 	log.Printf("📊 Args: %v", args)
@@ -146,37 +315,58 @@ func SearchTrips(c *gin.Context) {
 	for rows.Next() {
 		var trip models.Trip
 		var driverName, carBrand, carModel, carColor, carNumber string
-		var driverRating float64
+		var driverRating, driverRatingScore float64
+		var driverReviewsCount int
 
 		err := rows.Scan(
-			&trip.ID, &trip.FromCity, &trip.ToCity, &trip.TripDate, &trip.TripTime,
+			&trip.ID, &trip.FromCity, &trip.ToCity, &trip.TripDate, &trip.DepartureAt, &trip.Timezone,
+			&trip.DistanceKm, &trip.DurationMinutes, &trip.ArrivalAt,
 			&trip.Seats, &trip.AvailableSeats, &trip.Price, &trip.Description,
+			&trip.NoSmoking, &trip.AnimalsAllowed, &trip.MusicAllowed, &trip.Amenities,
 			&trip.DriverID, &trip.Status, &trip.CreatedAt,
 			&driverName, &carBrand, &carModel, &carColor, &carNumber, &driverRating,
+			&driverReviewsCount, &driverRatingScore,
 		)
 		if err != nil {
 			log.Println("❌ Ошибка сканирования поездки:", err)
 			continue
 		}
 
+		local := geo.LocalTime(trip.DepartureAt, trip.Timezone)
+		setArrival(&trip)
+
 		trips = append(trips, map[string]interface{}{
-			"id":             trip.ID,
-			"fromCity":       trip.FromCity,
-			"toCity":         trip.ToCity,
-			"tripDate":       trip.TripDate,
-			"tripTime":       trip.TripTime,
-			"seats":          trip.Seats,
-			"availableSeats": trip.AvailableSeats,
-			"price":          trip.Price,
-			"description":    trip.Description,
-			"driverId":       trip.DriverID,
-			"driverName":     driverName,
-			"carBrand":       carBrand,
-			"carModel":       carModel,
-			"carColor":       carColor,
-			"carNumber":      carNumber,
-			"driverRating":   driverRating,
-			"status":         trip.Status,
+			"id":              trip.ID,
+			"fromCity":        trip.FromCity,
+			"toCity":          trip.ToCity,
+			"tripDate":        trip.TripDate,
+			"tripTime":        local.Format("15:04"),
+			"departureAt":     trip.DepartureAt.UTC(),
+			"departureLocal":  local.Format(time.RFC3339),
+			"timezone":        trip.Timezone,
+			"distanceKm":      trip.DistanceKm,
+			"durationMinutes": trip.DurationMinutes,
+			"duration":        trip.Duration,
+			"arrivalAt":       trip.ArrivalAt,
+			"arrivalLocal":    trip.ArrivalLocal,
+			"seats":           trip.Seats,
+			"availableSeats":  trip.AvailableSeats,
+			"price":           trip.Price,
+			"description":     trip.Description,
+			"noSmoking":       trip.NoSmoking,
+			"animalsAllowed":  trip.AnimalsAllowed,
+			"musicAllowed":    trip.MusicAllowed,
+			"amenities":       trip.Amenities,
+			"driverId":        trip.DriverID,
+			"driverName":      driverName,
+			"carBrand":        carBrand,
+			"carModel":        carModel,
+			"carColor":        carColor,
+			"carNumber":       carNumber,
+			"driverRating":    driverRating,
+			"driverReviews":   driverReviewsCount,
+			"driverScore":     driverRatingScore,
+			"status":          trip.Status,
 		})
 	}
 
@@ -196,18 +386,19 @@ func GetTrip(c *gin.Context) {
 	err := database.DB.QueryRow(`
 		SELECT 
 			t.id, t.driver_id, t.from_city, t.to_city, t.trip_date,
-			TO_CHAR(t.trip_time, 'HH24:MI') as trip_time,
+			t.departure_at, t.timezone, t.distance_km, t.duration_minutes, t.arrival_at,
 			t.price, t.seats, t.available_seats, t.description, t.duration,
-			t.no_smoking, t.animals_allowed, t.music_allowed, t.status,
+			t.no_smoking, t.animals_allowed, t.music_allowed, t.amenities, t.cancellation_policy, t.status,
 			u.full_name as driver_name, u.rating as driver_rating, u.phone,
 			CONCAT(u.car_brand, ' ', u.car_model) as driver_car
 		FROM trips t
 		JOIN users u ON t.driver_id = u.id
 		WHERE t.id = $1
 	`, tripID).Scan(
-		&trip.ID, &trip.DriverID, &trip.FromCity, &trip.ToCity, &trip.TripDate, &trip.TripTime,
+		&trip.ID, &trip.DriverID, &trip.FromCity, &trip.ToCity, &trip.TripDate,
+		&trip.DepartureAt, &trip.Timezone, &trip.DistanceKm, &trip.DurationMinutes, &trip.ArrivalAt,
 		&trip.Price, &trip.Seats, &trip.AvailableSeats, &trip.Description, &duration,
-		&trip.NoSmoking, &trip.AnimalsAllowed, &trip.MusicAllowed, &trip.Status,
+		&trip.NoSmoking, &trip.AnimalsAllowed, &trip.MusicAllowed, &trip.Amenities, &trip.CancellationPolicy, &trip.Status,
 		&trip.DriverName, &trip.DriverRating, &phone, &trip.DriverCar,
 	)
 
@@ -225,13 +416,38 @@ func GetTrip(c *gin.Context) {
 		trip.Duration = duration.String
 	}
 
+	local := geo.LocalTime(trip.DepartureAt, trip.Timezone)
+	trip.DepartureAt = trip.DepartureAt.UTC()
+	trip.DepartureLocal = local.Format(time.RFC3339)
+	trip.TripTime = local.Format("15:04")
+	setArrival(&trip)
+
+	// Номер водителя раскрывается только после подтверждения бронирования,
+	// до этого общение идёт через сообщения в приложении
 	if phone.Valid {
-		trip.Phone = phone.String
+		trip.Phone = maskPhone(phone.String)
+		if canSeeTripContacts(trip.ID, trip.DriverID, c.GetInt("userID")) {
+			trip.Phone = phone.String
+			trip.PhoneRevealed = true
+		}
 	}
 
 	c.JSON(http.StatusOK, trip)
 }
 
+// setArrival заполняет время прибытия по часовому поясу города назначения
+// и человекочитаемую длительность поездки
+func setArrival(trip *models.Trip) {
+	if trip.DurationMinutes != nil {
+		trip.Duration = geo.FormatDuration(*trip.DurationMinutes)
+	}
+	if trip.ArrivalAt != nil {
+		arrival := trip.ArrivalAt.UTC()
+		trip.ArrivalAt = &arrival
+		trip.ArrivalLocal = geo.LocalTime(arrival, geo.TimezoneFor(trip.ToCity)).Format(time.RFC3339)
+	}
+}
+
 // GetMyTrips получает поездки текущего пользователя
 func GetMyTrips(c *gin.Context) {
 	userID, _ := c.Get("userID")
@@ -244,23 +460,23 @@ func GetMyTrips(c *gin.Context) {
 		query = `
 			SELECT 
 				t.id, t.from_city, t.to_city, t.trip_date,
-				TO_CHAR(t.trip_time, 'HH24:MI') as trip_time,
+				t.departure_at, t.timezone,
 				t.price, t.seats, t.available_seats, t.status, t.driver_id
 			FROM trips t
 			WHERE t.driver_id = $1
-			ORDER BY t.trip_date DESC, t.trip_time DESC
+			ORDER BY t.departure_at DESC
 		`
 	} else {
 		query = `
 			SELECT 
 				t.id, t.from_city, t.to_city, t.trip_date,
-				TO_CHAR(t.trip_time, 'HH24:MI') as trip_time,
+				t.departure_at, t.timezone,
 				t.price, b.seats_booked, b.status as booking_status, 
 				t.status as trip_status, t.driver_id
 			FROM bookings b
 			JOIN trips t ON b.trip_id = t.id
 			WHERE b.passenger_id = $1
-			ORDER BY t.trip_date DESC, t.trip_time DESC
+			ORDER BY t.departure_at DESC
 		`
 	}
 
@@ -280,7 +496,8 @@ func GetMyTrips(c *gin.Context) {
 				fromCity       string
 				toCity         string
 				tripDate       time.Time
-				tripTime       string
+				departureAt    time.Time
+				timezone       string
 				price          int
 				seats          int
 				availableSeats int
@@ -288,18 +505,22 @@ func GetMyTrips(c *gin.Context) {
 				driverID       int
 			)
 
-			err := rows.Scan(&id, &fromCity, &toCity, &tripDate, &tripTime,
+			err := rows.Scan(&id, &fromCity, &toCity, &tripDate, &departureAt, &timezone,
 				&price, &seats, &availableSeats, &status, &driverID)
 			if err != nil {
 				continue
 			}
 
+			local := geo.LocalTime(departureAt, timezone)
+
 			result = append(result, gin.H{
 				"id":             id,
 				"fromCity":       fromCity,
 				"toCity":         toCity,
 				"tripDate":       tripDate,
-				"tripTime":       tripTime,
+				"tripTime":       local.Format("15:04"),
+				"departureAt":    departureAt.UTC(),
+				"departureLocal": local.Format(time.RFC3339),
 				"price":          price,
 				"seats":          seats,
 				"availableSeats": availableSeats,
@@ -312,7 +533,8 @@ func GetMyTrips(c *gin.Context) {
 				fromCity      string
 				toCity        string
 				tripDate      time.Time
-				tripTime      string
+				departureAt   time.Time
+				timezone      string
 				price         int
 				seatsBooked   int
 				bookingStatus string
@@ -320,30 +542,75 @@ func GetMyTrips(c *gin.Context) {
 				driverID      int
 			)
 
-			err := rows.Scan(&id, &fromCity, &toCity, &tripDate, &tripTime, 
+			err := rows.Scan(&id, &fromCity, &toCity, &tripDate, &departureAt, &timezone,
 				&price, &seatsBooked, &bookingStatus, &tripStatus, &driverID)
 			if err != nil {
 				continue
 			}
 
+			local := geo.LocalTime(departureAt, timezone)
+
 			// Используем booking_status для отображения, но добавляем trip_status
 			result = append(result, gin.H{
-				"id":            id,
-				"fromCity":      fromCity,
-				"toCity":        toCity,
-				"tripDate":      tripDate,
-				"tripTime":      tripTime,
-				"price":         price,
-				"seatsBooked":   seatsBooked,
-				"status":        bookingStatus, // Статус бронирования
-				"tripStatus":    tripStatus,     // Статус поездки
-				"driverId":      driverID,
+				"id":             id,
+				"fromCity":       fromCity,
+				"toCity":         toCity,
+				"tripDate":       tripDate,
+				"tripTime":       local.Format("15:04"),
+				"departureAt":    departureAt.UTC(),
+				"departureLocal": local.Format(time.RFC3339),
+				"price":          price,
+				"seatsBooked":    seatsBooked,
+				"status":         bookingStatus, // Статус бронирования
+				"tripStatus":     tripStatus,     // Статус поездки
+				"driverId":       driverID,
 			})
 		}
 	}
 
 	c.JSON(http.StatusOK, result)
 }
+
+// GetAmenityCatalog — справочник удобств для формы поездки и фильтров поиска
+func GetAmenityCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, models.AmenityCatalog)
+}
+
+// GetCancellationPolicies — справочник политик отмены и правила штрафа для водителей
+func GetCancellationPolicies(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"policies":      cancellation.Policies,
+		"defaultPolicy": cancellation.DefaultPolicy,
+		"driver":        cancellation.Driver,
+	})
+}
+
+// SuggestTripPrice — рекомендованная цена за место для маршрута
+func SuggestTripPrice(c *gin.Context) {
+	var req struct {
+		FromCity string `form:"from" binding:"required"`
+		ToCity   string `form:"to" binding:"required"`
+		Seats    int    `form:"seats" binding:"omitempty,min=1,max=8"`
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Seats == 0 {
+		req.Seats = 3
+	}
+
+	suggestion, err := pricing.Suggest(req.FromCity, req.ToCity, req.Seats)
+	if err != nil {
+		log.Println("❌ Ошибка расчёта цены:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suggest price"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestion)
+}
 // CancelTrip отменяет поездку
 func CancelTrip(c *gin.Context) {
 	tripID := c.Param("id")
@@ -352,7 +619,8 @@ func CancelTrip(c *gin.Context) {
 	// Проверяем, что пользователь - владелец поездки
 	var driverID int
 	var status string
-	err := database.DB.QueryRow("SELECT driver_id, status FROM trips WHERE id = $1", tripID).Scan(&driverID, &status)
+	var departureAt time.Time
+	err := database.DB.QueryRow("SELECT driver_id, status, departure_at FROM trips WHERE id = $1", tripID).Scan(&driverID, &status, &departureAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
@@ -377,22 +645,138 @@ func CancelTrip(c *gin.Context) {
 		return
 	}
 
+	tx, err := audit.Begin(auditActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Блокируем поездку: параллельная отмена или завершение дождутся этой транзакции
+	err = tx.QueryRow("SELECT status FROM trips WHERE id = $1 FOR UPDATE", tripID).Scan(&status)
+	if err != nil {
+		log.Println("❌ Ошибка блокировки поездки:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
+		return
+	}
+	if status == "cancelled" || status == "completed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Trip is already " + status})
+		return
+	}
+
+	// Пассажиры, которых нужно известить об отмене, — в той же транзакции, что и событие
+	passengerIDs, err := tripPassengerIDs(tx, tripID)
+	if err != nil {
+		log.Println("❌ Ошибка получения пассажиров поездки:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
+		return
+	}
+
+	// Подтверждённым пассажирам возвращается всё; водитель платит штраф за позднюю отмену.
+	// Бронирования заблокированы, поэтому сумма не изменится до коммита.
+	var confirmedTotal int
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(total_price), 0) FROM (
+			SELECT total_price FROM bookings WHERE trip_id = $1 AND status = 'confirmed' FOR UPDATE
+		) confirmed
+	`, tripID).Scan(&confirmedTotal)
+	if err != nil {
+		log.Println("❌ Ошибка расчёта штрафа:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
+		return
+	}
+
+	untilDeparture := time.Until(departureAt)
+	charge := cancellation.DriverPenalty(confirmedTotal, untilDeparture)
+
 	// Отменяем поездку
-	_, err = database.DB.Exec("UPDATE trips SET status = 'cancelled' WHERE id = $1", tripID)
+	_, err = tx.Exec("UPDATE trips SET status = 'cancelled' WHERE id = $1", tripID)
 	if err != nil {
 		log.Println("❌ Ошибка отмены поездки:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
 		return
 	}
 
-	// Отменяем все бронирования
-	_, err = database.DB.Exec("UPDATE bookings SET status = 'cancelled' WHERE trip_id = $1 AND status = 'pending'", tripID)
+	// Записываем возвраты по подтверждённым бронированиям и штраф водителя
+	_, err = tx.Exec(`
+		INSERT INTO cancellations (booking_id, trip_id, cancelled_by, party, rule, hours_before_departure, fee, refund)
+		SELECT id, trip_id, $2, 'driver', $3, $4, 0, total_price
+		FROM bookings WHERE trip_id = $1 AND status = 'confirmed'
+	`, tripID, driverID, cancellation.RuleTripCancelled, untilDeparture.Hours())
+	if err != nil {
+		log.Println("❌ Ошибка записи возвратов:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
+		return
+	}
+
+	if charge.Fee > 0 {
+		_, err = tx.Exec(`
+			INSERT INTO cancellations (trip_id, cancelled_by, party, rule, hours_before_departure, fee, refund)
+			VALUES ($1, $2, 'driver', $3, $4, $5, 0)
+		`, tripID, driverID, charge.Rule, untilDeparture.Hours(), charge.Fee)
+		if err != nil {
+			log.Println("❌ Ошибка записи штрафа:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
+			return
+		}
+	}
+
+	// Отменяем все бронирования; блокировки оплаты снимет обработчик TripCancelled
+	_, err = tx.Exec("UPDATE bookings SET status = 'cancelled', updated_at = NOW() WHERE trip_id = $1 AND status IN ('pending', 'confirmed')", tripID)
 	if err != nil {
-		log.Println("⚠️ Предупреждение: не удалось отменить бронирования:", err)
+		log.Println("❌ Ошибка отмены бронирований:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
+		return
 	}
 
+	tripIDInt, _ := strconv.Atoi(tripID)
+	err = events.Record(tx, events.TripCancelled, "trip", tripIDInt, events.TripPayload{
+		TripID:       tripIDInt,
+		DriverID:     driverID,
+		PassengerIDs: passengerIDs,
+	})
+	if err != nil {
+		log.Println("❌ Ошибка записи события:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel trip"})
+		return
+	}
+	events.Wake()
+
 	log.Println("✅ Поездка отменена:", tripID)
-	c.JSON(http.StatusOK, gin.H{"message": "Trip cancelled successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Trip cancelled successfully",
+		"refunded": charge.Refund,
+		"penalty":  charge.Fee,
+	})
+}
+
+// tripPassengerIDs возвращает пассажиров с действующими бронированиями на поездку.
+// Бронирования блокируются до конца транзакции, чтобы список совпал с записанным событием.
+func tripPassengerIDs(tx *sql.Tx, tripID string) ([]int, error) {
+	rows, err := tx.Query(`
+		SELECT passenger_id FROM bookings
+		WHERE trip_id = $1 AND status IN ('pending', 'confirmed')
+		FOR UPDATE
+	`, tripID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
 }
 
 // CompleteTrip завершает поездку
@@ -428,14 +812,58 @@ func CompleteTrip(c *gin.Context) {
 		return
 	}
 
+	tx, err := audit.Begin(auditActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete trip"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Блокируем поездку и проверяем статус ещё раз: её могли отменить, пока шла проверка
+	err = tx.QueryRow("SELECT status FROM trips WHERE id = $1 FOR UPDATE", tripID).Scan(&status)
+	if err != nil {
+		log.Println("❌ Ошибка блокировки поездки:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete trip"})
+		return
+	}
+	if status != "active" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Trip is already " + status})
+		return
+	}
+
 	// Завершаем поездку
-	_, err = database.DB.Exec("UPDATE trips SET status = 'completed' WHERE id = $1", tripID)
+	_, err = tx.Exec("UPDATE trips SET status = 'completed', completed_at = NOW() WHERE id = $1", tripID)
 	if err != nil {
 		log.Println("❌ Ошибка завершения поездки:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete trip"})
 		return
 	}
 
+	passengerIDs, err := tripPassengerIDs(tx, tripID)
+	if err != nil {
+		log.Println("❌ Ошибка получения пассажиров поездки:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete trip"})
+		return
+	}
+
+	tripIDInt, _ := strconv.Atoi(tripID)
+	err = events.Record(tx, events.TripCompleted, "trip", tripIDInt, events.TripPayload{
+		TripID:       tripIDInt,
+		DriverID:     driverID,
+		PassengerIDs: passengerIDs,
+	})
+	if err != nil {
+		log.Println("❌ Ошибка записи события:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete trip"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete trip"})
+		return
+	}
+	events.Wake()
+
 	log.Println("✅ Поездка завершена:", tripID)
 	c.JSON(http.StatusOK, gin.H{"message": "Trip completed successfully"})
 }
@@ -2,12 +2,15 @@ package handlers
 
 import (
     "fmt"
+    "log"
     "net/http"
     "path/filepath"
     "time"
     
+    "hermes-carpooling/audit"
     "hermes-carpooling/database"
     "hermes-carpooling/models"
+    "hermes-carpooling/reviews"
     
     "github.com/gin-gonic/gin"
 )
@@ -20,14 +23,15 @@ func GetProfile(c *gin.Context) {
     
     err := database.DB.QueryRow(`
         SELECT id, full_name, email, phone, role, COALESCE(avatar_url, ''), rating, reviews_count,
+               COALESCE(rating_score, $2),
                COALESCE(car_brand, ''), COALESCE(car_model, ''), COALESCE(car_year, 0), 
                COALESCE(car_color, ''), COALESCE(car_number, ''),
-               created_at, updated_at
+               default_amenities, created_at, updated_at
         FROM users WHERE id = $1
-    `, userID).Scan(
+    `, userID, reviews.Rating.PriorMean).Scan(
         &user.ID, &user.FullName, &user.Email, &user.Phone, &user.Role, &avatarURL,
-        &user.Rating, &user.ReviewsCount, &user.CarBrand, &user.CarModel, &user.CarYear,
-        &user.CarColor, &user.CarNumber, &user.CreatedAt, &user.UpdatedAt,
+        &user.Rating, &user.ReviewsCount, &user.RatingScore, &user.CarBrand, &user.CarModel, &user.CarYear,
+        &user.CarColor, &user.CarNumber, &user.DefaultAmenities, &user.CreatedAt, &user.UpdatedAt,
     )
     
     if err != nil {
@@ -35,6 +39,12 @@ func GetProfile(c *gin.Context) {
         return
     }
     
+    // Средние оценки по критериям и частые метки из отзывов
+    reviewStats, err := reviews.Details(userID)
+    if err != nil {
+        log.Println("⚠️ Не удалось получить статистику отзывов:", err)
+    }
+    
     // ДОБАВЛЯЕМ TIMESTAMP К АВАТАРКЕ ДЛЯ ОЧИСТКИ КЭША
     if avatarURL != "" {
         avatarURL = fmt.Sprintf("%s?t=%d", avatarURL, time.Now().Unix())
@@ -42,22 +52,25 @@ func GetProfile(c *gin.Context) {
     
     // Возвращаем JSON с обновлённой аватаркой
     c.JSON(http.StatusOK, gin.H{
-        "id":           user.ID,
-        "fullName":     user.FullName,
-        "email":        user.Email,
-        "phone":        user.Phone,
-        "role":         user.Role,
-        "avatarUrl":    avatarURL,
-        "rating":       user.Rating,
-        "reviewsCount": user.ReviewsCount,
-        "carBrand":     user.CarBrand,
-        "carModel":     user.CarModel,
-        "carYear":      user.CarYear,
-        "carColor":     user.CarColor,
-        "carNumber":    user.CarNumber,
-        "isVerified":   false,
-        "createdAt":    user.CreatedAt,
-        "updatedAt":    user.UpdatedAt,
+        "id":               user.ID,
+        "fullName":         user.FullName,
+        "email":            user.Email,
+        "phone":            user.Phone,
+        "role":             user.Role,
+        "avatarUrl":        avatarURL,
+        "rating":           user.Rating,
+        "reviewsCount":     user.ReviewsCount,
+        "ratingScore":      user.RatingScore,
+        "reviewStats":      reviewStats,
+        "carBrand":         user.CarBrand,
+        "carModel":         user.CarModel,
+        "carYear":          user.CarYear,
+        "carColor":         user.CarColor,
+        "carNumber":        user.CarNumber,
+        "defaultAmenities": user.DefaultAmenities,
+        "isVerified":       false,
+        "createdAt":        user.CreatedAt,
+        "updatedAt":        user.UpdatedAt,
     })
 }
 
@@ -72,6 +85,8 @@ func UpdateProfile(c *gin.Context) {
         CarYear   *int    `json:"carYear"`
         CarColor  *string `json:"carColor"`
         CarNumber *string `json:"carNumber"`
+
+        DefaultAmenities *models.Amenities `json:"defaultAmenities"`
     }
     
     if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -79,13 +94,22 @@ func UpdateProfile(c *gin.Context) {
         return
     }
     
-    _, err := database.DB.Exec(`
+    if updateData.DefaultAmenities != nil {
+        if err := updateData.DefaultAmenities.Validate(); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+    }
+    
+    // Удобства по умолчанию меняются, только если переданы в запросе
+    _, err := audit.Exec(auditActor(c), `
         UPDATE users 
         SET full_name = $1, phone = $2, car_brand = $3, car_model = $4, 
-            car_year = $5, car_color = $6, car_number = $7, updated_at = NOW()
-        WHERE id = $8
+            car_year = $5, car_color = $6, car_number = $7,
+            default_amenities = COALESCE($8, default_amenities), updated_at = NOW()
+        WHERE id = $9
     `, updateData.FullName, updateData.Phone, updateData.CarBrand, updateData.CarModel,
-       updateData.CarYear, updateData.CarColor, updateData.CarNumber, userID)
+       updateData.CarYear, updateData.CarColor, updateData.CarNumber, updateData.DefaultAmenities, userID)
     
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
@@ -123,7 +147,7 @@ func UploadAvatar(c *gin.Context) {
     
     // Обновляем БД
     avatarURL := fmt.Sprintf("/uploads/avatars/%s", filename)
-    _, err = database.DB.Exec(`UPDATE users SET avatar_url = $1 WHERE id = $2`, avatarURL, userID)
+    _, err = audit.Exec(auditActor(c), `UPDATE users SET avatar_url = $1 WHERE id = $2`, avatarURL, userID)
     
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update database"})
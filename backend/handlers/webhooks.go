@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql"
+	"hermes-carpooling/database"
+	"hermes-carpooling/models"
+	"hermes-carpooling/webhooks"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWebhookEventTypes — события, на которые можно подписать вебхук
+func GetWebhookEventTypes(c *gin.Context) {
+	names := []string{}
+	for _, name := range webhooks.EventTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.JSON(http.StatusOK, names)
+}
+
+// CreateWebhook — зарегистрировать вебхук. Секрет для проверки подписи
+// возвращается только в ответе на создание.
+func CreateWebhook(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	var req models.WebhookCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := webhooks.ValidateURL(c.Request.Context(), req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validWebhookEventTypes(req.EventTypes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event type"})
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	var webhookID int
+	err = database.DB.QueryRow(`
+		INSERT INTO webhooks (user_id, url, description, event_types, secret)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, userID, req.URL, req.Description, models.StringList(req.EventTypes), secret).Scan(&webhookID)
+
+	if err != nil {
+		log.Println("❌ Ошибка создания вебхука:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Webhook created successfully",
+		"webhookId": webhookID,
+		"secret":    secret,
+	})
+}
+
+// GetMyWebhooks — вебхуки текущего пользователя
+func GetMyWebhooks(c *gin.Context) {
+	userID := c.GetInt("userID")
+
+	rows, err := database.DB.Query(`
+		SELECT id, user_id, url, description, event_types, active, created_at
+		FROM webhooks
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhooks"})
+		return
+	}
+	defer rows.Close()
+
+	result := []models.Webhook{}
+	for rows.Next() {
+		var w models.Webhook
+		if err := rows.Scan(&w.ID, &w.UserID, &w.URL, &w.Description, &w.EventTypes, &w.Active, &w.CreatedAt); err != nil {
+			continue
+		}
+		result = append(result, w)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// UpdateWebhook — изменить адрес, фильтр событий или включить/выключить вебхук
+func UpdateWebhook(c *gin.Context) {
+	userID := c.GetInt("userID")
+	webhookID := c.Param("id")
+
+	var req models.WebhookUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.URL != nil {
+		if err := webhooks.ValidateURL(c.Request.Context(), *req.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !validWebhookEventTypes(req.EventTypes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event type"})
+		return
+	}
+
+	var eventTypes interface{}
+	if req.EventTypes != nil {
+		eventTypes = models.StringList(req.EventTypes)
+	}
+
+	result, err := database.DB.Exec(`
+		UPDATE webhooks
+		SET url = COALESCE($1, url),
+			description = COALESCE($2, description),
+			event_types = COALESCE($3::jsonb, event_types),
+			active = COALESCE($4, active)
+		WHERE id = $5 AND user_id = $6
+	`, req.URL, req.Description, eventTypes, req.Active, webhookID, userID)
+
+	if err != nil {
+		log.Println("❌ Ошибка обновления вебхука:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully"})
+}
+
+// DeleteWebhook — удалить вебхук вместе с журналом доставок
+func DeleteWebhook(c *gin.Context) {
+	userID := c.GetInt("userID")
+	webhookID := c.Param("id")
+
+	result, err := database.DB.Exec(`
+		DELETE FROM webhooks WHERE id = $1 AND user_id = $2
+	`, webhookID, userID)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries — журнал доставок вебхука (последние 100, можно фильтровать по ?status=)
+func GetWebhookDeliveries(c *gin.Context) {
+	userID := c.GetInt("userID")
+	webhookID := c.Param("id")
+
+	if !ownsWebhook(c, webhookID, userID) {
+		return
+	}
+
+	query := `
+		SELECT id, webhook_id, event_id, event_type, payload, status, attempts,
+			response_status, response_body, last_error, duration_ms, redelivery_of,
+			next_attempt_at, delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1`
+	args := []interface{}{webhookID}
+
+	if status := c.Query("status"); status != "" {
+		query += " AND status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY id DESC LIMIT 100"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get deliveries"})
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&d.ResponseStatus, &d.ResponseBody, &d.LastError, &d.DurationMs, &d.RedeliveryOf,
+			&d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt)
+		if err != nil {
+			continue
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverWebhook — повторно отправить доставку. Создаётся новая запись журнала
+// с тем же телом, исходная остаётся как есть.
+func RedeliverWebhook(c *gin.Context) {
+	userID := c.GetInt("userID")
+	webhookID := c.Param("id")
+	deliveryID := c.Param("deliveryId")
+
+	if !ownsWebhook(c, webhookID, userID) {
+		return
+	}
+
+	var newID int
+	err := database.DB.QueryRow(`
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, redelivery_of)
+		SELECT webhook_id, event_id, event_type, payload, id
+		FROM webhook_deliveries
+		WHERE id = $1 AND webhook_id = $2
+		RETURNING id
+	`, deliveryID, webhookID).Scan(&newID)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		} else {
+			log.Println("❌ Ошибка повторной доставки вебхука:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver"})
+		}
+		return
+	}
+
+	webhooks.Wake()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Redelivery scheduled",
+		"deliveryId": newID,
+	})
+}
+
+// ownsWebhook проверяет, что вебхук принадлежит пользователю. При ошибке ответ уже отправлен.
+func ownsWebhook(c *gin.Context, webhookID string, userID int) bool {
+	if _, err := strconv.Atoi(webhookID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return false
+	}
+
+	var ownerID int
+	err := database.DB.QueryRow(`SELECT user_id FROM webhooks WHERE id = $1`, webhookID).Scan(&ownerID)
+	if err != nil || ownerID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return false
+	}
+	return true
+}
+
+func validWebhookEventTypes(names []string) bool {
+	for _, name := range names {
+		if !webhooks.ValidEventType(name) {
+			return false
+		}
+	}
+	return true
+}
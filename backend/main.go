@@ -1,14 +1,25 @@
 package main
 
 import (
+    "hermes-carpooling/cancellation"
     "hermes-carpooling/config"
     "hermes-carpooling/database"
+    "hermes-carpooling/events"
+    "hermes-carpooling/geo"
     "hermes-carpooling/handlers"
     "hermes-carpooling/middleware"
+    "hermes-carpooling/notifications"
+    "hermes-carpooling/payments"
+    "hermes-carpooling/pricing"
+    "hermes-carpooling/promotions"
+    "hermes-carpooling/reviews"
+    "hermes-carpooling/webhooks"
     "log"
+    "net/http"
     "os"
     "path/filepath"
     "strings"
+    "time"
 
     "github.com/gin-gonic/gin"
     "github.com/joho/godotenv"
@@ -23,18 +34,92 @@ func main() {
     // Загрузка конфигурации
     cfg := config.Load()
 
+    // Часовой пояс по умолчанию для справочника городов
+    geo.DefaultTimezone = cfg.DefaultTimezone
+
+    // Внешний провайдер маршрутов (необязательный)
+    if cfg.RoutingURL != "" {
+        geo.Provider = geo.OSRMProvider{
+            BaseURL: strings.TrimSuffix(cfg.RoutingURL, "/"),
+            Client:  &http.Client{Timeout: 5 * time.Second},
+        }
+        log.Println("🗺️ Routing provider:", cfg.RoutingURL)
+    }
+
+    // Параметры расчёта цены за место
+    pricing.Config.FuelPricePerLiter = cfg.FuelPricePerLiter
+    pricing.Config.FuelConsumption = cfg.FuelConsumption
+    pricing.Config.CeilingFactor = cfg.PriceCeilingFactor
+    pricing.Config.FallbackMaxPrice = cfg.PriceCeilingFallback
+
     // Инициализация базы данных
     if err := database.Init(cfg); err != nil {
         log.Fatal("Failed to initialize database:", err)
     }
     defer database.Close()
 
+    // Каналы уведомлений. Push и SMS пока работают через заглушки,
+    // реальные провайдеры подключаются реализацией интерфейсов notifications
+    var mailer notifications.Mailer = notifications.LogMailer{}
+    if cfg.SMTPHost != "" {
+        mailer = notifications.SMTPMailer{
+            Host:     cfg.SMTPHost,
+            Port:     cfg.SMTPPort,
+            Username: cfg.SMTPUser,
+            Password: cfg.SMTPPassword,
+            From:     cfg.SMTPFrom,
+        }
+    }
+    notifications.Register(notifications.EmailChannel{Mailer: mailer})
+    notifications.Register(notifications.PushChannel{Provider: notifications.LogPushProvider{}})
+    notifications.Register(notifications.SMSChannel{Provider: notifications.LogSMSProvider{}})
+
+    // Фоновая отправка дайджестов по сохранённым поискам
+    handlers.StartSavedSearchDigest(time.Hour)
+
+    // Доменные события: побочные эффекты выполняются после коммита через outbox
+    handlers.RegisterEventSubscribers()
+    events.Start(5 * time.Second)
+
+    // Отправка вебхуков партнёрам с повторными попытками
+    webhooks.Start(10 * time.Second)
+
+    // Платежи: пока подключён тестовый провайдер, выплаты водителям раз в 10 минут
+    payments.Config.PlatformFeePercent = cfg.PlatformFeePercent
+    payments.Config.PayoutHold = time.Duration(cfg.PayoutHoldHours * float64(time.Hour))
+    payments.StartPayouts(10 * time.Minute)
+
+    // Политики отмены
+    if _, ok := cancellation.Find(cfg.DefaultCancellationPolicy); ok {
+        cancellation.DefaultPolicy = cfg.DefaultCancellationPolicy
+    } else {
+        log.Println("⚠️ Неизвестная политика отмены по умолчанию:", cfg.DefaultCancellationPolicy)
+    }
+    cancellation.Driver.WindowHours = cfg.DriverPenaltyHours
+    cancellation.Driver.PenaltyPercent = cfg.DriverPenaltyPercent
+
+    // Реферальная программа
+    promotions.ReferralBonus = cfg.ReferralBonus
+
+    // Окно для отзывов после завершения поездки
+    reviews.WindowDays = cfg.ReviewWindowDays
+    reviews.StartPublisher(time.Hour)
+
+    // Рейтинг пользователей: сглаживание и затухание веса старых отзывов
+    reviews.Rating.PriorMean = cfg.RatingPriorMean
+    reviews.Rating.PriorWeight = cfg.RatingPriorWeight
+    reviews.Rating.HalfLifeDays = cfg.RatingHalfLifeDays
+    reviews.StartRatingRefresh(24 * time.Hour)
+
     // Создание роутера
     router := gin.Default()
 
     // CORS middleware
     router.Use(middleware.CORS())
 
+    // ID запроса для журнала изменений
+    router.Use(middleware.RequestID())
+
     // Получаем путь к frontend
     currentDir, err := os.Getwd()
     if err != nil {
@@ -107,12 +192,91 @@ func main() {
             users.GET("/profile", handlers.GetProfile)
             users.PUT("/profile", handlers.UpdateProfile)
             users.POST("/avatar", handlers.UploadAvatar)  // ДОБАВЛЕНО: загрузка аватарки
+            users.GET("/blocked", handlers.GetBlockedUsers)
+            users.GET("/report-categories", handlers.GetUserReportCategories)
+            users.POST("/:id/block", handlers.BlockUser)
+            users.DELETE("/:id/block", handlers.UnblockUser)
+            users.POST("/:id/report", handlers.ReportUser)
+            users.GET("/history", handlers.GetMyHistory)
+        }
+
+        // Публичный профиль пользователя (доступен без авторизации)
+        api.GET("/users/:id/public", middleware.AuthOptional(), handlers.GetPublicProfile)
+
+        // Сохранённые поиски (требуют авторизации)
+        savedSearches := api.Group("/saved-searches")
+        savedSearches.Use(middleware.AuthRequired())
+        {
+            savedSearches.POST("", handlers.CreateSavedSearch)
+            savedSearches.GET("", handlers.GetMySavedSearches)
+            savedSearches.DELETE("/:id", handlers.DeleteSavedSearch)
+        }
+
+        // Уведомления (требуют авторизации)
+        inbox := api.Group("/notifications")
+        inbox.Use(middleware.AuthRequired())
+        {
+            inbox.GET("", handlers.GetNotifications)
+            inbox.GET("/unread-count", handlers.GetUnreadNotificationsCount)
+            inbox.PATCH("/read-all", handlers.MarkAllNotificationsRead)
+            inbox.PATCH("/:id/read", handlers.MarkNotificationRead)
+            inbox.GET("/preferences", handlers.GetNotificationPreferences)
+            inbox.PUT("/preferences", handlers.UpdateNotificationPreferences)
+        }
+
+        // Поток событий в реальном времени (SSE); вместо заголовка можно передать
+        // одноразовый билет в ?ticket=
+        api.POST("/events/ticket", middleware.AuthRequired(), handlers.CreateStreamTicket)
+        api.GET("/events", middleware.AuthStream(), handlers.StreamEvents)
+
+        // Сообщения между водителем и пассажирами (требуют авторизации)
+        conversations := api.Group("/conversations")
+        conversations.Use(middleware.AuthRequired())
+        {
+            conversations.POST("", handlers.StartConversation)
+            conversations.GET("", handlers.GetConversations)
+            conversations.GET("/unread-count", handlers.GetUnreadMessagesCount)
+            conversations.GET("/:id/messages", handlers.GetConversationMessages)
+            conversations.POST("/:id/messages", handlers.SendMessage)
+            conversations.PATCH("/:id/read", handlers.MarkConversationRead)
+        }
+
+        // Выплаты водителям (требуют авторизации)
+        api.GET("/payouts", middleware.AuthRequired(), handlers.GetMyPayouts)
+
+        // Промокоды, бонусный кошелёк и реферальная программа (требуют авторизации)
+        api.POST("/promo-codes/check", middleware.AuthRequired(), handlers.CheckPromoCode)
+        api.GET("/wallet", middleware.AuthRequired(), handlers.GetWallet)
+        api.GET("/referrals", middleware.AuthRequired(), handlers.GetReferrals)
+
+        // Отчёты о заработке и расходах (требуют авторизации)
+        reportsGroup := api.Group("/reports")
+        reportsGroup.Use(middleware.AuthRequired())
+        {
+            reportsGroup.GET("/earnings", handlers.GetEarningsReport)
+            reportsGroup.GET("/spending", handlers.GetSpendingReport)
+        }
+
+        // Вебхуки для партнёрских систем (партнёры и администраторы)
+        hooks := api.Group("/webhooks")
+        hooks.Use(middleware.AuthRequired(), middleware.PartnerRequired())
+        {
+            hooks.POST("", handlers.CreateWebhook)
+            hooks.GET("", handlers.GetMyWebhooks)
+            hooks.GET("/event-types", handlers.GetWebhookEventTypes)
+            hooks.PUT("/:id", handlers.UpdateWebhook)
+            hooks.DELETE("/:id", handlers.DeleteWebhook)
+            hooks.GET("/:id/deliveries", handlers.GetWebhookDeliveries)
+            hooks.POST("/:id/deliveries/:deliveryId/redeliver", handlers.RedeliverWebhook)
         }
 
         // Поездки
         trips := api.Group("/trips")
+        trips.Use(middleware.AuthOptional())
         {
             trips.GET("/search", handlers.SearchTrips)
+            trips.GET("/amenities", handlers.GetAmenityCatalog)
+            trips.GET("/cancellation-policies", handlers.GetCancellationPolicies)
             trips.GET("/:id", handlers.GetTrip)
         }
 
@@ -122,6 +286,7 @@ func main() {
         {
             tripsAuth.POST("", handlers.CreateTrip)
             tripsAuth.GET("/my-trips", handlers.GetMyTrips)
+            tripsAuth.GET("/price-suggestion", handlers.SuggestTripPrice)
             tripsAuth.PATCH("/:id/cancel", handlers.CancelTrip)
             tripsAuth.PATCH("/:id/complete", handlers.CompleteTrip)
         }
@@ -134,6 +299,9 @@ func main() {
             bookings.GET("/my-bookings", handlers.GetMyBookings)
             bookings.GET("/driver", handlers.GetDriverBookings)
             bookings.PATCH("/:id/status", handlers.UpdateBookingStatus)
+            bookings.GET("/:id/cancellation-quote", handlers.GetCancellationQuote)
+            bookings.PATCH("/:id/cancel", handlers.CancelBooking)
+            bookings.GET("/:id/receipt", handlers.GetBookingReceipt)
             bookings.POST("/:id/rate", handlers.RatePassenger)
         }
 
@@ -143,11 +311,43 @@ func main() {
         {
             reviews.POST("", handlers.CreateReview)
             reviews.GET("/user/:id", handlers.GetUserReviews)
+            reviews.GET("/user/:id/stats", handlers.GetUserReviewStats)
+            reviews.GET("/catalog", handlers.GetReviewCatalog)
             reviews.GET("/my-reviews", handlers.GetMyReviews)
             reviews.GET("/check/:tripId", handlers.CheckExistingReview)
             reviews.PUT("/:id", handlers.UpdateReview)
+            reviews.GET("/pending", handlers.GetPendingReviews)
+            reviews.POST("/:id/reply", handlers.ReplyToReview)
+            reviews.POST("/:id/report", handlers.ReportReview)
             reviews.GET("/my-written-reviews", handlers.GetMyWrittenReviews)
         }
+
+        // Администрирование (требует прав администратора)
+        admin := api.Group("/admin")
+        admin.Use(middleware.AuthRequired(), middleware.AdminRequired())
+        {
+            admin.GET("/reviews/reports", handlers.GetReviewModerationQueue)
+            admin.PATCH("/reviews/:id/hide", handlers.HideReview)
+            admin.PATCH("/reviews/:id/restore", handlers.RestoreReview)
+            admin.DELETE("/reviews/:id", handlers.DeleteReview)
+            admin.GET("/users/reports", handlers.GetUserReports)
+            admin.PATCH("/users/reports/:id", handlers.ResolveUserReport)
+            admin.PUT("/reviews/:id", handlers.EditReview)
+            admin.GET("/users", handlers.SearchUsers)
+            admin.GET("/users/:id", handlers.GetUserDetails)
+            admin.PATCH("/users/:id/suspend", handlers.SuspendUser)
+            admin.PATCH("/users/:id/ban", handlers.BanUser)
+            admin.PATCH("/users/:id/reinstate", handlers.ReinstateUser)
+            admin.PATCH("/users/:id/partner", handlers.SetUserPartner)
+            admin.GET("/users/:id/members", handlers.GetPartnerMembers)
+            admin.POST("/users/:id/members", handlers.AddPartnerMember)
+            admin.DELETE("/users/:id/members/:userId", handlers.RemovePartnerMember)
+            admin.PATCH("/trips/:id/cancel", handlers.ForceCancelTrip)
+            admin.PATCH("/bookings/:id/cancel", handlers.ForceCancelBooking)
+            admin.GET("/stats", handlers.GetPlatformStats)
+            admin.GET("/actions", handlers.GetAdminActions)
+            admin.GET("/audit", handlers.GetAuditLog)
+        }
     }
 
     // Исправляем порт
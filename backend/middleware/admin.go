@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"hermes-carpooling/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRequired — доступ только для администраторов; ставится после AuthRequired.
+// Флаг проверяется по базе, чтобы снятие прав действовало сразу, без перевыпуска токена.
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var isAdmin bool
+		err := database.DB.QueryRow(`
+			SELECT is_admin FROM users WHERE id = $1
+		`, c.GetInt("userID")).Scan(&isAdmin)
+		if err != nil || !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Set("isAdmin", true)
+		c.Next()
+	}
+}
@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"errors"
+	"hermes-carpooling/admin"
 	"hermes-carpooling/config"
+	"hermes-carpooling/realtime"
+	"log"
 	"net/http"
 	"strings"
 
@@ -26,27 +30,128 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
-		// Парсим токен
-		cfg := config.Load()
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		claims, err := parseToken(tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		// Извлекаем claims
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("userID", int(claims["user_id"].(float64)))
-			c.Set("userEmail", claims["email"].(string))
-			c.Next()
+		setClaims(c, claims)
+		if !accountActive(c) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// AuthOptional — для публичных маршрутов: если передан валидный токен,
+// в контекст кладётся пользователь, иначе запрос обрабатывается анонимно
+func AuthOptional() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString != "" {
+			if claims, err := parseToken(tokenString); err == nil {
+				setClaims(c, claims)
+			}
+		}
+		c.Next()
+	}
+}
+
+// AuthStream — как AuthRequired, но для EventSource, который не умеет передавать
+// заголовки: вместо токена можно передать одноразовый билет ?ticket=
+// (POST /events/ticket). Сам JWT в адресе не принимается — адреса пишутся в логи.
+func AuthStream() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ticket := c.Query("ticket"); ticket != "" {
+			userID, err := realtime.RedeemTicket(ticket)
+			if err == realtime.ErrInvalidTicket {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				log.Println("❌ Ошибка проверки билета потока событий:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket"})
+				c.Abort()
+				return
+			}
+			c.Set("userID", userID)
 		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+			tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if tokenString == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization required"})
+				c.Abort()
+				return
+			}
+
+			claims, err := parseToken(tokenString)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			setClaims(c, claims)
+		}
+
+		if !accountActive(c) {
 			return
 		}
+		c.Next()
 	}
 }
+
+// accountActive прерывает запрос, если аккаунт приостановлен или заблокирован.
+// Проверяется по базе, чтобы блокировка действовала сразу, без отзыва токена.
+func accountActive(c *gin.Context) bool {
+	restriction, err := admin.AccountRestriction(c.GetInt("userID"))
+	if err == admin.ErrUserNotFound {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		c.Abort()
+		return false
+	}
+	if err != nil {
+		log.Println("❌ Ошибка проверки статуса аккаунта:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check account"})
+		c.Abort()
+		return false
+	}
+	if restriction != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": restriction.Message(), "reason": restriction.Reason})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// parseToken проверяет подпись и срок действия токена
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	cfg := config.Load()
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.JWTSecret), nil
+	})
+
+	if err != nil || !token.Valid {
+		return nil, errors.New("Invalid or expired token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("Invalid token claims")
+	}
+
+	if _, ok := claims["user_id"].(float64); !ok {
+		return nil, errors.New("Invalid token claims")
+	}
+	if _, ok := claims["email"].(string); !ok {
+		return nil, errors.New("Invalid token claims")
+	}
+
+	return claims, nil
+}
+
+func setClaims(c *gin.Context, claims jwt.MapClaims) {
+	c.Set("userID", int(claims["user_id"].(float64)))
+	c.Set("userEmail", claims["email"].(string))
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"hermes-carpooling/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PartnerRequired — доступ только для партнёров и администраторов; ставится после AuthRequired
+func PartnerRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var allowed bool
+		err := database.DB.QueryRow(`
+			SELECT is_partner OR is_admin FROM users WHERE id = $1
+		`, c.GetInt("userID")).Scan(&allowed)
+		if err != nil || !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Partner access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader — заголовок с ID запроса; клиент или прокси может передать свой
+const RequestIDHeader = "X-Request-ID"
+
+// clientRequestID — допустимый ID от клиента: без пробелов и управляющих символов,
+// чтобы его можно было безопасно писать в журнал и заголовок ответа; вместе
+// с префиксом client- укладывается в audit_log.request_id (64 символа)
+var clientRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,57}$`)
+
+// RequestID присваивает запросу ID: он возвращается в заголовке ответа
+// и записывается в журнал изменений, чтобы по нему найти, что сделал запрос.
+// ID клиента помечается префиксом client-, чтобы его нельзя было выдать за серверный.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if clientRequestID.MatchString(id) {
+			id = "client-" + id
+		} else {
+			id = newRequestID()
+		}
+
+		c.Set("requestID", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// newRequestID — случайный ID запроса; если генератор недоступен — по времени
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "t" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Типы удобств: флаг (есть/нет) или уровень из упорядоченного списка
+const (
+	AmenityFlag  = "flag"
+	AmenityLevel = "level"
+)
+
+// Amenity — описание удобства в поездке
+type Amenity struct {
+	Key     string   `json:"key"`
+	Label   string   `json:"label"`
+	Type    string   `json:"type"`
+	Options []string `json:"options,omitempty"` // для AmenityLevel, от меньшего к большему
+}
+
+// AmenityCatalog — справочник удобств. Чтобы добавить новое удобство,
+// достаточно дописать его сюда: хранение, фильтры и проверка работают по справочнику.
+var AmenityCatalog = []Amenity{
+	{Key: "luggage", Label: "Багаж", Type: AmenityLevel, Options: []string{"none", "small", "medium", "large"}},
+	{Key: "childSeat", Label: "Детское кресло", Type: AmenityFlag},
+	{Key: "maxTwoInBack", Label: "Максимум двое сзади", Type: AmenityFlag},
+	{Key: "womenOnly", Label: "Только для женщин", Type: AmenityFlag},
+	{Key: "airConditioning", Label: "Кондиционер", Type: AmenityFlag},
+	{Key: "bikeRack", Label: "Крепление для велосипеда", Type: AmenityFlag},
+}
+
+// FindAmenity ищет удобство в справочнике по ключу
+func FindAmenity(key string) (Amenity, bool) {
+	for _, a := range AmenityCatalog {
+		if a.Key == key {
+			return a, true
+		}
+	}
+	return Amenity{}, false
+}
+
+// Amenities — значения удобств поездки: флаги хранятся как bool, уровни как string
+type Amenities map[string]interface{}
+
+// Validate проверяет ключи и значения по справочнику
+func (a Amenities) Validate() error {
+	for key, value := range a {
+		amenity, ok := FindAmenity(key)
+		if !ok {
+			return fmt.Errorf("unknown amenity %q", key)
+		}
+
+		switch amenity.Type {
+		case AmenityFlag:
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("amenity %q must be true or false", key)
+			}
+		case AmenityLevel:
+			s, ok := value.(string)
+			if !ok || amenity.LevelIndex(s) < 0 {
+				return fmt.Errorf("amenity %q must be one of %v", key, amenity.Options)
+			}
+		}
+	}
+	return nil
+}
+
+// Merge возвращает значения по умолчанию, переопределённые значениями overrides
+func (a Amenities) Merge(overrides Amenities) Amenities {
+	result := Amenities{}
+	for k, v := range a {
+		result[k] = v
+	}
+	for k, v := range overrides {
+		result[k] = v
+	}
+	return result
+}
+
+// LevelIndex возвращает позицию значения в списке уровней или -1
+func (a Amenity) LevelIndex(value string) int {
+	for i, option := range a.Options {
+		if option == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// Scan читает JSONB из базы
+func (a *Amenities) Scan(src interface{}) error {
+	if src == nil {
+		*a = Amenities{}
+		return nil
+	}
+
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Amenities", src)
+	}
+
+	result := Amenities{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	*a = result
+	return nil
+}
+
+// Value записывает удобства в JSONB
+func (a Amenities) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
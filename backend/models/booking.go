@@ -9,6 +9,9 @@ type Booking struct {
     SeatsBooked   int       `json:"seatsBooked" db:"seats_booked"`
     TotalPrice    int       `json:"totalPrice" db:"total_price"`
     Status        string    `json:"status" db:"status"`
+    PaymentStatus string    `json:"paymentStatus" db:"payment_status"`
+    Discount      int       `json:"discount" db:"discount_amount"`
+    WalletAmount  int       `json:"walletAmount" db:"wallet_amount"`
     CreatedAt     time.Time `json:"createdAt" db:"created_at"`
     UpdatedAt     time.Time `json:"updatedAt" db:"updated_at"`
     
@@ -21,6 +24,8 @@ type Booking struct {
 }
 
 type BookingCreate struct {
-    TripID      int `json:"tripId" binding:"required"`
-    SeatsBooked int `json:"seatsBooked" binding:"required,min=1,max=8"`
+    TripID      int    `json:"tripId" binding:"required"`
+    SeatsBooked int    `json:"seatsBooked" binding:"required,min=1,max=8"`
+    PromoCode   string `json:"promoCode"`
+    UseWallet   bool   `json:"useWallet"` // оплатить часть бонусами с кошелька
 }
\ No newline at end of file
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Conversation — переписка водителя с одним пассажиром по поездке
+type Conversation struct {
+	ID          int       `json:"id" db:"id"`
+	TripID      int       `json:"tripId" db:"trip_id"`
+	BookingID   *int      `json:"bookingId" db:"booking_id"`
+	DriverID    int       `json:"driverId" db:"driver_id"`
+	PassengerID int       `json:"passengerId" db:"passenger_id"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type Message struct {
+	ID             int        `json:"id" db:"id"`
+	ConversationID int        `json:"conversationId" db:"conversation_id"`
+	SenderID       int        `json:"senderId" db:"sender_id"`
+	Body           string     `json:"body" db:"body"`
+	ReadAt         *time.Time `json:"readAt" db:"read_at"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type ConversationCreate struct {
+	TripID      int `json:"tripId" binding:"required"`
+	PassengerID int `json:"passengerId"` // указывает водитель; пассажир пишет от своего имени
+}
+
+type MessageCreate struct {
+	Body string `json:"body" binding:"required,max=2000"`
+}
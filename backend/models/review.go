@@ -0,0 +1,176 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Роли адресата отзыва
+const (
+	ReviewTargetDriver    = "driver"
+	ReviewTargetPassenger = "passenger"
+)
+
+// ReviewCriterion — критерий дополнительной оценки 1–5.
+// Roles — о ком можно ставить оценку; пусто — о любом участнике.
+type ReviewCriterion struct {
+	Key   string   `json:"key"`
+	Label string   `json:"label"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// ReviewCriteria — справочник критериев оценки
+var ReviewCriteria = []ReviewCriterion{
+	{Key: "punctuality", Label: "Пунктуальность"},
+	{Key: "drivingSafety", Label: "Безопасность вождения", Roles: []string{ReviewTargetDriver}},
+	{Key: "cleanliness", Label: "Чистота"},
+	{Key: "communication", Label: "Общение"},
+}
+
+// ReviewTag — готовая метка отзыва
+type ReviewTag struct {
+	Key   string   `json:"key"`
+	Label string   `json:"label"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// ReviewTagCatalog — справочник меток. Новая метка добавляется только сюда.
+var ReviewTagCatalog = []ReviewTag{
+	{Key: "onTime", Label: "Вовремя"},
+	{Key: "pleasantConversation", Label: "Приятная беседа"},
+	{Key: "polite", Label: "Вежливость"},
+	{Key: "safeDriving", Label: "Аккуратное вождение", Roles: []string{ReviewTargetDriver}},
+	{Key: "cleanCar", Label: "Чистая машина", Roles: []string{ReviewTargetDriver}},
+	{Key: "comfortableRide", Label: "Комфортная поездка", Roles: []string{ReviewTargetDriver}},
+	{Key: "flexiblePickup", Label: "Удобное место посадки", Roles: []string{ReviewTargetDriver}},
+	{Key: "tidy", Label: "Аккуратный пассажир", Roles: []string{ReviewTargetPassenger}},
+	{Key: "lightLuggage", Label: "Мало багажа", Roles: []string{ReviewTargetPassenger}},
+}
+
+// appliesTo — подходит ли критерий или метка для роли адресата
+func appliesTo(roles []string, role string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// FindReviewCriterion ищет критерий по ключу
+func FindReviewCriterion(key string) (ReviewCriterion, bool) {
+	for _, c := range ReviewCriteria {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return ReviewCriterion{}, false
+}
+
+// FindReviewTag ищет метку по ключу
+func FindReviewTag(key string) (ReviewTag, bool) {
+	for _, t := range ReviewTagCatalog {
+		if t.Key == key {
+			return t, true
+		}
+	}
+	return ReviewTag{}, false
+}
+
+// ReviewCreate — отзыв о попутчике; оценки по критериям и метки необязательны
+type ReviewCreate struct {
+	TripID   int          `json:"tripId" binding:"required"`
+	TargetID int          `json:"targetId" binding:"required"`
+	Rating   int          `json:"rating" binding:"required,min=1,max=5"`
+	Comment  string       `json:"comment"`
+	Scores   ReviewScores `json:"scores"`
+	Tags     StringList   `json:"tags"`
+}
+
+// ValidateDetails проверяет оценки и метки для роли адресата
+func (r ReviewCreate) ValidateDetails(targetRole string) error {
+	if err := r.Scores.Validate(targetRole); err != nil {
+		return err
+	}
+	return ValidateReviewTags(r.Tags, targetRole)
+}
+
+// ValidateReviewTags проверяет метки по справочнику и роли адресата
+func ValidateReviewTags(tags []string, targetRole string) error {
+	seen := map[string]bool{}
+	for _, key := range tags {
+		tag, ok := FindReviewTag(key)
+		if !ok {
+			return fmt.Errorf("unknown review tag %q", key)
+		}
+		if !appliesTo(tag.Roles, targetRole) {
+			return fmt.Errorf("review tag %q does not apply to a %s", key, targetRole)
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate review tag %q", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// ReviewScores — оценки по критериям: ключ критерия → 1..5
+type ReviewScores map[string]int
+
+// Validate проверяет ключи и значения по справочнику и роли адресата
+func (s ReviewScores) Validate(targetRole string) error {
+	for key, value := range s {
+		criterion, ok := FindReviewCriterion(key)
+		if !ok {
+			return fmt.Errorf("unknown review criterion %q", key)
+		}
+		if !appliesTo(criterion.Roles, targetRole) {
+			return fmt.Errorf("review criterion %q does not apply to a %s", key, targetRole)
+		}
+		if value < 1 || value > 5 {
+			return fmt.Errorf("review criterion %q must be between 1 and 5", key)
+		}
+	}
+	return nil
+}
+
+// Scan читает JSONB из базы
+func (s *ReviewScores) Scan(src interface{}) error {
+	if src == nil {
+		*s = ReviewScores{}
+		return nil
+	}
+
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ReviewScores", src)
+	}
+
+	result := ReviewScores{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	*s = result
+	return nil
+}
+
+// Value записывает оценки в JSONB
+func (s ReviewScores) Value() (driver.Value, error) {
+	if s == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
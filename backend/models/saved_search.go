@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// SavedSearch — сохранённый поиск пассажира, по которому приходят уведомления о новых поездках
+type SavedSearch struct {
+	ID             int        `json:"id" db:"id"`
+	UserID         int        `json:"userId" db:"user_id"`
+	FromCity       *string    `json:"fromCity" db:"from_city"`
+	ToCity         *string    `json:"toCity" db:"to_city"`
+	DateFrom       *time.Time `json:"dateFrom" db:"date_from"`
+	DateTo         *time.Time `json:"dateTo" db:"date_to"`
+	MaxPrice       *int       `json:"maxPrice" db:"max_price"`
+	MinSeats       *int       `json:"minSeats" db:"min_seats"`
+	NoSmoking      bool       `json:"noSmoking" db:"no_smoking"`
+	AnimalsAllowed bool       `json:"animalsAllowed" db:"animals_allowed"`
+	MusicAllowed   bool       `json:"musicAllowed" db:"music_allowed"`
+	Digest         bool       `json:"digest" db:"digest"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type SavedSearchCreate struct {
+	FromCity       string `json:"fromCity"`
+	ToCity         string `json:"toCity"`
+	DateFrom       string `json:"dateFrom"`
+	DateTo         string `json:"dateTo"`
+	MaxPrice       *int   `json:"maxPrice" binding:"omitempty,min=0"`
+	MinSeats       *int   `json:"minSeats" binding:"omitempty,min=1,max=8"`
+	NoSmoking      bool   `json:"noSmoking"`
+	AnimalsAllowed bool   `json:"animalsAllowed"`
+	MusicAllowed   bool   `json:"musicAllowed"`
+	Digest         bool   `json:"digest"`
+}
@@ -15,11 +15,28 @@ type Trip struct {
     Description    string    `json:"description" db:"description"`
     Duration       string    `json:"duration" db:"duration"`
 
+    // Момент отправления (UTC) и часовой пояс города отправления
+    DepartureAt    time.Time `json:"departureAt" db:"departure_at"`
+    DepartureLocal string    `json:"departureLocal"`
+    Timezone       string    `json:"timezone" db:"timezone"`
+
+    // Расчётные расстояние, время в пути и прибытие
+    DistanceKm      *float64   `json:"distanceKm" db:"distance_km"`
+    DurationMinutes *int       `json:"durationMinutes" db:"duration_minutes"`
+    ArrivalAt       *time.Time `json:"arrivalAt" db:"arrival_at"`
+    ArrivalLocal    string     `json:"arrivalLocal"`
+
     // Conditions
     NoSmoking      bool `json:"noSmoking" db:"no_smoking"`
     AnimalsAllowed bool `json:"animalsAllowed" db:"animals_allowed"`
     MusicAllowed   bool `json:"musicAllowed" db:"music_allowed"`
 
+    // Дополнительные удобства (см. AmenityCatalog)
+    Amenities Amenities `json:"amenities" db:"amenities"`
+
+    // Политика отмены бронирования (см. cancellation.Policies)
+    CancellationPolicy string `json:"cancellationPolicy" db:"cancellation_policy"`
+
     Status    string    `json:"status" db:"status"` // active, completed, cancelled
     CreatedAt time.Time `json:"createdAt" db:"created_at"`
     UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
@@ -29,19 +46,25 @@ type Trip struct {
     DriverRating float64 `json:"driverRating" db:"driver_rating"`
     DriverCar    string  `json:"driverCar" db:"driver_car"`
     Phone        string  `json:"phone" db:"phone"`
+
+    // Телефон раскрыт только участникам подтверждённого бронирования
+    PhoneRevealed bool `json:"phoneRevealed"`
 }
 
 type TripCreate struct {
-    FromCity       string `json:"fromCity" binding:"required"`
-    ToCity         string `json:"toCity" binding:"required"`
-    TripDate       string `json:"tripDate" binding:"required"`
-    TripTime       string `json:"tripTime" binding:"required"`
-    Price          int    `json:"price" binding:"required,min=0"`
-    Seats          int    `json:"seats" binding:"required,min=1,max=8"`
-    Description    string `json:"description"`
-    NoSmoking      bool   `json:"noSmoking"`
-    AnimalsAllowed bool   `json:"animalsAllowed"`
-    MusicAllowed   bool   `json:"musicAllowed"`
+    FromCity       string    `json:"fromCity" binding:"required"`
+    ToCity         string    `json:"toCity" binding:"required"`
+    TripDate       string    `json:"tripDate" binding:"required"`
+    TripTime       string    `json:"tripTime" binding:"required"`
+    Price          int       `json:"price" binding:"required,min=0"`
+    Seats          int       `json:"seats" binding:"required,min=1,max=8"`
+    Description    string    `json:"description"`
+    NoSmoking      bool      `json:"noSmoking"`
+    AnimalsAllowed bool      `json:"animalsAllowed"`
+    MusicAllowed   bool      `json:"musicAllowed"`
+    Amenities      Amenities `json:"amenities"`
+
+    CancellationPolicy string `json:"cancellationPolicy"`
 }
 
 type TripSearch struct {
@@ -15,6 +15,7 @@ type User struct {
     AvatarURL    *string   `json:"avatarUrl" db:"avatar_url"`
     Rating       float64   `json:"rating" db:"rating"`
     ReviewsCount int       `json:"reviewsCount" db:"reviews_count"`
+    RatingScore  float64   `json:"ratingScore" db:"rating_score"` // сглаженный рейтинг для ранжирования
     IsVerified   bool      `json:"isVerified" db:"is_verified"`
     CreatedAt    time.Time `json:"createdAt" db:"created_at"`
     UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
@@ -25,6 +26,9 @@ type User struct {
     CarYear   *int    `json:"carYear" db:"car_year"`
     CarColor  *string `json:"carColor" db:"car_color"`
     CarNumber *string `json:"carNumber" db:"car_number"`
+
+    // Удобства по умолчанию для новых поездок водителя
+    DefaultAmenities Amenities `json:"defaultAmenities" db:"default_amenities"`
 }
 
 type UserRegister struct {
@@ -33,6 +37,9 @@ type UserRegister struct {
     Phone    string `json:"phone" binding:"required"`
     Password string `json:"password" binding:"required,min=6"`
     IsDriver bool   `json:"isDriver"`
+
+    // Реферальный код пригласившего пользователя
+    ReferralCode string `json:"referralCode"`
 }
 
 type UserLogin struct {
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Webhook — адрес партнёрской системы, на который отправляются события
+type Webhook struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"userId" db:"user_id"`
+	URL         string     `json:"url" db:"url"`
+	Description string     `json:"description" db:"description"`
+	EventTypes  StringList `json:"eventTypes" db:"event_types"` // пустой список — все события
+	Active      bool       `json:"active" db:"active"`
+	CreatedAt   time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type WebhookCreate struct {
+	URL         string   `json:"url" binding:"required,url,max=500"`
+	Description string   `json:"description" binding:"max=200"`
+	EventTypes  []string `json:"eventTypes"`
+}
+
+type WebhookUpdate struct {
+	URL         *string  `json:"url" binding:"omitempty,url,max=500"`
+	Description *string  `json:"description" binding:"omitempty,max=200"`
+	EventTypes  []string `json:"eventTypes"`
+	Active      *bool    `json:"active"`
+}
+
+// WebhookDelivery — запись журнала доставки
+type WebhookDelivery struct {
+	ID             int             `json:"id" db:"id"`
+	WebhookID      int             `json:"webhookId" db:"webhook_id"`
+	EventID        *int64          `json:"eventId" db:"event_id"`
+	EventType      string          `json:"eventType" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	ResponseStatus *int            `json:"responseStatus" db:"response_status"`
+	ResponseBody   *string         `json:"responseBody" db:"response_body"`
+	LastError      *string         `json:"lastError" db:"last_error"`
+	DurationMs     *int            `json:"durationMs" db:"duration_ms"`
+	RedeliveryOf   *int            `json:"redeliveryOf" db:"redelivery_of"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt" db:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"deliveredAt" db:"delivered_at"`
+	CreatedAt      time.Time       `json:"createdAt" db:"created_at"`
+}
+
+// StringList — список строк, хранящийся в JSONB-массиве
+type StringList []string
+
+// Scan читает JSONB из базы
+func (l *StringList) Scan(src interface{}) error {
+	if src == nil {
+		*l = StringList{}
+		return nil
+	}
+
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", src)
+	}
+
+	result := StringList{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	*l = result
+	return nil
+}
+
+// Value записывает список в JSONB
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
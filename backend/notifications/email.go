@@ -0,0 +1,63 @@
+package notifications
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer отправляет письма
+type Mailer interface {
+	SendMail(to, subject, body string) error
+}
+
+// EmailChannel доставляет уведомления по email
+type EmailChannel struct {
+	Mailer Mailer
+}
+
+func (EmailChannel) Name() string { return ChannelEmail }
+
+func (ch EmailChannel) Send(r Recipient, n Notification) error {
+	if r.Email == "" {
+		return nil
+	}
+	return ch.Mailer.SendMail(r.Email, n.Title, n.Body)
+}
+
+// SMTPMailer отправляет письма через SMTP-сервер
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) SendMail(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(fmt.Sprintf("%s:%s", m.Host, m.Port), auth, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer — заглушка для локальной разработки: пишет письма в лог
+type LogMailer struct{}
+
+func (LogMailer) SendMail(to, subject, body string) error {
+	log.Printf("✉️ [email → %s] %s: %s", to, subject, body)
+	return nil
+}
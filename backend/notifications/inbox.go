@@ -0,0 +1,24 @@
+package notifications
+
+import (
+	"encoding/json"
+	"hermes-carpooling/database"
+)
+
+// InboxChannel сохраняет уведомление во входящие пользователя (таблица notifications)
+type InboxChannel struct{}
+
+func (InboxChannel) Name() string { return ChannelInbox }
+
+func (InboxChannel) Send(r Recipient, n Notification) error {
+	data, err := json.Marshal(n.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(`
+		INSERT INTO notifications (user_id, type, title, body, data)
+		VALUES ($1, $2, $3, $4, $5)
+	`, r.UserID, n.Type, n.Title, n.Body, data)
+	return err
+}
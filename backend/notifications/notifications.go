@@ -0,0 +1,106 @@
+package notifications
+
+import (
+	"log"
+	"sync"
+
+	"hermes-carpooling/database"
+)
+
+// Типы уведомлений
+const (
+	SavedSearchMatch        = "saved_search_match"
+	SavedSearchDigest       = "saved_search_digest"
+	BookingCreated          = "booking_created"
+	BookingConfirmed        = "booking_confirmed"
+	BookingCancelled        = "booking_cancelled"
+	BookingWithdrawn        = "booking_withdrawn" // пассажир сам отменил бронирование
+	BookingCancelledByAdmin = "booking_cancelled_by_admin"
+	TripCancelled           = "trip_cancelled"
+	ReviewReceived          = "review_received"
+	ReviewPending           = "review_pending" // попутчик оставил отзыв, он откроется после встречного
+	ReviewReply             = "review_reply"
+)
+
+// Notification — уведомление для конкретного пользователя.
+// Если Title и Body пусты, они берутся из шаблона типа на языке получателя.
+type Notification struct {
+	UserID int
+	Type   string
+	Title  string
+	Body   string
+	Data   map[string]interface{}
+}
+
+// Recipient — контакты и язык получателя
+type Recipient struct {
+	UserID int
+	Email  string
+	Phone  string
+	Locale string
+}
+
+// Channel — канал доставки уведомлений (in-app, email, push, sms)
+type Channel interface {
+	Name() string
+	Send(r Recipient, n Notification) error
+}
+
+var (
+	mu       sync.RWMutex
+	channels = []Channel{InboxChannel{}}
+)
+
+// Register добавляет канал доставки
+func Register(ch Channel) {
+	mu.Lock()
+	defer mu.Unlock()
+	channels = append(channels, ch)
+}
+
+// Send рассылает уведомление по каналам, включённым у пользователя.
+// Входящие сохраняются сразу, внешние каналы работают в фоне,
+// чтобы медленный SMTP или провайдер не задерживал ответ API.
+// Ошибка одного канала не мешает доставке через остальные.
+func Send(n Notification) {
+	r, err := loadRecipient(n.UserID)
+	if err != nil {
+		log.Printf("⚠️ Уведомление %s: получатель %d не найден: %v", n.Type, n.UserID, err)
+		return
+	}
+
+	if n.Title == "" && n.Body == "" {
+		n.Title, n.Body = Render(n.Type, r.Locale, n.Data)
+	}
+
+	enabled := enabledChannels(n.UserID, n.Type)
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, ch := range channels {
+		if !enabled[ch.Name()] {
+			continue
+		}
+
+		if ch.Name() == ChannelInbox {
+			deliver(ch, r, n)
+			continue
+		}
+		go deliver(ch, r, n)
+	}
+}
+
+func deliver(ch Channel, r Recipient, n Notification) {
+	if err := ch.Send(r, n); err != nil {
+		log.Printf("⚠️ Не удалось отправить уведомление через %s: %v", ch.Name(), err)
+	}
+}
+
+func loadRecipient(userID int) (Recipient, error) {
+	r := Recipient{UserID: userID}
+	err := database.DB.QueryRow(`
+		SELECT email, phone, COALESCE(locale, 'ru') FROM users WHERE id = $1
+	`, userID).Scan(&r.Email, &r.Phone, &r.Locale)
+	return r, err
+}
@@ -0,0 +1,99 @@
+package notifications
+
+import (
+	"hermes-carpooling/database"
+	"log"
+)
+
+// Каналы доставки
+const (
+	ChannelInbox = "inbox"
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+	ChannelSMS   = "sms"
+)
+
+// AllTypes — значение event_type в настройках, относящееся ко всем типам уведомлений
+const AllTypes = "*"
+
+// DefaultChannels — каналы, включённые, пока пользователь ничего не настроил.
+// Входящие отключить нельзя: это история уведомлений пользователя.
+var DefaultChannels = map[string]bool{
+	ChannelInbox: true,
+	ChannelEmail: true,
+	ChannelPush:  true,
+	ChannelSMS:   false,
+}
+
+// Preference — настройка канала для типа уведомлений (или для всех типов)
+type Preference struct {
+	Channel   string `json:"channel" binding:"required,oneof=email push sms"`
+	EventType string `json:"eventType"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// enabledChannels вычисляет каналы для пользователя и типа уведомления:
+// значения по умолчанию → настройка для всех типов → настройка для конкретного типа
+func enabledChannels(userID int, eventType string) map[string]bool {
+	enabled := map[string]bool{}
+	for ch, on := range DefaultChannels {
+		enabled[ch] = on
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT channel, event_type, enabled FROM notification_preferences
+		WHERE user_id = $1 AND event_type IN ($2, $3)
+		ORDER BY event_type = $3
+	`, userID, AllTypes, eventType)
+	if err != nil {
+		log.Println("⚠️ Не удалось загрузить настройки уведомлений:", err)
+		return enabled
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Preference
+		if err := rows.Scan(&p.Channel, &p.EventType, &p.Enabled); err == nil {
+			enabled[p.Channel] = p.Enabled
+		}
+	}
+
+	enabled[ChannelInbox] = true
+	return enabled
+}
+
+// GetPreferences возвращает сохранённые настройки пользователя
+func GetPreferences(userID int) ([]Preference, error) {
+	rows, err := database.DB.Query(`
+		SELECT channel, event_type, enabled FROM notification_preferences
+		WHERE user_id = $1
+		ORDER BY event_type, channel
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := []Preference{}
+	for rows.Next() {
+		var p Preference
+		if err := rows.Scan(&p.Channel, &p.EventType, &p.Enabled); err == nil {
+			prefs = append(prefs, p)
+		}
+	}
+	return prefs, nil
+}
+
+// SetPreference сохраняет настройку канала
+func SetPreference(userID int, p Preference) error {
+	if p.EventType == "" {
+		p.EventType = AllTypes
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO notification_preferences (user_id, channel, event_type, enabled)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, channel, event_type) DO UPDATE SET enabled = EXCLUDED.enabled
+	`, userID, p.Channel, p.EventType, p.Enabled)
+	return err
+}
@@ -0,0 +1,28 @@
+package notifications
+
+import "log"
+
+// PushProvider отправляет push-уведомления на устройства пользователя
+// (FCM, APNs и т.п. подключаются реализацией этого интерфейса)
+type PushProvider interface {
+	Push(userID int, title, body string, data map[string]interface{}) error
+}
+
+// PushChannel доставляет уведомления через push-провайдера
+type PushChannel struct {
+	Provider PushProvider
+}
+
+func (PushChannel) Name() string { return ChannelPush }
+
+func (ch PushChannel) Send(r Recipient, n Notification) error {
+	return ch.Provider.Push(r.UserID, n.Title, n.Body, n.Data)
+}
+
+// LogPushProvider — заглушка для локальной разработки
+type LogPushProvider struct{}
+
+func (LogPushProvider) Push(userID int, title, body string, data map[string]interface{}) error {
+	log.Printf("📲 [push → %d] %s: %s", userID, title, body)
+	return nil
+}
@@ -0,0 +1,30 @@
+package notifications
+
+import "log"
+
+// SMSProvider отправляет SMS через внешнего оператора
+type SMSProvider interface {
+	SendSMS(phone, text string) error
+}
+
+// SMSChannel доставляет короткий текст уведомления по SMS
+type SMSChannel struct {
+	Provider SMSProvider
+}
+
+func (SMSChannel) Name() string { return ChannelSMS }
+
+func (ch SMSChannel) Send(r Recipient, n Notification) error {
+	if r.Phone == "" {
+		return nil
+	}
+	return ch.Provider.SendSMS(r.Phone, n.Body)
+}
+
+// LogSMSProvider — заглушка для локальной разработки
+type LogSMSProvider struct{}
+
+func (LogSMSProvider) SendSMS(phone, text string) error {
+	log.Printf("💬 [sms → %s] %s", phone, text)
+	return nil
+}
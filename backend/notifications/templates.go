@@ -0,0 +1,93 @@
+package notifications
+
+import (
+	"bytes"
+	"log"
+	"text/template"
+)
+
+// DefaultLocale — язык, на который откатываемся, если шаблона на языке пользователя нет
+const DefaultLocale = "ru"
+
+type messageTemplate struct {
+	Title string
+	Body  string
+}
+
+// templates[тип][язык] — заголовок и текст уведомления; поля берутся из Notification.Data
+var templates = map[string]map[string]messageTemplate{
+	SavedSearchMatch: {
+		"ru": {"Новая поездка по вашему поиску", "{{.fromCity}} → {{.toCity}}, {{.tripDate}} {{.tripTime}}, {{.price}} ₽ за место"},
+		"en": {"New trip matches your search", "{{.fromCity}} → {{.toCity}}, {{.tripDate}} {{.tripTime}}, {{.price}} per seat"},
+	},
+	SavedSearchDigest: {
+		"ru": {"Новые поездки по сохранённым поискам", "Найдено новых поездок: {{.count}}"},
+		"en": {"New trips for your saved searches", "{{.count}} new trips match your saved searches"},
+	},
+	BookingCreated: {
+		"ru": {"Новая заявка на бронирование", "{{.passengerName}} хочет забронировать мест: {{.seatsBooked}} ({{.fromCity}} → {{.toCity}})"},
+		"en": {"New booking request", "{{.passengerName}} wants to book {{.seatsBooked}} seat(s) ({{.fromCity}} → {{.toCity}})"},
+	},
+	BookingConfirmed: {
+		"ru": {"Бронирование подтверждено", "Водитель подтвердил вашу поездку {{.fromCity}} → {{.toCity}}"},
+		"en": {"Booking confirmed", "The driver confirmed your trip {{.fromCity}} → {{.toCity}}"},
+	},
+	BookingCancelled: {
+		"ru": {"Бронирование отменено", "Бронирование на поездку {{.fromCity}} → {{.toCity}} отменено"},
+		"en": {"Booking cancelled", "Your booking for {{.fromCity}} → {{.toCity}} was cancelled"},
+	},
+	BookingWithdrawn: {
+		"ru": {"Пассажир отменил бронирование", "{{.passengerName}} отменил(а) бронирование на поездку {{.fromCity}} → {{.toCity}}"},
+		"en": {"Passenger cancelled a booking", "{{.passengerName}} cancelled their booking for {{.fromCity}} → {{.toCity}}"},
+	},
+	BookingCancelledByAdmin: {
+		"ru": {"Бронирование отменено поддержкой", "Служба поддержки отменила бронирование {{.passengerName}} на поездку {{.fromCity}} → {{.toCity}}"},
+		"en": {"Booking cancelled by support", "Support cancelled {{.passengerName}}'s booking for {{.fromCity}} → {{.toCity}}"},
+	},
+	TripCancelled: {
+		"ru": {"Поездка отменена", "Водитель отменил поездку {{.fromCity}} → {{.toCity}}"},
+		"en": {"Trip cancelled", "The driver cancelled the trip {{.fromCity}} → {{.toCity}}"},
+	},
+	ReviewReceived: {
+		"ru": {"Новый отзыв", "{{.authorName}} оставил(а) вам отзыв: {{.rating}} из 5"},
+		"en": {"New review", "{{.authorName}} left you a review: {{.rating}} out of 5"},
+	},
+	ReviewPending: {
+		"ru": {"Вам оставили отзыв", "{{.authorName}} оставил(а) вам отзыв. Оцените поездку в ответ, чтобы его увидеть"},
+		"en": {"You have a new review", "{{.authorName}} reviewed you. Leave your review to see it"},
+	},
+	ReviewReply: {
+		"ru": {"Ответ на отзыв", "{{.targetName}} ответил(а) на ваш отзыв"},
+		"en": {"Reply to your review", "{{.targetName}} replied to your review"},
+	},
+}
+
+// Render подставляет данные в шаблон типа уведомления на нужном языке
+func Render(notificationType, locale string, data map[string]interface{}) (title, body string) {
+	byLocale, ok := templates[notificationType]
+	if !ok {
+		return notificationType, ""
+	}
+
+	tmpl, ok := byLocale[locale]
+	if !ok {
+		tmpl = byLocale[DefaultLocale]
+	}
+
+	return execute(tmpl.Title, data), execute(tmpl.Body, data)
+}
+
+func execute(text string, data map[string]interface{}) string {
+	t, err := template.New("").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		log.Println("⚠️ Ошибка шаблона уведомления:", err)
+		return text
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Println("⚠️ Ошибка шаблона уведомления:", err)
+		return text
+	}
+	return buf.String()
+}
@@ -0,0 +1,497 @@
+package payments
+
+import (
+	"database/sql"
+	"fmt"
+	"hermes-carpooling/cancellation"
+	"hermes-carpooling/database"
+	"log"
+	"math"
+	"time"
+)
+
+// Статусы оплаты бронирования (bookings.payment_status)
+const (
+	StatusUnpaid            = "unpaid"
+	StatusAuthorized        = "authorized"
+	StatusCaptured          = "captured"
+	StatusVoided            = "voided"
+	StatusRefunded          = "refunded"
+	StatusPartiallyRefunded = "partially_refunded"
+)
+
+// Settings — параметры расчётов с водителями
+type Settings struct {
+	PlatformFeePercent float64       // комиссия сервиса с каждой выплаты
+	PayoutHold         time.Duration // сколько держать деньги после поездки до выплаты (на случай споров)
+}
+
+// Config — текущие настройки, задаются в main из конфигурации
+var Config = Settings{
+	PlatformFeePercent: 10,
+	PayoutHold:         24 * time.Hour,
+}
+
+// Default — используемый провайдер
+var Default Provider = NewFakeProvider()
+
+// MaxPayoutAttempts — после стольких неудач выплата требует ручного разбора
+const MaxPayoutAttempts = 5
+
+// Authorize блокирует стоимость бронирования на счёте пассажира и записывает
+// платёж в той же транзакции, что и подтверждение. Если транзакция не будет
+// зафиксирована, блокировку нужно снять через VoidAuthorization.
+// subsidy — часть стоимости, оплаченная промокодом и бонусами: её водителю доплачивает сервис.
+func Authorize(tx *sql.Tx, bookingID, payerID, amount, subsidy int) (string, error) {
+	if amount <= 0 && subsidy <= 0 {
+		return "", nil
+	}
+
+	// Если всё оплачено бонусами, к провайдеру не обращаемся
+	provider, ref := "wallet", ""
+	if amount > 0 {
+		var err error
+		ref, err = Default.Authorize(payerID, amount, fmt.Sprintf("booking-%d", bookingID))
+		if err != nil {
+			return "", err
+		}
+		provider = Default.Name()
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO payments (booking_id, provider, provider_ref, amount, subsidy, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, bookingID, provider, ref, amount, subsidy, StatusAuthorized)
+	if err != nil {
+		VoidAuthorization(ref)
+		return "", err
+	}
+
+	_, err = tx.Exec(`UPDATE bookings SET payment_status = $1 WHERE id = $2`, StatusAuthorized, bookingID)
+	if err != nil {
+		VoidAuthorization(ref)
+		return "", err
+	}
+
+	return ref, nil
+}
+
+// VoidAuthorization снимает блокировку, которая не попала в базу
+func VoidAuthorization(ref string) {
+	if err := void(ref, "void-"+ref); err != nil {
+		log.Println("⚠️ Платежи: не удалось снять блокировку", ref, ":", err)
+	}
+}
+
+// capture и void пропускают платежи, полностью оплаченные бонусами (без ref у провайдера)
+func capture(ref string, amount int, key string) error {
+	if ref == "" || amount <= 0 {
+		return nil
+	}
+	return Default.Capture(ref, amount, key)
+}
+
+func void(ref, key string) error {
+	if ref == "" {
+		return nil
+	}
+	return Default.Void(ref, key)
+}
+
+// operationKey — ключ идемпотентности операции по платежу в рамках доменного события:
+// повторная обработка события не спишет и не вернёт деньги второй раз
+func operationKey(eventID int64, paymentID int) string {
+	return fmt.Sprintf("event-%d-payment-%d", eventID, paymentID)
+}
+
+// closePlan по сумме блокировки на карте и сумме, оплаченной бонусами, решает,
+// сколько списать с карты, сколько удержать из бонусов в пользу водителя
+// и причитается ли водителю субсидия (поездка состоялась)
+type closePlan func(cardAmount, walletAmount int) (capture, walletFee int, paySubsidy bool)
+
+// closePayment закрывает заблокированный платёж по плану plan: списывает с карты
+// свою часть и снимает блокировку с остатка. Строка платежа блокируется на время
+// вызова провайдера, уже закрытый платёж не трогается. Возвращает списанную сумму.
+func closePayment(paymentID int, eventID int64, plan closePlan) (int, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var bookingID, amount, subsidy, walletAmount, captured int
+	var ref, status string
+	err = tx.QueryRow(`
+		SELECT p.booking_id, p.provider_ref, p.amount, p.subsidy, b.wallet_amount, p.captured_amount, p.status
+		FROM payments p
+		JOIN bookings b ON b.id = p.booking_id
+		WHERE p.id = $1
+		FOR UPDATE OF p
+	`, paymentID).Scan(&bookingID, &ref, &amount, &subsidy, &walletAmount, &captured, &status)
+	if err != nil {
+		return 0, err
+	}
+	if status != StatusAuthorized {
+		return captured, nil
+	}
+
+	requested, walletFee, paySubsidy := plan(amount, walletAmount)
+	captured, status = settlement(amount, requested, paySubsidy && subsidy > 0)
+	key := operationKey(eventID, paymentID)
+	if status == StatusVoided {
+		err = void(ref, key)
+	} else {
+		err = capture(ref, captured, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// captured_at отсчитывает срок до выплаты: он нужен, если водителю что-то причитается
+	owed := captured+walletFee > 0 || status == StatusCaptured
+	_, err = tx.Exec(`
+		UPDATE payments
+		SET status = $1, captured_amount = $2, wallet_fee = $3,
+		    captured_at = CASE WHEN $4 THEN NOW() END, updated_at = NOW()
+		WHERE id = $5
+	`, status, captured, walletFee, owed, paymentID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE bookings SET payment_status = $1 WHERE id = $2`, status, bookingID); err != nil {
+		return 0, err
+	}
+	return captured, tx.Commit()
+}
+
+// settlement — сколько списать из блокировки amount при запрошенной сумме
+// и каким становится статус платежа. Платёж, целиком оплаченный промокодом
+// и бонусами (amount = 0), считается списанным, если водителю причитается
+// субсидия: иначе она не попадёт в выплату.
+func settlement(amount, requested int, subsidyOwed bool) (int, string) {
+	switch {
+	case requested >= amount && (amount > 0 || subsidyOwed):
+		return amount, StatusCaptured
+	case requested <= 0:
+		return 0, StatusVoided
+	default:
+		return requested, StatusPartiallyRefunded
+	}
+}
+
+// authorizedPayments — заблокированные платежи по бронированиям поездки
+func authorizedPayments(tripID int) ([]int, error) {
+	rows, err := database.DB.Query(`
+		SELECT p.id
+		FROM payments p
+		JOIN bookings b ON p.booking_id = b.id
+		WHERE b.trip_id = $1 AND p.status = $2
+	`, tripID, StatusAuthorized)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// closeTripPayments закрывает все заблокированные платежи поездки
+func closeTripPayments(tripID int, eventID int64, plan closePlan) error {
+	ids, err := authorizedPayments(tripID)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, id := range ids {
+		if _, err := closePayment(id, eventID, plan); err != nil {
+			log.Printf("⚠️ Платежи: не удалось закрыть платёж %d: %v", id, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// CaptureTrip списывает заблокированные платежи подтверждённых бронирований
+// завершённой поездки. Уже списанные платежи пропускаются, поэтому вызов можно повторять.
+func CaptureTrip(tripID int, eventID int64) error {
+	return closeTripPayments(tripID, eventID, func(card, _ int) (int, int, bool) { return card, 0, true })
+}
+
+// VoidTrip снимает блокировки по отменённой поездке
+func VoidTrip(tripID int, eventID int64) error {
+	return closeTripPayments(tripID, eventID, func(int, int) (int, int, bool) { return 0, 0, false })
+}
+
+// SettleCancelledBooking закрывает блокировку отменённого бронирования: удержание fee
+// списывается с карты, а не покрытый картой остаток удерживается из бонусов (их возврат
+// уменьшит promotions.RefundBooking). Всё удержанное позже уйдёт водителю.
+func SettleCancelledBooking(bookingID, fee int, eventID int64) error {
+	var paymentID int
+	err := database.DB.QueryRow(`
+		SELECT id FROM payments WHERE booking_id = $1 AND status = $2
+	`, bookingID, StatusAuthorized).Scan(&paymentID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = closePayment(paymentID, eventID, func(card, wallet int) (int, int, bool) {
+		cardFee, walletFee := cancellation.SplitFee(fee, card, wallet)
+		return cardFee, walletFee, false
+	})
+	return err
+}
+
+// PlatformFee — комиссия сервиса с суммы
+func PlatformFee(gross int) int {
+	return int(math.Round(float64(gross) * Config.PlatformFeePercent / 100))
+}
+
+// ReleasePayouts собирает списанные платежи, у которых истёк срок удержания,
+// в выплаты водителям (одна выплата на поездку) и отправляет их провайдеру.
+// Неудачные выплаты повторяются при следующих запусках.
+func ReleasePayouts() {
+	rows, err := database.DB.Query(`
+		SELECT DISTINCT t.driver_id, b.trip_id
+		FROM payments p
+		JOIN bookings b ON p.booking_id = b.id
+		JOIN trips t ON b.trip_id = t.id
+		WHERE (p.status IN ($1, $2) OR p.wallet_fee > 0) AND p.payout_id IS NULL
+		  AND p.captured_at <= NOW() - $3 * INTERVAL '1 second'
+	`, StatusCaptured, StatusPartiallyRefunded, int(Config.PayoutHold.Seconds()))
+	if err != nil {
+		log.Println("⚠️ Выплаты: ошибка выборки платежей:", err)
+		return
+	}
+
+	type group struct{ driverID, tripID int }
+	var groups []group
+	for rows.Next() {
+		var g group
+		if err := rows.Scan(&g.driverID, &g.tripID); err != nil {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+
+	for _, g := range groups {
+		if err := createPayout(g.driverID, g.tripID); err != nil {
+			log.Printf("⚠️ Выплаты: не удалось создать выплату по поездке %d: %v", g.tripID, err)
+		}
+	}
+
+	// Отправляем новые выплаты и повторяем неудачные
+	rows, err = database.DB.Query(`
+		SELECT id FROM payouts
+		WHERE status IN ('pending', 'failed') AND attempts < $1
+		ORDER BY id
+	`, MaxPayoutAttempts)
+	if err != nil {
+		log.Println("⚠️ Выплаты: ошибка выборки выплат:", err)
+		return
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		sendPayout(id)
+	}
+}
+
+// createPayout привязывает платежи поездки к новой выплате и считает сумму за вычетом комиссии
+func createPayout(driverID, tripID int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var payoutID int
+	err = tx.QueryRow(`
+		INSERT INTO payouts (driver_id, trip_id, gross_amount, fee, amount)
+		VALUES ($1, $2, 0, 0, 0)
+		RETURNING id
+	`, driverID, tripID).Scan(&payoutID)
+	if err != nil {
+		return err
+	}
+
+	// Скидки и бонусы пассажира по состоявшимся поездкам сервис компенсирует водителю;
+	// по отменённым водителю уходит удержание с карты и из бонусов
+	rows, err := tx.Query(`
+		UPDATE payments p SET payout_id = $1, updated_at = NOW()
+		FROM bookings b
+		WHERE b.id = p.booking_id AND b.trip_id = $5
+		  AND p.payout_id IS NULL AND (p.status IN ($2, $3) OR p.wallet_fee > 0)
+		  AND p.captured_at <= NOW() - $4 * INTERVAL '1 second'
+		RETURNING p.captured_amount - p.refunded_amount + p.wallet_fee +
+		          CASE WHEN b.status = 'confirmed' THEN p.subsidy ELSE 0 END
+	`, payoutID, StatusCaptured, StatusPartiallyRefunded, int(Config.PayoutHold.Seconds()), tripID)
+	if err != nil {
+		return err
+	}
+
+	gross := 0
+	for rows.Next() {
+		var amount int
+		if err := rows.Scan(&amount); err != nil {
+			rows.Close()
+			return err
+		}
+		gross += amount
+	}
+	rows.Close()
+
+	// Платежи уже забрала параллельная выплата
+	if gross == 0 {
+		return nil
+	}
+
+	fee := PlatformFee(gross)
+	penalty, err := deductPenalties(tx, driverID, gross-fee)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE payouts SET gross_amount = $1, fee = $2, penalty = $3, amount = $4 WHERE id = $5
+	`, gross, fee, penalty, gross-fee-penalty, payoutID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deductPenalties удерживает из выплаты непогашенные штрафы водителя за поздние
+// отмены поездок (не больше limit) и возвращает удержанную сумму
+func deductPenalties(tx *sql.Tx, driverID, limit int) (int, error) {
+	rows, err := tx.Query(`
+		SELECT id, fee - settled_amount FROM cancellations
+		WHERE cancelled_by = $1 AND party = 'driver' AND fee > settled_amount
+		ORDER BY id
+		FOR UPDATE
+	`, driverID)
+	if err != nil {
+		return 0, err
+	}
+
+	type debt struct{ id, amount int }
+	var debts []debt
+	for rows.Next() {
+		var d debt
+		if err := rows.Scan(&d.id, &d.amount); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		debts = append(debts, d)
+	}
+	rows.Close()
+
+	amounts := make([]int, len(debts))
+	for i, d := range debts {
+		amounts[i] = d.amount
+	}
+
+	total := 0
+	for i, amount := range allocatePenalties(amounts, limit) {
+		if amount == 0 {
+			break
+		}
+		if _, err := tx.Exec(`UPDATE cancellations SET settled_amount = settled_amount + $1 WHERE id = $2`, amount, debts[i].id); err != nil {
+			return 0, err
+		}
+		total += amount
+	}
+	return total, nil
+}
+
+// allocatePenalties распределяет limit по долгам от старых к новым
+// и возвращает, сколько удержать по каждому
+func allocatePenalties(debts []int, limit int) []int {
+	result := make([]int, len(debts))
+	for i, debt := range debts {
+		if debt > limit {
+			debt = limit
+		}
+		if debt <= 0 {
+			break
+		}
+		result[i] = debt
+		limit -= debt
+	}
+	return result
+}
+
+// sendPayout отправляет выплату провайдеру и записывает результат. Номер выплаты
+// служит ключом идемпотентности: если результат не сохранился, повтор не заплатит дважды.
+func sendPayout(payoutID int) {
+	var driverID, amount int
+	err := database.DB.QueryRow(`SELECT driver_id, amount FROM payouts WHERE id = $1`, payoutID).Scan(&driverID, &amount)
+	if err != nil {
+		log.Println("⚠️ Выплаты: не удалось загрузить выплату:", err)
+		return
+	}
+
+	if amount <= 0 {
+		if _, err := database.DB.Exec(`UPDATE payouts SET status = 'paid', paid_at = NOW() WHERE id = $1`, payoutID); err != nil {
+			log.Printf("⚠️ Выплаты: не удалось закрыть пустую выплату %d: %v", payoutID, err)
+		}
+		return
+	}
+
+	ref, err := Default.Payout(driverID, amount, fmt.Sprintf("payout-%d", payoutID))
+	if err != nil {
+		log.Printf("⚠️ Выплаты: выплата %d не прошла: %v", payoutID, err)
+		_, dbErr := database.DB.Exec(`
+			UPDATE payouts SET status = 'failed', attempts = attempts + 1, last_error = $1 WHERE id = $2
+		`, err.Error(), payoutID)
+		if dbErr != nil {
+			log.Printf("⚠️ Выплаты: не удалось сохранить ошибку выплаты %d: %v", payoutID, dbErr)
+		}
+		return
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE payouts
+		SET status = 'paid', provider = $1, provider_ref = $2, attempts = attempts + 1, last_error = NULL, paid_at = NOW()
+		WHERE id = $3
+	`, Default.Name(), ref, payoutID)
+	if err != nil {
+		log.Printf("⚠️ Выплаты: выплата %d прошла (%s), но не сохранена: %v", payoutID, ref, err)
+		return
+	}
+	log.Printf("✅ Выплата %d: %d ₽ водителю %d", payoutID, amount, driverID)
+}
+
+// StartPayouts запускает фоновую обработку выплат
+func StartPayouts(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ReleasePayouts()
+		}
+	}()
+}
@@ -0,0 +1,83 @@
+package payments
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSettlement(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      int
+		requested   int
+		subsidyOwed bool
+		wantAmount  int
+		wantStatus  string
+	}{
+		{"trip completed", 1500, 1500, true, 1500, StatusCaptured},
+		{"trip cancelled", 1500, 0, false, 0, StatusVoided},
+		{"late cancellation fee", 1500, 750, false, 750, StatusPartiallyRefunded},
+		{"fee above the card amount", 400, 750, false, 400, StatusCaptured},
+		{"negative request", 1500, -10, false, 0, StatusVoided},
+		{"paid by wallet and promo, trip completed", 0, 0, true, 0, StatusCaptured},
+		{"paid by wallet and promo, trip cancelled", 0, 0, false, 0, StatusVoided},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, status := settlement(tt.amount, tt.requested, tt.subsidyOwed)
+			if amount != tt.wantAmount || status != tt.wantStatus {
+				t.Errorf("settlement(%d, %d, %t) = %d, %s; want %d, %s",
+					tt.amount, tt.requested, tt.subsidyOwed, amount, status, tt.wantAmount, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestOperationKey(t *testing.T) {
+	if operationKey(7, 3) == operationKey(8, 3) {
+		t.Error("different events must not share an idempotency key")
+	}
+	if operationKey(7, 3) != operationKey(7, 3) {
+		t.Error("the same event and payment must produce the same key")
+	}
+}
+
+func TestPlatformFee(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	Config.PlatformFeePercent = 10
+
+	tests := []struct{ gross, want int }{
+		{0, 0},
+		{1000, 100},
+		{1234, 123},
+		{1235, 124},
+	}
+	for _, tt := range tests {
+		if got := PlatformFee(tt.gross); got != tt.want {
+			t.Errorf("PlatformFee(%d) = %d, want %d", tt.gross, got, tt.want)
+		}
+	}
+}
+
+func TestAllocatePenalties(t *testing.T) {
+	tests := []struct {
+		name  string
+		debts []int
+		limit int
+		want  []int
+	}{
+		{"no debts", nil, 1000, []int{}},
+		{"all covered", []int{200, 300}, 1000, []int{200, 300}},
+		{"oldest first", []int{600, 600}, 1000, []int{600, 400}},
+		{"nothing to pay out", []int{200}, 0, []int{0}},
+		{"limit exhausted", []int{500, 100, 100}, 500, []int{500, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := allocatePenalties(tt.debts, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allocatePenalties(%v, %d) = %v, want %v", tt.debts, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
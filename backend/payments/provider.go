@@ -0,0 +1,164 @@
+package payments
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrDeclined — провайдер отклонил платёж (недостаточно средств, карта заблокирована и т.п.)
+var ErrDeclined = errors.New("payment declined")
+
+// Provider — платёжный провайдер. Суммы в рублях.
+// Деньги пассажира сначала блокируются (Authorize), списываются после поездки (Capture),
+// а доля водителя перечисляется отдельной выплатой (Payout).
+// Повтор операции с тем же ключом идемпотентности (reference для Authorize и Payout)
+// должен возвращать результат первого вызова, а не выполнять операцию снова.
+type Provider interface {
+	Name() string
+	Authorize(payerID, amount int, reference string) (string, error)
+	Capture(authorizationID string, amount int, idempotencyKey string) error
+	Void(authorizationID, idempotencyKey string) error
+	Refund(authorizationID string, amount int, idempotencyKey string) error
+	Payout(recipientID, amount int, reference string) (string, error)
+}
+
+// FakeProvider хранит операции в памяти и пишет их в лог — для разработки и тестов.
+// Платежи больше DeclineAbove (если задан) отклоняются.
+// После перезапуска сервера память пуста, поэтому незнакомая блокировка считается
+// сделанной до перезапуска: её можно списать, снять или вернуть.
+type FakeProvider struct {
+	DeclineAbove int
+
+	mu      sync.Mutex
+	prefix  string // отличает ID этого запуска от выданных до перезапуска
+	seq     int
+	charges map[string]*fakeCharge
+	done    map[string]string // ключ идемпотентности → результат операции
+}
+
+type fakeCharge struct {
+	amount   int
+	captured int
+	refunded int
+	voided   bool
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		prefix:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		charges: map[string]*fakeCharge{},
+		done:    map[string]string{},
+	}
+}
+
+// charge возвращает блокировку; незнакомую (выданную до перезапуска) заводит на amount
+func (p *FakeProvider) charge(authorizationID string, amount int) *fakeCharge {
+	c, ok := p.charges[authorizationID]
+	if !ok {
+		log.Printf("💳 [fake] Блокировка %s не найдена в памяти, считаем её выданной до перезапуска", authorizationID)
+		c = &fakeCharge{amount: amount}
+		p.charges[authorizationID] = c
+	}
+	return c
+}
+
+func (p *FakeProvider) Name() string { return "fake" }
+
+func (p *FakeProvider) Authorize(payerID, amount int, reference string) (string, error) {
+	if p.DeclineAbove > 0 && amount > p.DeclineAbove {
+		return "", ErrDeclined
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.done[reference]; ok {
+		return id, nil
+	}
+	p.seq++
+	id := fmt.Sprintf("fake_auth_%s_%d", p.prefix, p.seq)
+	p.charges[id] = &fakeCharge{amount: amount}
+	p.done[reference] = id
+	log.Printf("💳 [fake] Блокировка %d ₽ у пользователя %d (%s): %s", amount, payerID, reference, id)
+	return id, nil
+}
+
+func (p *FakeProvider) Capture(authorizationID string, amount int, idempotencyKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.done[idempotencyKey]; ok {
+		return nil
+	}
+
+	charge := p.charge(authorizationID, amount)
+	if charge.voided || charge.captured > 0 {
+		return fmt.Errorf("authorization %s is already closed", authorizationID)
+	}
+	if amount > charge.amount {
+		return fmt.Errorf("capture amount %d exceeds authorized %d", amount, charge.amount)
+	}
+	charge.captured = amount
+	p.done[idempotencyKey] = authorizationID
+	log.Printf("💳 [fake] Списание %d ₽ по %s", amount, authorizationID)
+	return nil
+}
+
+func (p *FakeProvider) Void(authorizationID, idempotencyKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.done[idempotencyKey]; ok {
+		return nil
+	}
+
+	charge := p.charge(authorizationID, 0)
+	if charge.captured > 0 {
+		return fmt.Errorf("authorization %s is already captured", authorizationID)
+	}
+	charge.voided = true
+	p.done[idempotencyKey] = authorizationID
+	log.Printf("💳 [fake] Отмена блокировки %s", authorizationID)
+	return nil
+}
+
+func (p *FakeProvider) Refund(authorizationID string, amount int, idempotencyKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.done[idempotencyKey]; ok {
+		return nil
+	}
+
+	charge, ok := p.charges[authorizationID]
+	if !ok {
+		// Списание было до перезапуска; его сумма неизвестна, верим запросу
+		charge = p.charge(authorizationID, amount)
+		charge.captured = amount
+	}
+	if charge.refunded+amount > charge.captured {
+		return fmt.Errorf("refund amount %d exceeds captured %d", amount, charge.captured-charge.refunded)
+	}
+	charge.refunded += amount
+	p.done[idempotencyKey] = authorizationID
+	log.Printf("💳 [fake] Возврат %d ₽ по %s", amount, authorizationID)
+	return nil
+}
+
+func (p *FakeProvider) Payout(recipientID, amount int, reference string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id, ok := p.done[reference]; ok {
+		return id, nil
+	}
+	p.seq++
+	id := fmt.Sprintf("fake_payout_%s_%d", p.prefix, p.seq)
+	p.done[reference] = id
+	log.Printf("💳 [fake] Выплата %d ₽ пользователю %d (%s): %s", amount, recipientID, reference, id)
+	return id, nil
+}
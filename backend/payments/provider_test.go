@@ -0,0 +1,110 @@
+package payments
+
+import "testing"
+
+func TestFakeProviderCapture(t *testing.T) {
+	p := NewFakeProvider()
+
+	ref, err := p.Authorize(1, 1000, "booking-1")
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if again, _ := p.Authorize(1, 1000, "booking-1"); again != ref {
+		t.Errorf("repeated Authorize returned %s, want %s", again, ref)
+	}
+
+	if err := p.Capture(ref, 1000, "capture-1"); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if err := p.Capture(ref, 1000, "capture-1"); err != nil {
+		t.Errorf("repeated Capture with the same key: %v", err)
+	}
+	if err := p.Capture(ref, 1000, "capture-2"); err == nil {
+		t.Error("second Capture with a new key should fail")
+	}
+	if err := p.Void(ref, "void-1"); err == nil {
+		t.Error("Void after Capture should fail")
+	}
+}
+
+func TestFakeProviderCaptureLimits(t *testing.T) {
+	p := NewFakeProvider()
+	ref, _ := p.Authorize(1, 500, "booking-1")
+
+	if err := p.Capture(ref, 600, "capture-1"); err == nil {
+		t.Error("Capture above the authorized amount should fail")
+	}
+	if err := p.Capture(ref, 200, "capture-2"); err != nil {
+		t.Fatalf("partial Capture: %v", err)
+	}
+	if err := p.Refund(ref, 300, "refund-1"); err == nil {
+		t.Error("Refund above the captured amount should fail")
+	}
+	if err := p.Refund(ref, 200, "refund-2"); err != nil {
+		t.Errorf("Refund: %v", err)
+	}
+	if err := p.Refund(ref, 200, "refund-2"); err != nil {
+		t.Errorf("repeated Refund with the same key: %v", err)
+	}
+}
+
+func TestFakeProviderVoid(t *testing.T) {
+	p := NewFakeProvider()
+	ref, _ := p.Authorize(1, 500, "booking-1")
+
+	if err := p.Void(ref, "void-1"); err != nil {
+		t.Fatalf("Void: %v", err)
+	}
+	if err := p.Void(ref, "void-1"); err != nil {
+		t.Errorf("repeated Void with the same key: %v", err)
+	}
+	if err := p.Capture(ref, 500, "capture-1"); err == nil {
+		t.Error("Capture after Void should fail")
+	}
+}
+
+func TestFakeProviderDecline(t *testing.T) {
+	p := NewFakeProvider()
+	p.DeclineAbove = 1000
+
+	if _, err := p.Authorize(1, 1001, "booking-1"); err != ErrDeclined {
+		t.Errorf("Authorize above DeclineAbove = %v, want ErrDeclined", err)
+	}
+	if _, err := p.Authorize(1, 1000, "booking-2"); err != nil {
+		t.Errorf("Authorize at DeclineAbove: %v", err)
+	}
+}
+
+func TestFakeProviderAfterRestart(t *testing.T) {
+	before := NewFakeProvider()
+	captureRef, _ := before.Authorize(1, 700, "booking-1")
+	voidRef, _ := before.Authorize(2, 300, "booking-2")
+
+	after := NewFakeProvider()
+	if err := after.Capture(captureRef, 700, "capture-1"); err != nil {
+		t.Errorf("Capture of an authorization made before restart: %v", err)
+	}
+	if err := after.Void(voidRef, "void-1"); err != nil {
+		t.Errorf("Void of an authorization made before restart: %v", err)
+	}
+
+	ref, _ := after.Authorize(3, 100, "booking-3")
+	if ref == captureRef || ref == voidRef {
+		t.Errorf("authorization ID %s reused after restart", ref)
+	}
+}
+
+func TestFakeProviderPayout(t *testing.T) {
+	p := NewFakeProvider()
+
+	ref, err := p.Payout(5, 900, "payout-1")
+	if err != nil {
+		t.Fatalf("Payout: %v", err)
+	}
+	if again, _ := p.Payout(5, 900, "payout-1"); again != ref {
+		t.Errorf("repeated Payout returned %s, want %s", again, ref)
+	}
+	if other, _ := p.Payout(5, 900, "payout-2"); other == ref {
+		t.Error("different payouts got the same reference")
+	}
+}
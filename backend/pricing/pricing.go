@@ -0,0 +1,114 @@
+package pricing
+
+import (
+	"database/sql"
+	"hermes-carpooling/database"
+	"hermes-carpooling/geo"
+	"math"
+)
+
+// Settings — параметры расчёта стоимости поездки
+type Settings struct {
+	FuelPricePerLiter float64 // цена топлива, руб/л
+	FuelConsumption   float64 // расход, л/100 км
+	CeilingFactor     float64 // доля расходов, которую пассажиры могут покрыть в сумме (1.0 — не больше расходов)
+	FallbackMaxPrice  int     // потолок цены за место, если маршрут не удалось оценить, руб
+	HistoryDays       int     // за сколько дней учитывать цены на том же маршруте
+}
+
+// Config задаётся при старте сервера из конфигурации
+var Config = Settings{
+	FuelPricePerLiter: 60,
+	FuelConsumption:   8,
+	CeilingFactor:     1.0,
+	FallbackMaxPrice:  1500,
+	HistoryDays:       180,
+}
+
+// Suggestion — рекомендованная цена за место
+type Suggestion struct {
+	DistanceKm       *float64 `json:"distanceKm"`
+	FuelCost         *int     `json:"fuelCost"`
+	CostShare        *int     `json:"costShare"`
+	HistoricalMedian *int     `json:"historicalMedian"`
+	HistoricalCount  int      `json:"historicalCount"`
+	SuggestedPrice   *int     `json:"suggestedPrice"`
+	MaxPrice         *int     `json:"maxPrice"`
+}
+
+// Suggest рассчитывает рекомендованную цену за место для маршрута.
+// Доля расходов считается на всех в машине, включая водителя; потолок
+// не даёт пассажирам в сумме заплатить больше, чем стоит поездка.
+func Suggest(fromCity, toCity string, seats int) (Suggestion, error) {
+	var s Suggestion
+
+	if route, ok := geo.EstimateRoute(fromCity, toCity); ok {
+		fuelCost := fuelCostFor(route.DistanceKm)
+		costShare := roundTo10(fuelCost / float64(seats+1))
+		maxPrice := ceilingFor(fuelCost, seats)
+
+		distance := route.DistanceKm
+		fuel := int(math.Round(fuelCost))
+		s.DistanceKm = &distance
+		s.FuelCost = &fuel
+		s.CostShare = &costShare
+		s.MaxPrice = &maxPrice
+	} else {
+		maxPrice := Config.FallbackMaxPrice
+		s.MaxPrice = &maxPrice
+	}
+
+	var median sql.NullFloat64
+	err := database.DB.QueryRow(`
+		SELECT PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY price), COUNT(*)
+		FROM trips
+		WHERE LOWER(from_city) = LOWER($1) AND LOWER(to_city) = LOWER($2)
+		  AND status <> 'cancelled'
+		  AND created_at > NOW() - $3 * INTERVAL '1 day'
+	`, fromCity, toCity, Config.HistoryDays).Scan(&median, &s.HistoricalCount)
+	if err != nil {
+		return s, err
+	}
+
+	if median.Valid {
+		m := roundTo10(median.Float64)
+		s.HistoricalMedian = &m
+	}
+
+	// Рекомендация: доля расходов, скорректированная на сложившиеся цены маршрута
+	var suggested int
+	switch {
+	case s.CostShare != nil && s.HistoricalMedian != nil:
+		suggested = roundTo10(float64(*s.CostShare+*s.HistoricalMedian) / 2)
+	case s.CostShare != nil:
+		suggested = *s.CostShare
+	case s.HistoricalMedian != nil:
+		suggested = *s.HistoricalMedian
+	default:
+		return s, nil
+	}
+
+	if s.MaxPrice != nil && suggested > *s.MaxPrice {
+		suggested = *s.MaxPrice
+	}
+	s.SuggestedPrice = &suggested
+
+	return s, nil
+}
+
+// MaxPriceForRoute возвращает потолок цены за место для уже оценённого маршрута
+func MaxPriceForRoute(route geo.Route, seats int) int {
+	return ceilingFor(fuelCostFor(route.DistanceKm), seats)
+}
+
+func fuelCostFor(distanceKm float64) float64 {
+	return distanceKm * Config.FuelConsumption / 100 * Config.FuelPricePerLiter
+}
+
+func ceilingFor(fuelCost float64, seats int) int {
+	return int(math.Floor(fuelCost * Config.CeilingFactor / float64(seats)))
+}
+
+func roundTo10(v float64) int {
+	return int(math.Round(v/10) * 10)
+}
@@ -0,0 +1,138 @@
+package promotions
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// Типы скидки промокода
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+// Ошибки применения промокода; текст ошибки отдаётся клиенту
+var (
+	ErrPromoNotFound  = errors.New("promo code not found")
+	ErrPromoExpired   = errors.New("promo code has expired")
+	ErrPromoExhausted = errors.New("promo code usage limit reached")
+	ErrPromoUsed      = errors.New("promo code has already been used")
+	ErrPromoFirstRide = errors.New("promo code is valid for the first ride only")
+)
+
+// PromoCode — промокод со скидкой на бронирование
+type PromoCode struct {
+	ID            int        `json:"id"`
+	Code          string     `json:"code"`
+	DiscountType  string     `json:"discountType"`
+	DiscountValue int        `json:"discountValue"` // проценты или рубли
+	MaxDiscount   *int       `json:"maxDiscount"`   // потолок процентной скидки
+	UsageLimit    *int       `json:"usageLimit"`    // всего использований
+	PerUserLimit  int        `json:"perUserLimit"`
+	FirstRideOnly bool       `json:"firstRideOnly"`
+	ValidUntil    *time.Time `json:"validUntil"`
+	Active        bool       `json:"active"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// Discount — размер скидки для суммы, не больше самой суммы
+func (p PromoCode) Discount(subtotal int) int {
+	discount := p.DiscountValue
+	if p.DiscountType == DiscountPercent {
+		discount = int(math.Round(float64(subtotal) * float64(p.DiscountValue) / 100))
+		if p.MaxDiscount != nil && discount > *p.MaxDiscount {
+			discount = *p.MaxDiscount
+		}
+	}
+	if discount > subtotal {
+		return subtotal
+	}
+	return discount
+}
+
+// queryer — общий интерфейс *sql.DB и *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Check находит промокод и проверяет, что пользователь может его применить.
+// Использования считаются только по действующим бронированиям: при отмене промокод освобождается.
+// В транзакции строка промокода блокируется, чтобы лимит не превысили параллельные бронирования.
+func Check(q queryer, code string, userID int) (PromoCode, error) {
+	var p PromoCode
+	query := `
+		SELECT id, code, discount_type, discount_value, max_discount, usage_limit, per_user_limit,
+			first_ride_only, valid_until, active, created_at
+		FROM promo_codes WHERE UPPER(code) = UPPER($1)`
+	if _, ok := q.(*sql.Tx); ok {
+		query += " FOR UPDATE"
+	}
+
+	err := q.QueryRow(query, strings.TrimSpace(code)).Scan(&p.ID, &p.Code, &p.DiscountType, &p.DiscountValue,
+		&p.MaxDiscount, &p.UsageLimit, &p.PerUserLimit, &p.FirstRideOnly, &p.ValidUntil, &p.Active, &p.CreatedAt)
+	if err == sql.ErrNoRows || (err == nil && !p.Active) {
+		return p, ErrPromoNotFound
+	}
+	if err != nil {
+		return p, err
+	}
+
+	var total, byUser int
+	err = q.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE r.user_id = $2)
+		FROM promo_redemptions r
+		JOIN bookings b ON r.booking_id = b.id
+		WHERE r.promo_code_id = $1 AND b.status <> 'cancelled'
+	`, p.ID, userID).Scan(&total, &byUser)
+	if err != nil {
+		return p, err
+	}
+
+	var hasRides bool
+	if p.FirstRideOnly {
+		err = q.QueryRow(`
+			SELECT EXISTS (SELECT 1 FROM bookings WHERE passenger_id = $1 AND status IN ('pending', 'confirmed'))
+		`, userID).Scan(&hasRides)
+		if err != nil {
+			return p, err
+		}
+	}
+
+	return p, p.usable(time.Now(), total, byUser, hasRides)
+}
+
+// usable применяет ограничения промокода: total и byUser — действующие использования
+// всего и этим пользователем, hasRides — есть ли у пользователя действующие бронирования
+func (p PromoCode) usable(now time.Time, total, byUser int, hasRides bool) error {
+	if p.ValidUntil != nil && p.ValidUntil.Before(now) {
+		return ErrPromoExpired
+	}
+	if p.UsageLimit != nil && total >= *p.UsageLimit {
+		return ErrPromoExhausted
+	}
+	if byUser >= p.PerUserLimit {
+		return ErrPromoUsed
+	}
+	if p.FirstRideOnly && hasRides {
+		return ErrPromoFirstRide
+	}
+	return nil
+}
+
+// Redeem записывает использование промокода бронированием
+func Redeem(tx *sql.Tx, promoID, userID, bookingID, discount int) error {
+	_, err := tx.Exec(`
+		INSERT INTO promo_redemptions (promo_code_id, user_id, booking_id, discount)
+		VALUES ($1, $2, $3, $4)
+	`, promoID, userID, bookingID, discount)
+	return err
+}
+
+// IsUserError — ошибка относится к самому промокоду, а не к базе
+func IsUserError(err error) bool {
+	return err == ErrPromoNotFound || err == ErrPromoExpired || err == ErrPromoExhausted ||
+		err == ErrPromoUsed || err == ErrPromoFirstRide
+}
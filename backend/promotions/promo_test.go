@@ -0,0 +1,64 @@
+package promotions
+
+import (
+	"testing"
+	"time"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestPromoCodeDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		promo    PromoCode
+		subtotal int
+		want     int
+	}{
+		{"percent", PromoCode{DiscountType: DiscountPercent, DiscountValue: 10}, 1500, 150},
+		{"percent rounded", PromoCode{DiscountType: DiscountPercent, DiscountValue: 15}, 999, 150},
+		{"percent capped", PromoCode{DiscountType: DiscountPercent, DiscountValue: 50, MaxDiscount: intPtr(300)}, 1000, 300},
+		{"percent below cap", PromoCode{DiscountType: DiscountPercent, DiscountValue: 10, MaxDiscount: intPtr(300)}, 1000, 100},
+		{"fixed", PromoCode{DiscountType: DiscountFixed, DiscountValue: 200}, 1000, 200},
+		{"fixed above subtotal", PromoCode{DiscountType: DiscountFixed, DiscountValue: 500}, 300, 300},
+		{"hundred percent", PromoCode{DiscountType: DiscountPercent, DiscountValue: 100}, 700, 700},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.Discount(tt.subtotal); got != tt.want {
+				t.Errorf("Discount(%d) = %d, want %d", tt.subtotal, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoCodeUsable(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		promo    PromoCode
+		total    int
+		byUser   int
+		hasRides bool
+		want     error
+	}{
+		{"usable", PromoCode{PerUserLimit: 1, ValidUntil: &future}, 0, 0, false, nil},
+		{"no expiry", PromoCode{PerUserLimit: 1}, 0, 0, false, nil},
+		{"expired", PromoCode{PerUserLimit: 1, ValidUntil: &past}, 0, 0, false, ErrPromoExpired},
+		{"usage limit reached", PromoCode{PerUserLimit: 1, UsageLimit: intPtr(10)}, 10, 0, false, ErrPromoExhausted},
+		{"under usage limit", PromoCode{PerUserLimit: 1, UsageLimit: intPtr(10)}, 9, 0, false, nil},
+		{"already used", PromoCode{PerUserLimit: 1}, 3, 1, false, ErrPromoUsed},
+		{"per user limit above one", PromoCode{PerUserLimit: 3}, 3, 2, false, nil},
+		{"first ride with rides", PromoCode{PerUserLimit: 1, FirstRideOnly: true}, 0, 0, true, ErrPromoFirstRide},
+		{"first ride without rides", PromoCode{PerUserLimit: 1, FirstRideOnly: true}, 0, 0, false, nil},
+		{"expiry checked first", PromoCode{PerUserLimit: 1, ValidUntil: &past, UsageLimit: intPtr(1)}, 1, 1, false, ErrPromoExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.usable(now, tt.total, tt.byUser, tt.hasRides); got != tt.want {
+				t.Errorf("usable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package promotions
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"hermes-carpooling/database"
+	"log"
+	"strings"
+)
+
+// ReferralBonus — сколько рублей получают на кошелёк пригласивший и приглашённый
+// после первой завершённой поездки приглашённого
+var ReferralBonus = 200
+
+// Алфавит кодов без похожих символов (0/O, 1/I)
+const referralAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NewReferralCode генерирует реферальный код пользователя
+func NewReferralCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = referralAlphabet[int(b[i])%len(referralAlphabet)]
+	}
+	return string(b), nil
+}
+
+// FindReferrer возвращает владельца реферального кода
+func FindReferrer(code string) (int, bool) {
+	var userID int
+	err := database.DB.QueryRow(`
+		SELECT id FROM users WHERE referral_code = $1
+	`, strings.ToUpper(strings.TrimSpace(code))).Scan(&userID)
+	return userID, err == nil
+}
+
+// RewardReferral начисляет бонус обоим участникам, если пользователь пришёл
+// по приглашению и уже совершил поездку. Бонус начисляется один раз.
+func RewardReferral(userID int) error {
+	var referrerID sql.NullInt64
+	var completedTrips int
+	err := database.DB.QueryRow(`
+		SELECT u.referred_by,
+			(SELECT COUNT(*) FROM trips t WHERE t.driver_id = u.id AND t.status = 'completed') +
+			(SELECT COUNT(*) FROM bookings b JOIN trips t ON b.trip_id = t.id
+			 WHERE b.passenger_id = u.id AND b.status = 'confirmed' AND t.status = 'completed')
+		FROM users u WHERE u.id = $1
+	`, userID).Scan(&referrerID, &completedTrips)
+	if err != nil {
+		return err
+	}
+
+	if !referrerID.Valid || completedTrips == 0 || ReferralBonus <= 0 {
+		return nil
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO referral_rewards (referee_id, referrer_id, amount)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (referee_id) DO NOTHING
+	`, userID, referrerID.Int64, ReferralBonus)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO wallet_transactions (user_id, amount, kind, description)
+		VALUES ($1, $3, $4, 'Бонус за приглашённого друга'),
+		       ($2, $3, $4, 'Бонус за первую поездку по приглашению')
+	`, referrerID.Int64, userID, ReferralBonus, WalletReferralBonus)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("🎁 Реферальный бонус: %d ₽ пользователям %d и %d", ReferralBonus, referrerID.Int64, userID)
+	return nil
+}
+
+// ReferralStats — реферальный код пользователя и результаты приглашений
+type ReferralStats struct {
+	Code     string `json:"code"`
+	Invited  int    `json:"invited"`
+	Rewarded int    `json:"rewarded"`
+	Earned   int    `json:"earned"`
+	Bonus    int    `json:"bonus"`
+}
+
+// Stats возвращает статистику приглашений пользователя
+func Stats(userID int) (ReferralStats, error) {
+	s := ReferralStats{Bonus: ReferralBonus}
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(u.referral_code, ''),
+			(SELECT COUNT(*) FROM users WHERE referred_by = u.id),
+			(SELECT COUNT(*) FROM referral_rewards WHERE referrer_id = u.id),
+			(SELECT COALESCE(SUM(amount), 0) FROM referral_rewards WHERE referrer_id = u.id)
+		FROM users u WHERE u.id = $1
+	`, userID).Scan(&s.Code, &s.Invited, &s.Rewarded, &s.Earned)
+	return s, err
+}
@@ -0,0 +1,155 @@
+package promotions
+
+import (
+	"database/sql"
+	"hermes-carpooling/cancellation"
+	"hermes-carpooling/database"
+	"time"
+)
+
+// Виды операций по кошельку
+const (
+	WalletReferralBonus = "referral_bonus"
+	WalletBookingDebit  = "booking_payment"
+	WalletBookingRefund = "booking_refund"
+)
+
+// WalletTransaction — операция по бонусному кошельку (amount > 0 — начисление)
+type WalletTransaction struct {
+	ID          int       `json:"id"`
+	Amount      int       `json:"amount"`
+	Kind        string    `json:"kind"`
+	BookingID   *int      `json:"bookingId"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Balance — текущий баланс кошелька
+func Balance(q queryer, userID int) (int, error) {
+	var balance int
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0) FROM wallet_transactions WHERE user_id = $1
+	`, userID).Scan(&balance)
+	return balance, err
+}
+
+// Debit списывает с кошелька до amount рублей в счёт бронирования и возвращает
+// фактически списанную сумму. Строка пользователя блокируется, чтобы параллельные
+// бронирования не потратили один и тот же баланс дважды.
+func Debit(tx *sql.Tx, userID, bookingID, amount int) (int, error) {
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.Exec(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return 0, err
+	}
+
+	balance, err := Balance(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if balance < amount {
+		amount = balance
+	}
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO wallet_transactions (user_id, amount, kind, booking_id, description)
+		VALUES ($1, $2, $3, $4, 'Оплата бронирования')
+	`, userID, -amount, WalletBookingDebit, bookingID)
+	if err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// RefundBooking возвращает на кошелёк сумму, списанную за отменённое бронирование,
+// за вычетом части удержания fee, которую не покрыла оплата картой.
+// Повторный вызов ничего не делает: возврат по бронированию уникален на уровне схемы.
+func RefundBooking(bookingID, fee int) error {
+	var userID, debit, cardAmount int
+	err := database.DB.QueryRow(`
+		SELECT w.user_id, -w.amount,
+		       COALESCE((SELECT amount FROM payments WHERE booking_id = w.booking_id ORDER BY id DESC LIMIT 1), 0)
+		FROM wallet_transactions w
+		WHERE w.booking_id = $1 AND w.kind = $2
+	`, bookingID, WalletBookingDebit).Scan(&userID, &debit, &cardAmount)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, walletFee := cancellation.SplitFee(fee, cardAmount, debit)
+	refund := debit - walletFee
+	if refund <= 0 {
+		return nil
+	}
+
+	_, err = database.DB.Exec(`
+		INSERT INTO wallet_transactions (user_id, amount, kind, booking_id, description)
+		VALUES ($1, $2, $3, $4, 'Возврат за отменённое бронирование')
+		ON CONFLICT (booking_id) WHERE kind = 'booking_refund' DO NOTHING
+	`, userID, refund, WalletBookingRefund, bookingID)
+	return err
+}
+
+// RefundTrip возвращает кошельковые оплаты всех отменённых бронирований поездки
+func RefundTrip(tripID int) error {
+	rows, err := database.DB.Query(`
+		SELECT id FROM bookings WHERE trip_id = $1 AND status = 'cancelled' AND wallet_amount > 0
+	`, tripID)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := RefundBooking(id, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History — последние операции по кошельку
+func History(userID int) ([]WalletTransaction, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, amount, kind, booking_id, COALESCE(description, ''), created_at
+		FROM wallet_transactions
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 100
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []WalletTransaction{}
+	for rows.Next() {
+		var t WalletTransaction
+		if err := rows.Scan(&t.ID, &t.Amount, &t.Kind, &t.BookingID, &t.Description, &t.CreatedAt); err != nil {
+			continue
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
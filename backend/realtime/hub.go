@@ -0,0 +1,86 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+// Event — событие, отправляемое клиенту в реальном времени
+type Event struct {
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// Типы событий
+const (
+	BookingCreated       = "booking.created"
+	BookingStatusChanged = "booking.status_changed"
+	TripCancelled        = "trip.cancelled"
+	MessageCreated       = "message.created"
+)
+
+// Hub раздаёт события подписчикам; у одного пользователя может быть
+// несколько подключений (вкладки, устройства)
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[int]map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[int]map[chan Event]struct{})}
+}
+
+// Default — хаб, используемый сервером
+var Default = NewHub()
+
+// Subscribe регистрирует новое подключение пользователя.
+// Вызывающий обязан вызвать unsubscribe при закрытии подключения.
+func (h *Hub) Subscribe(userID int) (events <-chan Event, unsubscribe func()) {
+	ch := make(chan Event, 16)
+
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if subs, ok := h.subscribers[userID]; ok {
+			delete(subs, ch)
+			if len(subs) == 0 {
+				delete(h.subscribers, userID)
+			}
+		}
+	}
+}
+
+// Publish отправляет событие всем подключениям пользователя.
+// Медленный клиент с заполненным буфером пропускает событие, а не блокирует отправителя.
+func (h *Hub) Publish(userID int, eventType string, data interface{}) {
+	event := Event{Type: eventType, Data: data, CreatedAt: time.Now().UTC()}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Online сообщает, есть ли у пользователя активные подключения
+func (h *Hub) Online(userID int) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers[userID]) > 0
+}
+
+// Publish отправляет событие через хаб по умолчанию
+func Publish(userID int, eventType string, data interface{}) {
+	Default.Publish(userID, eventType, data)
+}
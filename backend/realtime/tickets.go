@@ -0,0 +1,54 @@
+package realtime
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"hermes-carpooling/database"
+	"time"
+)
+
+// TicketTTL — сколько действует билет на подключение к потоку событий
+const TicketTTL = time.Minute
+
+// ErrInvalidTicket — билет не найден, уже использован или истёк
+var ErrInvalidTicket = errors.New("invalid or expired stream ticket")
+
+// IssueTicket выдаёт одноразовый короткоживущий билет на подключение к потоку.
+// EventSource не умеет передавать заголовки, а JWT в адресе попал бы в логи.
+func IssueTicket(userID int) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	ticket := hex.EncodeToString(buf)
+
+	// Заодно убираем истёкшие билеты
+	if _, err := database.DB.Exec(`DELETE FROM stream_tickets WHERE expires_at < NOW()`); err != nil {
+		return "", err
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO stream_tickets (ticket, user_id, expires_at)
+		VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second')
+	`, ticket, userID, TicketTTL.Seconds())
+	if err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// RedeemTicket погашает билет и возвращает его владельца
+func RedeemTicket(ticket string) (int, error) {
+	var userID int
+	err := database.DB.QueryRow(`
+		DELETE FROM stream_tickets
+		WHERE ticket = $1 AND expires_at > NOW()
+		RETURNING user_id
+	`, ticket).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidTicket
+	}
+	return userID, err
+}
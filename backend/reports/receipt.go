@@ -0,0 +1,113 @@
+package reports
+
+import (
+	"fmt"
+	"hermes-carpooling/database"
+	"hermes-carpooling/geo"
+	"html/template"
+	"io"
+	"time"
+)
+
+// Receipt — квитанция по бронированию завершённой поездки
+type Receipt struct {
+	Number        string    `json:"number"`
+	BookingID     int       `json:"bookingId"`
+	TripID        int       `json:"tripId"`
+	FromCity      string    `json:"fromCity"`
+	ToCity        string    `json:"toCity"`
+	DepartureAt   time.Time `json:"departureAt"`
+	DepartureLoc  string    `json:"departureLocal"`
+	DriverID      int       `json:"driverId"`
+	DriverName    string    `json:"driverName"`
+	DriverCar     string    `json:"driverCar"`
+	PassengerID   int       `json:"passengerId"`
+	PassengerName string    `json:"passengerName"`
+	Seats         int       `json:"seats"`
+	PricePerSeat  int       `json:"pricePerSeat"`
+	Subtotal      int       `json:"subtotal"`
+	Discount      int       `json:"discount"`
+	WalletAmount  int       `json:"walletAmount"`
+	PaidByCard    int       `json:"paidByCard"`
+	PaymentStatus string    `json:"paymentStatus"`
+	IssuedAt      time.Time `json:"issuedAt"`
+}
+
+// LoadReceipt собирает квитанцию. ok = false, если бронирование не подтверждено
+// или поездка ещё не состоялась; для несуществующего бронирования — sql.ErrNoRows.
+func LoadReceipt(bookingID int) (Receipt, bool, error) {
+	var r Receipt
+	var status, tripStatus, timezone string
+	err := database.DB.QueryRow(`
+		SELECT b.id, b.trip_id, t.from_city, t.to_city, t.departure_at, t.timezone,
+		       t.driver_id, d.full_name, TRIM(CONCAT(d.car_brand, ' ', d.car_model)),
+		       b.passenger_id, p.full_name, b.seats_booked, t.price,
+		       b.total_price, b.discount_amount, b.wallet_amount, b.payment_status,
+		       b.status, t.status
+		FROM bookings b
+		JOIN trips t ON b.trip_id = t.id
+		JOIN users d ON t.driver_id = d.id
+		JOIN users p ON b.passenger_id = p.id
+		WHERE b.id = $1
+	`, bookingID).Scan(&r.BookingID, &r.TripID, &r.FromCity, &r.ToCity, &r.DepartureAt, &timezone,
+		&r.DriverID, &r.DriverName, &r.DriverCar,
+		&r.PassengerID, &r.PassengerName, &r.Seats, &r.PricePerSeat,
+		&r.Subtotal, &r.Discount, &r.WalletAmount, &r.PaymentStatus,
+		&status, &tripStatus)
+	if err != nil {
+		return r, false, err
+	}
+
+	if status != "confirmed" || tripStatus != "completed" {
+		return r, false, nil
+	}
+
+	r.Number = fmt.Sprintf("HC-%08d", r.BookingID)
+	r.DepartureAt = r.DepartureAt.UTC()
+	r.DepartureLoc = geo.LocalTime(r.DepartureAt, timezone).Format("02.01.2006 15:04")
+	r.PaidByCard = r.Subtotal - r.Discount - r.WalletAmount
+	r.IssuedAt = time.Now().UTC()
+	return r, true, nil
+}
+
+var receiptTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Квитанция {{.Number}}</title>
+<style>
+body { font-family: Arial, sans-serif; max-width: 640px; margin: 40px auto; color: #222; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+.muted { color: #777; font-size: 13px; }
+table { width: 100%; border-collapse: collapse; margin-top: 24px; }
+td { padding: 8px 0; border-bottom: 1px solid #eee; }
+td.amount { text-align: right; }
+tr.total td { font-weight: bold; border-bottom: none; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Квитанция {{.Number}}</h1>
+<div class="muted">Hermes Carpooling · выдана {{.IssuedAt.Format "02.01.2006 15:04"}} UTC</div>
+
+<table>
+<tr><td>Маршрут</td><td class="amount">{{.FromCity}} → {{.ToCity}}</td></tr>
+<tr><td>Отправление</td><td class="amount">{{.DepartureLoc}}</td></tr>
+<tr><td>Водитель</td><td class="amount">{{.DriverName}}{{if .DriverCar}}, {{.DriverCar}}{{end}}</td></tr>
+<tr><td>Пассажир</td><td class="amount">{{.PassengerName}}</td></tr>
+</table>
+
+<table>
+<tr><td>Мест: {{.Seats}} × {{.PricePerSeat}} ₽</td><td class="amount">{{.Subtotal}} ₽</td></tr>
+{{if .Discount}}<tr><td>Скидка по промокоду</td><td class="amount">−{{.Discount}} ₽</td></tr>{{end}}
+{{if .WalletAmount}}<tr><td>Оплачено бонусами</td><td class="amount">−{{.WalletAmount}} ₽</td></tr>{{end}}
+<tr class="total"><td>Оплачено картой</td><td class="amount">{{.PaidByCard}} ₽</td></tr>
+</table>
+</body>
+</html>
+`))
+
+// WriteReceiptHTML выводит квитанцию в виде страницы для печати или сохранения в PDF
+func WriteReceiptHTML(w io.Writer, r Receipt) error {
+	return receiptTemplate.Execute(w, r)
+}
@@ -0,0 +1,237 @@
+package reports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"hermes-carpooling/database"
+	"hermes-carpooling/payments"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Periods — допустимые периоды группировки (аргумент date_trunc)
+var Periods = []string{"day", "week", "month", "year"}
+
+// ValidPeriod проверяет период группировки
+func ValidPeriod(period string) bool {
+	for _, p := range Periods {
+		if p == period {
+			return true
+		}
+	}
+	return false
+}
+
+// Range — интервал отчёта [From, To)
+type Range struct {
+	From   time.Time
+	To     time.Time
+	Period string
+}
+
+// EarningsRow — заработок водителя за период: оплата за завершённые поездки и
+// удержания за поздние отмены пассажиров за вычетом комиссии сервиса и штрафов
+// водителя за поздние отмены поездок
+type EarningsRow struct {
+	Period           string  `json:"period"`
+	Trips            int     `json:"trips"`
+	SeatsOffered     int     `json:"seatsOffered"`
+	SeatsSold        int     `json:"seatsSold"`
+	OccupancyRate    float64 `json:"occupancyRate"`    // доля проданных мест, 0..1
+	Fares            int     `json:"fares"`            // списано за подтверждённые бронирования, включая субсидию сервиса
+	CancellationFees int     `json:"cancellationFees"` // удержано с пассажиров за поздние отмены
+	PlatformFee      int     `json:"platformFee"`
+	Penalties        int     `json:"penalties"`
+	Earnings         int     `json:"earnings"`
+}
+
+// settle считает комиссию сервиса и чистый заработок так же, как выплата водителю
+func (row *EarningsRow) settle() {
+	row.PlatformFee = payments.PlatformFee(row.Fares + row.CancellationFees)
+	row.Earnings = row.Fares + row.CancellationFees - row.PlatformFee - row.Penalties
+}
+
+// add прибавляет период к итогу
+func (row *EarningsRow) add(other EarningsRow) {
+	row.Trips += other.Trips
+	row.SeatsOffered += other.SeatsOffered
+	row.SeatsSold += other.SeatsSold
+	row.Fares += other.Fares
+	row.CancellationFees += other.CancellationFees
+	row.PlatformFee += other.PlatformFee
+	row.Penalties += other.Penalties
+	row.Earnings += other.Earnings
+}
+
+// SpendingRow — расходы пассажира за период по совершённым поездкам
+type SpendingRow struct {
+	Period   string `json:"period"`
+	Trips    int    `json:"trips"`
+	Seats    int    `json:"seats"`
+	Spent    int    `json:"spent"`
+	Discount int    `json:"discount"`
+}
+
+// periodLabel — подпись периода: 2024-05, 2024-05-13 и т.п.
+func periodLabel(t time.Time, period string) string {
+	switch period {
+	case "year":
+		return t.Format("2006")
+	case "month":
+		return t.Format("2006-01")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// Earnings — отчёт водителя. Периоды считаются по местному времени отправления.
+// Удержания и штрафы относятся к периоду поездки, даже если она не состоялась.
+// Доход считается по списанным платежам, как и выплаты: с карты и субсидией сервиса.
+func Earnings(driverID int, r Range) ([]EarningsRow, EarningsRow, error) {
+	rows, err := database.DB.Query(`
+		SELECT DATE_TRUNC($1, t.departure_at AT TIME ZONE t.timezone) AS period,
+		       COUNT(*) FILTER (WHERE t.status = 'completed'),
+		       COALESCE(SUM(t.seats) FILTER (WHERE t.status = 'completed'), 0),
+		       COALESCE(SUM(s.seats_sold) FILTER (WHERE t.status = 'completed'), 0),
+		       COALESCE(SUM(s.fares) FILTER (WHERE t.status = 'completed'), 0),
+		       COALESCE(SUM(f.fees), 0),
+		       COALESCE(SUM(pen.penalty), 0)
+		FROM trips t
+		LEFT JOIN (
+			SELECT b.trip_id, SUM(b.seats_booked) AS seats_sold,
+			       SUM(p.captured_amount - p.refunded_amount + p.wallet_fee + p.subsidy)
+			           FILTER (WHERE p.status IN ($5, $6)) AS fares
+			FROM bookings b
+			LEFT JOIN payments p ON p.booking_id = b.id
+			WHERE b.status = 'confirmed'
+			GROUP BY b.trip_id
+		) s ON s.trip_id = t.id
+		LEFT JOIN (
+			SELECT b.trip_id, SUM(p.captured_amount - p.refunded_amount + p.wallet_fee) AS fees
+			FROM payments p
+			JOIN bookings b ON b.id = p.booking_id
+			WHERE b.status = 'cancelled'
+			GROUP BY b.trip_id
+		) f ON f.trip_id = t.id
+		LEFT JOIN (
+			SELECT trip_id, SUM(fee) AS penalty
+			FROM cancellations
+			WHERE party = 'driver' AND booking_id IS NULL
+			GROUP BY trip_id
+		) pen ON pen.trip_id = t.id
+		WHERE t.driver_id = $2
+		  AND (t.status = 'completed' OR f.fees > 0 OR pen.penalty > 0)
+		  AND t.departure_at >= $3 AND t.departure_at < $4
+		GROUP BY 1
+		ORDER BY 1
+	`, r.Period, driverID, r.From, r.To, payments.StatusCaptured, payments.StatusPartiallyRefunded)
+	if err != nil {
+		return nil, EarningsRow{}, err
+	}
+	defer rows.Close()
+
+	result := []EarningsRow{}
+	total := EarningsRow{Period: "total"}
+	for rows.Next() {
+		var row EarningsRow
+		var period time.Time
+		err := rows.Scan(&period, &row.Trips, &row.SeatsOffered, &row.SeatsSold,
+			&row.Fares, &row.CancellationFees, &row.Penalties)
+		if err != nil {
+			return nil, total, err
+		}
+		row.Period = periodLabel(period, r.Period)
+		row.OccupancyRate = occupancy(row.SeatsSold, row.SeatsOffered)
+		row.settle()
+		result = append(result, row)
+		total.add(row)
+	}
+	total.OccupancyRate = occupancy(total.SeatsSold, total.SeatsOffered)
+	return result, total, rows.Err()
+}
+
+// Spending — отчёт пассажира: подтверждённые бронирования завершённых поездок.
+// Потрачено — стоимость за вычетом скидки по промокоду.
+func Spending(passengerID int, r Range) ([]SpendingRow, SpendingRow, error) {
+	rows, err := database.DB.Query(`
+		SELECT DATE_TRUNC($1, t.departure_at AT TIME ZONE t.timezone) AS period,
+		       COUNT(*), SUM(b.seats_booked), SUM(b.total_price - b.discount_amount), SUM(b.discount_amount)
+		FROM bookings b
+		JOIN trips t ON b.trip_id = t.id
+		WHERE b.passenger_id = $2 AND b.status = 'confirmed' AND t.status = 'completed'
+		  AND t.departure_at >= $3 AND t.departure_at < $4
+		GROUP BY 1
+		ORDER BY 1
+	`, r.Period, passengerID, r.From, r.To)
+	if err != nil {
+		return nil, SpendingRow{}, err
+	}
+	defer rows.Close()
+
+	result := []SpendingRow{}
+	total := SpendingRow{Period: "total"}
+	for rows.Next() {
+		var row SpendingRow
+		var period time.Time
+		if err := rows.Scan(&period, &row.Trips, &row.Seats, &row.Spent, &row.Discount); err != nil {
+			return nil, total, err
+		}
+		row.Period = periodLabel(period, r.Period)
+		result = append(result, row)
+
+		total.Trips += row.Trips
+		total.Seats += row.Seats
+		total.Spent += row.Spent
+		total.Discount += row.Discount
+	}
+	return result, total, rows.Err()
+}
+
+func occupancy(sold, offered int) float64 {
+	if offered == 0 {
+		return 0
+	}
+	return math.Round(float64(sold)/float64(offered)*1000) / 1000
+}
+
+// WriteEarningsCSV выгружает отчёт водителя в CSV
+func WriteEarningsCSV(w io.Writer, rows []EarningsRow, total EarningsRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period", "trips", "seats_offered", "seats_sold", "occupancy_rate",
+		"fares", "cancellation_fees", "platform_fee", "penalties", "earnings"})
+	for _, row := range append(rows, total) {
+		cw.Write([]string{
+			row.Period,
+			strconv.Itoa(row.Trips),
+			strconv.Itoa(row.SeatsOffered),
+			strconv.Itoa(row.SeatsSold),
+			fmt.Sprintf("%.3f", row.OccupancyRate),
+			strconv.Itoa(row.Fares),
+			strconv.Itoa(row.CancellationFees),
+			strconv.Itoa(row.PlatformFee),
+			strconv.Itoa(row.Penalties),
+			strconv.Itoa(row.Earnings),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteSpendingCSV выгружает отчёт пассажира в CSV
+func WriteSpendingCSV(w io.Writer, rows []SpendingRow, total SpendingRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period", "trips", "seats", "spent", "discount"})
+	for _, row := range append(rows, total) {
+		cw.Write([]string{
+			row.Period,
+			strconv.Itoa(row.Trips),
+			strconv.Itoa(row.Seats),
+			strconv.Itoa(row.Spent),
+			strconv.Itoa(row.Discount),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,64 @@
+package reports
+
+import (
+	"hermes-carpooling/payments"
+	"testing"
+)
+
+func TestEarningsRowSettle(t *testing.T) {
+	saved := payments.Config
+	defer func() { payments.Config = saved }()
+	payments.Config.PlatformFeePercent = 10
+
+	// Одно подтверждённое бронирование завершённой поездки на 2000 ₽, одна поздняя
+	// отмена пассажиром с удержанием 500 ₽ и штраф водителя 300 ₽ за отменённую поездку
+	row := EarningsRow{Fares: 2000, CancellationFees: 500, Penalties: 300}
+	row.settle()
+
+	if row.PlatformFee != 250 {
+		t.Errorf("PlatformFee = %d, want 250", row.PlatformFee)
+	}
+	if row.Earnings != 1950 {
+		t.Errorf("Earnings = %d, want 1950", row.Earnings)
+	}
+}
+
+func TestEarningsRowSettleKinds(t *testing.T) {
+	saved := payments.Config
+	defer func() { payments.Config = saved }()
+	payments.Config.PlatformFeePercent = 10
+
+	tests := []struct {
+		name            string
+		row             EarningsRow
+		wantPlatformFee int
+		wantEarnings    int
+	}{
+		{"confirmed booking", EarningsRow{Fares: 1500}, 150, 1350},
+		{"late cancellation fee only", EarningsRow{CancellationFees: 400}, 40, 360},
+		{"driver penalty only", EarningsRow{Penalties: 200}, 0, -200},
+		{"nothing", EarningsRow{}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := tt.row
+			row.settle()
+			if row.PlatformFee != tt.wantPlatformFee || row.Earnings != tt.wantEarnings {
+				t.Errorf("settle() = fee %d, earnings %d; want %d, %d",
+					row.PlatformFee, row.Earnings, tt.wantPlatformFee, tt.wantEarnings)
+			}
+		})
+	}
+}
+
+func TestEarningsRowAdd(t *testing.T) {
+	total := EarningsRow{Period: "total"}
+	total.add(EarningsRow{Trips: 1, SeatsOffered: 3, SeatsSold: 2, Fares: 2000, PlatformFee: 200, Earnings: 1800})
+	total.add(EarningsRow{CancellationFees: 500, PlatformFee: 50, Penalties: 300, Earnings: 150})
+
+	want := EarningsRow{Period: "total", Trips: 1, SeatsOffered: 3, SeatsSold: 2,
+		Fares: 2000, CancellationFees: 500, PlatformFee: 250, Penalties: 300, Earnings: 1950}
+	if total != want {
+		t.Errorf("total = %+v, want %+v", total, want)
+	}
+}
@@ -0,0 +1,163 @@
+package reviews
+
+import (
+	"database/sql"
+	"errors"
+	"hermes-carpooling/database"
+	"hermes-carpooling/models"
+	"time"
+)
+
+// WindowDays — сколько дней после завершения поездки можно оставить или изменить отзыв
+var WindowDays = 14
+
+// Ошибки проверки права на отзыв; текст ошибки отдаётся клиенту
+var (
+	ErrTripNotFound     = errors.New("trip not found")
+	ErrTripNotCompleted = errors.New("reviews are allowed only after the trip is completed")
+	ErrWindowClosed     = errors.New("review window for this trip has closed")
+	ErrNotParticipant   = errors.New("you didn't participate in this trip")
+	ErrNotTripPartner   = errors.New("you can only review the driver or a passenger you travelled with")
+	ErrAlreadyReviewed  = errors.New("review already exists")
+	ErrPublished        = errors.New("published reviews can no longer be edited")
+	ErrInvalidDetails   = errors.New("invalid review details")
+)
+
+// queryer — общий интерфейс *sql.DB и *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Deadline — до какого момента открыто окно отзывов по поездке
+func Deadline(completedAt time.Time) time.Time {
+	return completedAt.AddDate(0, 0, WindowDays)
+}
+
+// CheckWindow проверяет, что поездка завершена и окно отзывов ещё не закрылось
+func CheckWindow(q queryer, tripID int) error {
+	var status string
+	var completedAt sql.NullTime
+	err := q.QueryRow(`
+		SELECT status, completed_at FROM trips WHERE id = $1
+	`, tripID).Scan(&status, &completedAt)
+	if err == sql.ErrNoRows {
+		return ErrTripNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if status != "completed" || !completedAt.Valid {
+		return ErrTripNotCompleted
+	}
+	if time.Now().After(Deadline(completedAt.Time)) {
+		return ErrWindowClosed
+	}
+	return nil
+}
+
+// CheckEligibility проверяет, что author может оставить отзыв о target по поездке:
+// поездка завершена, окно отзывов открыто, и они ехали вместе как водитель и
+// пассажир с подтверждённым бронированием (в любую сторону).
+// Возвращает роль адресата в поездке: models.ReviewTargetDriver или models.ReviewTargetPassenger.
+func CheckEligibility(q queryer, tripID, authorID, targetID int) (string, error) {
+	if err := CheckWindow(q, tripID); err != nil {
+		return "", err
+	}
+
+	var driverID int
+	var authorRode, targetRode bool
+	err := q.QueryRow(`
+		SELECT t.driver_id,
+			EXISTS (SELECT 1 FROM bookings WHERE trip_id = t.id AND passenger_id = $2 AND status = 'confirmed'),
+			EXISTS (SELECT 1 FROM bookings WHERE trip_id = t.id AND passenger_id = $3 AND status = 'confirmed')
+		FROM trips t WHERE t.id = $1
+	`, tripID, authorID, targetID).Scan(&driverID, &authorRode, &targetRode)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case authorID != driverID && !authorRode:
+		return "", ErrNotParticipant
+	case authorID == driverID && !targetRode,
+		authorID != driverID && targetID != driverID:
+		return "", ErrNotTripPartner
+	}
+
+	targetRole := models.ReviewTargetPassenger
+	if targetID == driverID {
+		targetRole = models.ReviewTargetDriver
+	}
+
+	var exists bool
+	err = q.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM reviews WHERE trip_id = $1 AND author_id = $2 AND target_id = $3)
+	`, tripID, authorID, targetID).Scan(&exists)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", ErrAlreadyReviewed
+	}
+	return targetRole, nil
+}
+
+// IsUserError — ошибка относится к праву на отзыв или его содержимому, а не к базе
+func IsUserError(err error) bool {
+	return errors.Is(err, ErrInvalidDetails) || err == ErrTripNotFound || err == ErrTripNotCompleted || err == ErrWindowClosed ||
+		err == ErrNotParticipant || err == ErrNotTripPartner || err == ErrAlreadyReviewed || err == ErrPublished
+}
+
+// PendingReview — попутчик, о котором пользователь ещё может оставить отзыв
+type PendingReview struct {
+	TripID     int       `json:"tripId"`
+	FromCity   string    `json:"fromCity"`
+	ToCity     string    `json:"toCity"`
+	TargetID   int       `json:"targetId"`
+	TargetName string    `json:"targetName"`
+	TargetRole string    `json:"targetRole"` // driver или passenger
+	Deadline   time.Time `json:"deadline"`
+}
+
+// Pending — все пары «водитель ↔ пассажир» по завершённым поездкам пользователя,
+// для которых окно отзывов открыто, а отзыв ещё не оставлен
+func Pending(userID int) ([]PendingReview, error) {
+	rows, err := database.DB.Query(`
+		SELECT p.trip_id, t.from_city, t.to_city, p.target_id, u.full_name, p.role, t.completed_at
+		FROM (
+			SELECT b.trip_id, t.driver_id AS target_id, 'driver' AS role
+			FROM bookings b JOIN trips t ON b.trip_id = t.id
+			WHERE b.passenger_id = $1 AND b.status = 'confirmed'
+			UNION
+			SELECT b.trip_id, b.passenger_id, 'passenger'
+			FROM bookings b JOIN trips t ON b.trip_id = t.id
+			WHERE t.driver_id = $1 AND b.status = 'confirmed'
+		) p
+		JOIN trips t ON p.trip_id = t.id
+		JOIN users u ON p.target_id = u.id
+		WHERE t.status = 'completed'
+		  AND t.completed_at > NOW() - make_interval(days => $2)
+		  AND NOT EXISTS (
+			SELECT 1 FROM reviews r
+			WHERE r.trip_id = p.trip_id AND r.author_id = $1 AND r.target_id = p.target_id
+		  )
+		ORDER BY t.completed_at DESC, p.target_id
+	`, userID, WindowDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []PendingReview{}
+	for rows.Next() {
+		var p PendingReview
+		var completedAt time.Time
+		if err := rows.Scan(&p.TripID, &p.FromCity, &p.ToCity, &p.TargetID, &p.TargetName, &p.TargetRole, &completedAt); err != nil {
+			return nil, err
+		}
+		p.Deadline = Deadline(completedAt)
+		result = append(result, p)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,208 @@
+package reviews
+
+import (
+	"hermes-carpooling/database"
+	"hermes-carpooling/models"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Сортировки списка отзывов
+var listSorts = map[string]string{
+	"newest":  "COALESCE(r.published_at, r.created_at) DESC, r.id DESC",
+	"oldest":  "COALESCE(r.published_at, r.created_at) ASC, r.id ASC",
+	"highest": "r.rating DESC, r.id DESC",
+	"lowest":  "r.rating ASC, r.id DESC",
+}
+
+// ValidSort проверяет сортировку списка отзывов
+func ValidSort(sort string) bool {
+	_, ok := listSorts[sort]
+	return ok
+}
+
+// ListFilter — параметры выборки отзывов. Нужен TargetID (отзывы о пользователе)
+// или AuthorID (отзывы пользователя); автор видит и свои ещё скрытые отзывы.
+type ListFilter struct {
+	TargetID int
+	AuthorID int
+	Rating   int    // 1..5, 0 — любая оценка
+	Role     string // роль адресата в поездке: driver, passenger или пусто
+	Sort     string
+	Limit    int
+	Offset   int
+}
+
+// Item — отзыв в списке
+type Item struct {
+	ID           int                 `json:"id"`
+	TripID       int                 `json:"tripId"`
+	Rating       int                 `json:"rating"`
+	Comment      string              `json:"comment"`
+	Scores       models.ReviewScores `json:"scores"`
+	Tags         models.StringList   `json:"tags"`
+	Role         string              `json:"role"` // роль адресата в поездке
+	Published    bool                `json:"published"`
+	Hidden       bool                `json:"hidden"`
+	Reply        *string             `json:"reply"`
+	RepliedAt    *time.Time          `json:"repliedAt"`
+	CreatedAt    time.Time           `json:"createdAt"`
+	AuthorID     int                 `json:"authorId"`
+	AuthorName   string              `json:"authorName"`
+	AuthorAvatar string              `json:"authorAvatar"`
+	TargetID     int                 `json:"targetId"`
+	TargetName   string              `json:"targetName"`
+	TargetAvatar string              `json:"targetAvatar"`
+	FromCity     string              `json:"fromCity"`
+	ToCity       string              `json:"toCity"`
+	TripDate     string              `json:"tripDate"`
+}
+
+// PublicItem — отзыв в публичном профиле: без поездки, маршрута, даты и автора,
+// чтобы по отзывам нельзя было восстановить, кто, куда и когда ездил
+type PublicItem struct {
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+	Month   string `json:"month"` // 2024-05
+}
+
+// Public — отзыв для публичного профиля
+func (i Item) Public() PublicItem {
+	return PublicItem{Rating: i.Rating, Comment: i.Comment, Month: i.CreatedAt.Format("2006-01")}
+}
+
+// where собирает условия выборки и аргументы
+func (f ListFilter) where() (string, []interface{}) {
+	var cond string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.AuthorID != 0 {
+		cond = "r.author_id = " + arg(f.AuthorID)
+	} else {
+		cond = "r.target_id = " + arg(f.TargetID) + " AND r.published_at IS NOT NULL AND r.hidden_at IS NULL"
+	}
+	if f.Rating != 0 {
+		cond += " AND r.rating = " + arg(f.Rating)
+	}
+	switch f.Role {
+	case models.ReviewTargetDriver:
+		cond += " AND t.driver_id = r.target_id"
+	case models.ReviewTargetPassenger:
+		cond += " AND t.driver_id <> r.target_id"
+	}
+	return cond, args
+}
+
+// List возвращает страницу отзывов и общее число отзывов по фильтру
+func List(f ListFilter) ([]Item, int, error) {
+	where, args := f.where()
+
+	var total int
+	err := database.DB.QueryRow(`
+		SELECT COUNT(*) FROM reviews r JOIN trips t ON r.trip_id = t.id
+		WHERE `+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order, ok := listSorts[f.Sort]
+	if !ok {
+		order = listSorts["newest"]
+	}
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := database.DB.Query(`
+		SELECT r.id, r.trip_id, r.rating, COALESCE(r.comment, ''), r.scores, r.tags,
+		       t.driver_id = r.target_id, r.published_at IS NOT NULL, r.hidden_at IS NOT NULL,
+		       r.reply, r.replied_at, r.created_at,
+		       a.id, a.full_name, COALESCE(a.avatar_url, ''),
+		       u.id, u.full_name, COALESCE(u.avatar_url, ''),
+		       t.from_city, t.to_city, t.trip_date
+		FROM reviews r
+		JOIN trips t ON r.trip_id = t.id
+		JOIN users a ON r.author_id = a.id
+		JOIN users u ON r.target_id = u.id
+		WHERE `+where+`
+		ORDER BY `+order+`
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		var item Item
+		var targetIsDriver bool
+		var tripDate time.Time
+		err := rows.Scan(&item.ID, &item.TripID, &item.Rating, &item.Comment, &item.Scores, &item.Tags,
+			&targetIsDriver, &item.Published, &item.Hidden,
+			&item.Reply, &item.RepliedAt, &item.CreatedAt,
+			&item.AuthorID, &item.AuthorName, &item.AuthorAvatar,
+			&item.TargetID, &item.TargetName, &item.TargetAvatar,
+			&item.FromCity, &item.ToCity, &tripDate)
+		if err != nil {
+			return nil, 0, err
+		}
+		item.Role = models.ReviewTargetPassenger
+		if targetIsDriver {
+			item.Role = models.ReviewTargetDriver
+		}
+		item.TripDate = tripDate.Format("2006-01-02")
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+// Summary — сводка отзывов о пользователе: среднее, число и распределение оценок
+type Summary struct {
+	Count       int         `json:"count"`
+	Average     float64     `json:"average"`
+	Histogram   map[int]int `json:"histogram"` // оценка 1..5 → число отзывов
+	AsDriver    int         `json:"asDriver"`
+	AsPassenger int         `json:"asPassenger"`
+}
+
+// Summarize считает сводку по опубликованным отзывам о пользователе;
+// role ограничивает её отзывами о нём как о водителе или пассажире
+func Summarize(targetID int, role string) (Summary, error) {
+	s := Summary{Histogram: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	where, args := ListFilter{TargetID: targetID, Role: role}.where()
+
+	rows, err := database.DB.Query(`
+		SELECT r.rating, t.driver_id = r.target_id, COUNT(*)
+		FROM reviews r JOIN trips t ON r.trip_id = t.id
+		WHERE `+where+`
+		GROUP BY 1, 2
+	`, args...)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+
+	sum := 0
+	for rows.Next() {
+		var rating, count int
+		var asDriver bool
+		if err := rows.Scan(&rating, &asDriver, &count); err != nil {
+			return s, err
+		}
+		s.Histogram[rating] += count
+		s.Count += count
+		sum += rating * count
+		if asDriver {
+			s.AsDriver += count
+		} else {
+			s.AsPassenger += count
+		}
+	}
+	if s.Count > 0 {
+		s.Average = math.Round(float64(sum)/float64(s.Count)*100) / 100
+	}
+	return s, rows.Err()
+}
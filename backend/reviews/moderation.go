@@ -0,0 +1,322 @@
+package reviews
+
+import (
+	"database/sql"
+	"errors"
+	"hermes-carpooling/actionlog"
+	"hermes-carpooling/audit"
+	"hermes-carpooling/database"
+	"hermes-carpooling/events"
+	"strings"
+	"time"
+)
+
+// Причины жалобы на отзыв
+var ReportReasons = []string{"spam", "offensive", "false_information", "personal_data", "other"}
+
+// Статусы жалоб
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"  // отзыв скрыт или удалён
+	ReportDismissed = "dismissed" // модератор оставил отзыв
+)
+
+// Ошибки ответов и жалоб; текст ошибки отдаётся клиенту
+var (
+	ErrReviewNotFound  = errors.New("review not found")
+	ErrNotReviewTarget = errors.New("only the reviewed user can reply")
+	ErrAlreadyReplied  = errors.New("reply already exists")
+	ErrNotPublished    = errors.New("review is not published yet")
+	ErrOwnReview       = errors.New("you cannot report your own review")
+	ErrAlreadyReported = errors.New("you have already reported this review")
+	ErrInvalidReason   = errors.New("invalid report reason")
+)
+
+// ValidReportReason проверяет причину жалобы
+func ValidReportReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// IsModerationError — ошибка ответа или жалобы, а не базы
+func IsModerationError(err error) bool {
+	return err == ErrReviewNotFound || err == ErrNotReviewTarget || err == ErrAlreadyReplied ||
+		err == ErrNotPublished || err == ErrOwnReview || err == ErrAlreadyReported || err == ErrInvalidReason
+}
+
+// Reply сохраняет публичный ответ адресата на опубликованный отзыв. Ответ один, без правок.
+func Reply(reviewID int, actor audit.Actor, text string) error {
+	tx, err := audit.Begin(actor)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var p events.ReviewPayload
+	var published, replied bool
+	err = tx.QueryRow(`
+		SELECT id, trip_id, author_id, target_id, rating,
+		       published_at IS NOT NULL AND hidden_at IS NULL, reply IS NOT NULL
+		FROM reviews WHERE id = $1
+		FOR UPDATE
+	`, reviewID).Scan(&p.ReviewID, &p.TripID, &p.AuthorID, &p.TargetID, &p.Rating, &published, &replied)
+	if err == sql.ErrNoRows {
+		return ErrReviewNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case p.TargetID != actor.UserID:
+		return ErrNotReviewTarget
+	case !published:
+		return ErrNotPublished
+	case replied:
+		return ErrAlreadyReplied
+	}
+
+	_, err = tx.Exec(`UPDATE reviews SET reply = $2, replied_at = NOW() WHERE id = $1`, reviewID, text)
+	if err != nil {
+		return err
+	}
+
+	if err := events.Record(tx, events.ReviewReplied, "review", reviewID, p); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	events.Wake()
+	return nil
+}
+
+// Report записывает жалобу пользователя на отзыв
+func Report(reviewID, reporterID int, reason, comment string) (int, error) {
+	if !ValidReportReason(reason) {
+		return 0, ErrInvalidReason
+	}
+
+	// Пожаловаться можно только на отзыв, который виден другим пользователям
+	var authorID int
+	err := database.DB.QueryRow(`
+		SELECT author_id FROM reviews
+		WHERE id = $1 AND published_at IS NOT NULL AND hidden_at IS NULL
+	`, reviewID).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		return 0, ErrReviewNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if authorID == reporterID {
+		return 0, ErrOwnReview
+	}
+
+	var reportID int
+	err = database.DB.QueryRow(`
+		INSERT INTO review_reports (review_id, reporter_id, reason, comment)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (review_id, reporter_id) DO NOTHING
+		RETURNING id
+	`, reviewID, reporterID, reason, comment).Scan(&reportID)
+	if err == sql.ErrNoRows {
+		return 0, ErrAlreadyReported
+	}
+	return reportID, err
+}
+
+// QueueItem — отзыв в очереди модерации со сводкой открытых жалоб
+type QueueItem struct {
+	ReviewID      int        `json:"reviewId"`
+	TripID        int        `json:"tripId"`
+	AuthorID      int        `json:"authorId"`
+	AuthorName    string     `json:"authorName"`
+	TargetID      int        `json:"targetId"`
+	TargetName    string     `json:"targetName"`
+	Rating        int        `json:"rating"`
+	Comment       string     `json:"comment"`
+	Reply         *string    `json:"reply"`
+	HiddenAt      *time.Time `json:"hiddenAt"`
+	Reports       int        `json:"reports"`
+	Reasons       []string   `json:"reasons"`
+	LastReportAt  time.Time  `json:"lastReportAt"`
+	LatestComment string     `json:"latestComment"`
+}
+
+// Queue — отзывы с жалобами в статусе status, самые обжалуемые первыми
+func Queue(status string, limit, offset int) ([]QueueItem, error) {
+	rows, err := database.DB.Query(`
+		SELECT r.id, r.trip_id, r.author_id, a.full_name, r.target_id, t.full_name,
+		       r.rating, COALESCE(r.comment, ''), r.reply, r.hidden_at,
+		       q.reports, q.reasons, q.last_report_at, q.latest_comment
+		FROM (
+			SELECT review_id, COUNT(*) AS reports,
+			       STRING_AGG(DISTINCT reason, ',') AS reasons,
+			       MAX(created_at) AS last_report_at,
+			       (ARRAY_AGG(COALESCE(comment, '') ORDER BY created_at DESC))[1] AS latest_comment
+			FROM review_reports
+			WHERE status = $1
+			GROUP BY review_id
+		) q
+		JOIN reviews r ON q.review_id = r.id
+		JOIN users a ON r.author_id = a.id
+		JOIN users t ON r.target_id = t.id
+		ORDER BY q.reports DESC, q.last_report_at
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []QueueItem{}
+	for rows.Next() {
+		var item QueueItem
+		var reasons string
+		err := rows.Scan(&item.ReviewID, &item.TripID, &item.AuthorID, &item.AuthorName, &item.TargetID, &item.TargetName,
+			&item.Rating, &item.Comment, &item.Reply, &item.HiddenAt,
+			&item.Reports, &reasons, &item.LastReportAt, &item.LatestComment)
+		if err != nil {
+			return nil, err
+		}
+		item.Reasons = strings.Split(reasons, ",")
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
+
+// Hide скрывает отзыв: он пропадает из выдачи и из рейтинга адресата.
+// Открытые жалобы на отзыв считаются рассмотренными.
+func Hide(reviewID int, actor audit.Actor, reason string) error {
+	return moderate(reviewID, actor, events.ReviewHidden, actionlog.ActionReviewHide, ReportResolved, reason, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE reviews SET hidden_at = NOW(), hidden_by = $2, hidden_reason = $3
+			WHERE id = $1 AND hidden_at IS NULL
+		`, reviewID, actor.UserID, reason)
+		return err
+	})
+}
+
+// Restore возвращает скрытый отзыв; открытые жалобы отклоняются
+func Restore(reviewID int, actor audit.Actor, reason string) error {
+	return moderate(reviewID, actor, events.ReviewRestored, actionlog.ActionReviewRestore, ReportDismissed, reason, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE reviews SET hidden_at = NULL, hidden_by = NULL, hidden_reason = NULL
+			WHERE id = $1
+		`, reviewID)
+		return err
+	})
+}
+
+// Delete удаляет отзыв вместе с жалобами на него
+func Delete(reviewID int, actor audit.Actor, reason string) error {
+	return moderate(reviewID, actor, events.ReviewDeleted, actionlog.ActionReviewDelete, ReportResolved, reason, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`DELETE FROM reviews WHERE id = $1`, reviewID)
+		return err
+	})
+}
+
+// moderate выполняет действие модератора, закрывает открытые жалобы, записывает
+// действие в журнал администраторов и событие, по которому пересчитывается рейтинг адресата
+func moderate(reviewID int, actor audit.Actor, eventType, action, reportStatus, reason string, apply func(tx *sql.Tx) error) error {
+	tx, err := audit.Begin(actor)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var p events.ReviewPayload
+	err = tx.QueryRow(`
+		SELECT id, trip_id, author_id, target_id, rating FROM reviews WHERE id = $1 FOR UPDATE
+	`, reviewID).Scan(&p.ReviewID, &p.TripID, &p.AuthorID, &p.TargetID, &p.Rating)
+	if err == sql.ErrNoRows {
+		return ErrReviewNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE review_reports SET status = $2, resolved_at = NOW(), resolved_by = $3
+		WHERE review_id = $1 AND status = $4
+	`, reviewID, reportStatus, actor.UserID, ReportOpen)
+	if err != nil {
+		return err
+	}
+
+	if err := apply(tx); err != nil {
+		return err
+	}
+
+	if err := actionlog.Log(tx, actor.UserID, action, "review", reviewID, reason, p); err != nil {
+		return err
+	}
+	if err := events.Record(tx, eventType, "review", reviewID, p); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	events.Wake()
+	return nil
+}
+
+// Edit — правка отзыва администратором, например удаление персональных данных из
+// комментария. nil-поля не меняются; рейтинг адресата пересчитает подписчик ReviewUpdated.
+func Edit(reviewID int, actor audit.Actor, rating *int, comment *string, reason string) error {
+	tx, err := audit.Begin(actor)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var p events.ReviewPayload
+	var oldComment string
+	err = tx.QueryRow(`
+		SELECT id, trip_id, author_id, target_id, rating, COALESCE(comment, '')
+		FROM reviews WHERE id = $1
+		FOR UPDATE
+	`, reviewID).Scan(&p.ReviewID, &p.TripID, &p.AuthorID, &p.TargetID, &p.Rating, &oldComment)
+	if err == sql.ErrNoRows {
+		return ErrReviewNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	old := map[string]interface{}{"rating": p.Rating, "comment": oldComment}
+	changed := map[string]interface{}{}
+	if rating != nil {
+		p.Rating = *rating
+		changed["rating"] = *rating
+	}
+	if comment != nil {
+		changed["comment"] = *comment
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reviews SET rating = $2, comment = COALESCE($3, comment)
+		WHERE id = $1
+	`, reviewID, p.Rating, comment)
+	if err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{"old": old, "new": changed}
+	if err := actionlog.Log(tx, actor.UserID, actionlog.ActionReviewEdit, "review", reviewID, reason, details); err != nil {
+		return err
+	}
+	if err := events.Record(tx, events.ReviewUpdated, "review", reviewID, p); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	events.Wake()
+	return nil
+}
@@ -0,0 +1,127 @@
+package reviews
+
+import (
+	"database/sql"
+	"hermes-carpooling/database"
+	"hermes-carpooling/events"
+	"log"
+	"time"
+)
+
+// Отзывы «вслепую»: отзыв не виден адресату и не влияет на рейтинг, пока
+// встречный отзыв не оставлен или не закрылось окно отзывов. Тогда публикуются оба сразу.
+
+// PublishMutual вызывается после создания отзыва в той же транзакции: если встречный
+// отзыв по поездке уже есть, публикует оба. Строка поездки блокируется, чтобы два
+// одновременно отправленных встречных отзыва увидели друг друга.
+func PublishMutual(tx *sql.Tx, reviewID int) (bool, error) {
+	var tripID, authorID, targetID int
+	err := tx.QueryRow(`
+		SELECT trip_id, author_id, target_id FROM reviews WHERE id = $1
+	`, reviewID).Scan(&tripID, &authorID, &targetID)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(`SELECT id FROM trips WHERE id = $1 FOR UPDATE`, tripID); err != nil {
+		return false, err
+	}
+
+	var counterpartID int
+	err = tx.QueryRow(`
+		SELECT id FROM reviews
+		WHERE trip_id = $1 AND author_id = $2 AND target_id = $3 AND published_at IS NULL
+	`, tripID, targetID, authorID).Scan(&counterpartID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range []int{counterpartID, reviewID} {
+		if err := publish(tx, id); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// publish открывает отзыв и записывает событие ReviewPublished
+func publish(tx *sql.Tx, reviewID int) error {
+	var p events.ReviewPayload
+	err := tx.QueryRow(`
+		UPDATE reviews SET published_at = NOW()
+		WHERE id = $1 AND published_at IS NULL
+		RETURNING id, trip_id, author_id, target_id, rating
+	`, reviewID).Scan(&p.ReviewID, &p.TripID, &p.AuthorID, &p.TargetID, &p.Rating)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return events.Record(tx, events.ReviewPublished, "review", reviewID, p)
+}
+
+// PublishExpired публикует отзывы, встречный отзыв к которым так и не появился
+// до закрытия окна отзывов
+func PublishExpired() {
+	rows, err := database.DB.Query(`
+		SELECT r.id FROM reviews r
+		JOIN trips t ON r.trip_id = t.id
+		WHERE r.published_at IS NULL
+		  AND t.completed_at <= NOW() - make_interval(days => $1)
+		ORDER BY r.id
+	`, WindowDays)
+	if err != nil {
+		log.Println("⚠️ Отзывы: ошибка выборки неопубликованных отзывов:", err)
+		return
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	published := 0
+	for _, id := range ids {
+		if err := publishOne(id); err != nil {
+			log.Printf("⚠️ Отзывы: не удалось опубликовать отзыв %d: %v", id, err)
+			continue
+		}
+		published++
+	}
+	if published > 0 {
+		events.Wake()
+		log.Printf("📝 Опубликовано отзывов по истечении окна: %d", published)
+	}
+}
+
+func publishOne(reviewID int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := publish(tx, reviewID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// StartPublisher запускает фоновую публикацию отзывов с истёкшим окном
+func StartPublisher(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			PublishExpired()
+		}
+	}()
+}
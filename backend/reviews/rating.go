@@ -0,0 +1,118 @@
+package reviews
+
+import (
+	"hermes-carpooling/database"
+	"log"
+	"math"
+	"time"
+)
+
+// RatingConfig — параметры расчёта рейтинга
+type RatingConfig struct {
+	// Априорная оценка и её вес в «виртуальных отзывах»: у новичка с одним
+	// отзывом на 5 балл сдвигается от PriorMean лишь немного
+	PriorMean   float64
+	PriorWeight float64
+	// Через сколько дней вес отзыва уменьшается вдвое
+	HalfLifeDays float64
+}
+
+// Rating — настройки рейтинга; задаются из конфигурации при старте
+var Rating = RatingConfig{
+	PriorMean:    4.0,
+	PriorWeight:  5,
+	HalfLifeDays: 365,
+}
+
+// Score — сглаженный рейтинг с учётом давности:
+//
+//	score = (PriorWeight·PriorMean + Σ wᵢ·ratingᵢ) / (PriorWeight + Σ wᵢ),  wᵢ = 0.5^(ageᵢ / HalfLifeDays)
+//
+// Без отзывов рейтинг равен PriorMean.
+func (cfg RatingConfig) Score(weightedSum, weights float64) float64 {
+	if cfg.PriorWeight+weights == 0 {
+		return cfg.PriorMean
+	}
+	score := (cfg.PriorWeight*cfg.PriorMean + weightedSum) / (cfg.PriorWeight + weights)
+	return math.Round(score*1000) / 1000
+}
+
+// Recompute пересчитывает рейтинг пользователя по опубликованным и не скрытым отзывам:
+// сырое среднее и число отзывов (users.rating, users.reviews_count) и сглаженный
+// рейтинг для ранжирования (users.rating_score). Строка пользователя блокируется,
+// чтобы параллельные пересчёты не перезаписали друг друга устаревшими данными.
+func Recompute(userID int) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return err
+	}
+
+	var count int
+	var average, weightedSum, weights float64
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(AVG(rating), 0),
+		       COALESCE(SUM(w * rating), 0), COALESCE(SUM(w), 0)
+		FROM (
+			SELECT rating,
+			       POWER(0.5, EXTRACT(EPOCH FROM NOW() - published_at) / 86400 / $2) AS w
+			FROM reviews
+			WHERE target_id = $1 AND published_at IS NOT NULL AND hidden_at IS NULL
+		) r
+	`, userID, Rating.HalfLifeDays).Scan(&count, &average, &weightedSum, &weights)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users
+		SET rating = $2, reviews_count = $3, rating_score = $4, rating_updated_at = NOW()
+		WHERE id = $1
+	`, userID, math.Round(average*100)/100, count, Rating.Score(weightedSum, weights))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RefreshAll пересчитывает рейтинги всех пользователей с отзывами: вес старых
+// отзывов со временем падает, даже если новых нет
+func RefreshAll() {
+	rows, err := database.DB.Query(`
+		SELECT DISTINCT target_id FROM reviews WHERE published_at IS NOT NULL
+	`)
+	if err != nil {
+		log.Println("⚠️ Рейтинг: ошибка выборки пользователей:", err)
+		return
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := Recompute(id); err != nil {
+			log.Printf("⚠️ Рейтинг: не удалось пересчитать рейтинг пользователя %d: %v", id, err)
+		}
+	}
+}
+
+// StartRatingRefresh запускает периодический пересчёт рейтингов
+func StartRatingRefresh(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			RefreshAll()
+		}
+	}()
+}
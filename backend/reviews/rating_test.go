@@ -0,0 +1,67 @@
+package reviews
+
+import (
+	"math"
+	"testing"
+)
+
+// review — оценка и её возраст в днях; вес считается так же, как в Recompute
+type review struct {
+	rating  float64
+	ageDays float64
+}
+
+func sums(cfg RatingConfig, list []review) (weightedSum, weights float64) {
+	for _, r := range list {
+		w := math.Pow(0.5, r.ageDays/cfg.HalfLifeDays)
+		weightedSum += w * r.rating
+		weights += w
+	}
+	return weightedSum, weights
+}
+
+func repeat(r review, n int) []review {
+	list := make([]review, n)
+	for i := range list {
+		list[i] = r
+	}
+	return list
+}
+
+func TestRatingScore(t *testing.T) {
+	cfg := RatingConfig{PriorMean: 4.0, PriorWeight: 5, HalfLifeDays: 365}
+
+	tests := []struct {
+		name    string
+		reviews []review
+		want    float64
+	}{
+		{"no reviews gives the prior mean", nil, 4.0},
+		{"one fresh five", []review{{5, 0}}, 4.167},
+		{"one fresh one", []review{{1, 0}}, 3.5},
+		{"few reviews stay near the prior", repeat(review{5, 0}, 3), 4.375},
+		{"many reviews outweigh the prior", repeat(review{5, 0}, 100), 4.952},
+		{"many low reviews", repeat(review{2, 0}, 100), 2.095},
+		{"old review counts half", []review{{5, 365}}, 4.091},
+		{"recent reviews dominate old ones", append(repeat(review{5, 0}, 10), repeat(review{1, 730}, 10)...), 4.143},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weightedSum, weights := sums(cfg, tt.reviews)
+			if got := cfg.Score(weightedSum, weights); got != tt.want {
+				t.Errorf("Score(%.3f, %.3f) = %v, want %v", weightedSum, weights, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRatingScoreWithoutPrior(t *testing.T) {
+	cfg := RatingConfig{PriorMean: 4.0, PriorWeight: 0, HalfLifeDays: 365}
+
+	if got := cfg.Score(0, 0); got != 4.0 {
+		t.Errorf("Score without reviews and prior weight = %v, want the prior mean 4", got)
+	}
+	if got := cfg.Score(9, 2); got != 4.5 {
+		t.Errorf("Score(9, 2) without prior = %v, want the plain mean 4.5", got)
+	}
+}
@@ -0,0 +1,97 @@
+package reviews
+
+import (
+	"hermes-carpooling/database"
+	"hermes-carpooling/models"
+	"math"
+	"sort"
+)
+
+// CriterionStats — средняя оценка пользователя по критерию
+type CriterionStats struct {
+	Key     string  `json:"key"`
+	Label   string  `json:"label"`
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+// TagStats — сколько раз пользователю ставили метку
+type TagStats struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// DetailStats — сводка по критериям и меткам из опубликованных отзывов о пользователе
+type DetailStats struct {
+	Criteria []CriterionStats `json:"criteria"`
+	Tags     []TagStats       `json:"tags"`
+}
+
+// Details считает средние по критериям и частоту меток. Критерии и метки
+// без оценок не попадают в сводку.
+func Details(userID int) (DetailStats, error) {
+	stats := DetailStats{Criteria: []CriterionStats{}, Tags: []TagStats{}}
+
+	rows, err := database.DB.Query(`
+		SELECT s.key, AVG(s.value::int), COUNT(*)
+		FROM reviews r, jsonb_each_text(r.scores) s
+		WHERE r.target_id = $1 AND r.published_at IS NOT NULL AND r.hidden_at IS NULL
+		GROUP BY s.key
+	`, userID)
+	if err != nil {
+		return stats, err
+	}
+
+	criteria := map[string]CriterionStats{}
+	for rows.Next() {
+		var c CriterionStats
+		if err := rows.Scan(&c.Key, &c.Average, &c.Count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		c.Average = math.Round(c.Average*100) / 100
+		criteria[c.Key] = c
+	}
+	rows.Close()
+
+	for _, criterion := range models.ReviewCriteria {
+		if c, ok := criteria[criterion.Key]; ok {
+			c.Label = criterion.Label
+			stats.Criteria = append(stats.Criteria, c)
+		}
+	}
+
+	rows, err = database.DB.Query(`
+		SELECT t.tag, COUNT(*)
+		FROM reviews r, jsonb_array_elements_text(r.tags) t(tag)
+		WHERE r.target_id = $1 AND r.published_at IS NOT NULL AND r.hidden_at IS NULL
+		GROUP BY t.tag
+	`, userID)
+	if err != nil {
+		return stats, err
+	}
+
+	tags := map[string]int{}
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		tags[key] = count
+	}
+	rows.Close()
+
+	for _, tag := range models.ReviewTagCatalog {
+		if count, ok := tags[tag.Key]; ok {
+			stats.Tags = append(stats.Tags, TagStats{Key: tag.Key, Label: tag.Label, Count: count})
+		}
+	}
+	// Самые частые метки — первыми
+	sort.SliceStable(stats.Tags, func(i, j int) bool {
+		return stats.Tags[i].Count > stats.Tags[j].Count
+	})
+	return stats, nil
+}
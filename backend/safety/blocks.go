@@ -0,0 +1,116 @@
+package safety
+
+import (
+	"database/sql"
+	"errors"
+	"hermes-carpooling/database"
+	"time"
+)
+
+// Ошибки блокировок и жалоб; текст ошибки отдаётся клиенту
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrSelf            = errors.New("you cannot block or report yourself")
+	ErrInvalidCategory = errors.New("invalid report category")
+	ErrReportNotFound  = errors.New("report not found")
+	ErrReportExists    = errors.New("you already have an open report on this user")
+)
+
+// queryer — общий интерфейс *sql.DB и *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// BlockedUser — пользователь из списка заблокированных
+type BlockedUser struct {
+	UserID    int       `json:"userId"`
+	FullName  string    `json:"fullName"`
+	AvatarURL string    `json:"avatarUrl"`
+	BlockedAt time.Time `json:"blockedAt"`
+}
+
+// userExists проверяет, что пользователь существует
+func userExists(userID int) error {
+	var exists bool
+	err := database.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Block добавляет пользователя в чёрный список. Повторная блокировка ничего не меняет.
+func Block(blockerID, blockedID int) error {
+	if blockerID == blockedID {
+		return ErrSelf
+	}
+	if err := userExists(blockedID); err != nil {
+		return err
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO user_blocks (blocker_id, blocked_id)
+		VALUES ($1, $2)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`, blockerID, blockedID)
+	return err
+}
+
+// Unblock убирает пользователя из чёрного списка
+func Unblock(blockerID, blockedID int) error {
+	_, err := database.DB.Exec(`
+		DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2
+	`, blockerID, blockedID)
+	return err
+}
+
+// Blocked — кого заблокировал пользователь
+func Blocked(blockerID int) ([]BlockedUser, error) {
+	rows, err := database.DB.Query(`
+		SELECT u.id, u.full_name, COALESCE(u.avatar_url, ''), b.created_at
+		FROM user_blocks b
+		JOIN users u ON b.blocked_id = u.id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC
+	`, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []BlockedUser{}
+	for rows.Next() {
+		var u BlockedUser
+		if err := rows.Scan(&u.UserID, &u.FullName, &u.AvatarURL, &u.BlockedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, rows.Err()
+}
+
+// BlockedBetween — заблокировал ли кто-то из двоих другого. Блокировка действует
+// в обе стороны: ни водитель, ни пассажир не хотят ехать вместе.
+func BlockedBetween(q queryer, a, b int) (bool, error) {
+	var blocked bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`, a, b).Scan(&blocked)
+	return blocked, err
+}
+
+// NotBlockedSQL — SQL-условие: пользователь из колонки column и пользователь
+// из параметра placeholder не блокировали друг друга
+func NotBlockedSQL(column, placeholder string) string {
+	return `NOT EXISTS (
+		SELECT 1 FROM user_blocks ub
+		WHERE (ub.blocker_id = ` + placeholder + ` AND ub.blocked_id = ` + column + `)
+		   OR (ub.blocker_id = ` + column + ` AND ub.blocked_id = ` + placeholder + `)
+	)`
+}
@@ -0,0 +1,150 @@
+package safety
+
+import (
+	"database/sql"
+	"hermes-carpooling/actionlog"
+	"hermes-carpooling/database"
+	"time"
+)
+
+// Категории жалоб на пользователя
+var ReportCategories = []string{
+	"harassment",
+	"unsafe_driving",
+	"no_show",
+	"fraud",
+	"fake_profile",
+	"inappropriate_behavior",
+	"other",
+}
+
+// Статусы жалоб
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"  // меры приняты
+	ReportDismissed = "dismissed" // нарушение не подтвердилось
+)
+
+// ValidCategory проверяет категорию жалобы
+func ValidCategory(category string) bool {
+	for _, c := range ReportCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUserError — ошибка блокировки или жалобы, а не базы
+func IsUserError(err error) bool {
+	return err == ErrUserNotFound || err == ErrSelf || err == ErrInvalidCategory || err == ErrReportNotFound ||
+		err == ErrReportExists
+}
+
+// UserReport — жалоба на пользователя
+type UserReport struct {
+	ID           int        `json:"id"`
+	ReporterID   int        `json:"reporterId"`
+	ReporterName string     `json:"reporterName"`
+	ReportedID   int        `json:"reportedId"`
+	ReportedName string     `json:"reportedName"`
+	Category     string     `json:"category"`
+	Description  string     `json:"description"`
+	TripID       *int       `json:"tripId"`
+	Status       string     `json:"status"`
+	Resolution   string     `json:"resolution"`
+	ResolvedBy   *int       `json:"resolvedBy"`
+	ResolvedAt   *time.Time `json:"resolvedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	// Сколько всего открытых жалоб на этого пользователя
+	OpenReports int `json:"openReports"`
+}
+
+// Report записывает жалобу на пользователя; tripID — необязательная поездка, к которой она относится.
+// Пока предыдущая жалоба того же автора на того же пользователя открыта, новая не принимается.
+func Report(reporterID, reportedID int, category, description string, tripID *int) (int, error) {
+	if reporterID == reportedID {
+		return 0, ErrSelf
+	}
+	if !ValidCategory(category) {
+		return 0, ErrInvalidCategory
+	}
+	if err := userExists(reportedID); err != nil {
+		return 0, err
+	}
+
+	var reportID int
+	err := database.DB.QueryRow(`
+		INSERT INTO user_reports (reporter_id, reported_id, category, description, trip_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (reporter_id, reported_id) WHERE status = 'open' DO NOTHING
+		RETURNING id
+	`, reporterID, reportedID, category, description, tripID).Scan(&reportID)
+	if err == sql.ErrNoRows {
+		return 0, ErrReportExists
+	}
+	return reportID, err
+}
+
+// Reports — очередь жалоб в статусе status; пользователи с большим числом
+// открытых жалоб — первыми
+func Reports(status string, limit, offset int) ([]UserReport, error) {
+	rows, err := database.DB.Query(`
+		SELECT r.id, r.reporter_id, a.full_name, r.reported_id, u.full_name,
+		       r.category, COALESCE(r.description, ''), r.trip_id, r.status,
+		       COALESCE(r.resolution, ''), r.resolved_by, r.resolved_at, r.created_at,
+		       (SELECT COUNT(*) FROM user_reports o WHERE o.reported_id = r.reported_id AND o.status = 'open')
+		FROM user_reports r
+		JOIN users a ON r.reporter_id = a.id
+		JOIN users u ON r.reported_id = u.id
+		WHERE r.status = $1
+		ORDER BY 14 DESC, r.created_at
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []UserReport{}
+	for rows.Next() {
+		var r UserReport
+		err := rows.Scan(&r.ID, &r.ReporterID, &r.ReporterName, &r.ReportedID, &r.ReportedName,
+			&r.Category, &r.Description, &r.TripID, &r.Status,
+			&r.Resolution, &r.ResolvedBy, &r.ResolvedAt, &r.CreatedAt, &r.OpenReports)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// ResolveReport закрывает жалобу решением модератора (resolved или dismissed)
+func ResolveReport(reportID, adminID int, status, resolution string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var reportedID int
+	err = tx.QueryRow(`
+		UPDATE user_reports
+		SET status = $2, resolution = $3, resolved_by = $4, resolved_at = NOW()
+		WHERE id = $1
+		RETURNING reported_id
+	`, reportID, status, resolution, adminID).Scan(&reportedID)
+	if err == sql.ErrNoRows {
+		return ErrReportNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{"status": status, "reportedId": reportedID}
+	if err := actionlog.Log(tx, adminID, actionlog.ActionUserReportClose, "user_report", reportID, resolution, details); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
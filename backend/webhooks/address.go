@@ -0,0 +1,68 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Ошибки проверки адреса вебхука; текст ошибки отдаётся клиенту
+var (
+	ErrInvalidURL     = errors.New("url must be an http or https address")
+	ErrPrivateAddress = errors.New("url must resolve to a public address")
+)
+
+// sharedAddressSpace — 100.64.0.0/10 (CGNAT), не входит в net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP — адрес, на который можно отправлять вебхуки: не loopback,
+// не частная сеть, не link-local (в том числе метаданные облака 169.254.169.254)
+// и не 0.0.0.0 / ::
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+// ValidateURL проверяет адрес вебхука при регистрации: схема http(s), хост есть
+// и все его адреса публичные. Подключение проверяется ещё раз при отправке
+// (см. dialControl), поэтому смена DNS-записи после регистрации не поможет.
+func ValidateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return ErrPrivateAddress
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// dialControl запрещает подключение к непубличным адресам. Вызывается для
+// уже разрешённого адреса, перед каждым соединением, включая редиректы.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("webhook address %s is not public", host)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := publicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("publicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	if err := dialControl("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("public address rejected: %v", err)
+	}
+	for _, address := range []string{"127.0.0.1:80", "[::1]:443", "169.254.169.254:80", "10.0.0.5:8080"} {
+		if err := dialControl("tcp", address, nil); err == nil {
+			t.Errorf("dialControl(%s) allowed a non-public address", address)
+		}
+	}
+}
+
+func TestValidateURLRejectsLocalAddresses(t *testing.T) {
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"ftp://example.com/hook", ErrInvalidURL},
+		{"http:///hook", ErrInvalidURL},
+		{"not a url", ErrInvalidURL},
+		{"http://127.0.0.1:8080/hook", ErrPrivateAddress},
+		{"http://[::1]/hook", ErrPrivateAddress},
+		{"http://169.254.169.254/latest/meta-data", ErrPrivateAddress},
+		{"https://192.168.0.10/hook", ErrPrivateAddress},
+		{"http://localhost/hook", ErrPrivateAddress},
+	}
+	for _, tt := range tests {
+		if err := ValidateURL(context.Background(), tt.url); err != tt.want {
+			t.Errorf("ValidateURL(%q) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
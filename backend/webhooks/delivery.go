@@ -0,0 +1,200 @@
+package webhooks
+
+import (
+	"bytes"
+	"fmt"
+	"hermes-carpooling/database"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxAttempts — после стольких неудачных попыток доставка помечается как failed
+	MaxAttempts = 8
+	batchSize   = 20
+	// leaseSeconds — на это время доставка «забирается» воркером, чтобы
+	// другой экземпляр сервера не отправил её параллельно
+	leaseSeconds    = 60
+	maxResponseBody = 1000
+)
+
+// Client — HTTP-клиент для отправки вебхуков. Подключается только к публичным
+// адресам и без прокси, чтобы вебхук нельзя было направить во внутреннюю сеть.
+var Client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: dialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConnsPerHost: 2,
+	},
+}
+
+var wake = make(chan struct{}, 1)
+
+// Wake просит воркер не ждать следующего тика
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start запускает фоновую доставку вебхуков
+func Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			for deliverBatch() == batchSize {
+			}
+			select {
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+type pending struct {
+	id        int
+	eventType string
+	payload   []byte
+	attempts  int
+	url       string
+	secret    string
+}
+
+// deliverBatch отправляет очередную порцию доставок и возвращает их количество
+func deliverBatch() int {
+	rows, err := database.DB.Query(`
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT d2.id FROM webhook_deliveries d2
+			JOIN webhooks w2 ON w2.id = d2.webhook_id
+			WHERE d2.status = 'pending' AND d2.next_attempt_at <= NOW() AND w2.active
+			ORDER BY d2.id
+			LIMIT $1
+			FOR UPDATE OF d2 SKIP LOCKED
+		)
+		RETURNING d.id, d.event_type, d.payload, d.attempts, w.url, w.secret
+	`, batchSize, leaseSeconds)
+	if err != nil {
+		log.Println("⚠️ Вебхуки: ошибка выборки доставок:", err)
+		return 0
+	}
+
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.eventType, &p.payload, &p.attempts, &p.url, &p.secret); err != nil {
+			log.Println("⚠️ Вебхуки: ошибка сканирования доставки:", err)
+			continue
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+
+	for _, p := range batch {
+		attempt(p)
+	}
+	return len(batch)
+}
+
+// attempt выполняет одну попытку доставки и записывает результат в журнал
+func attempt(p pending) {
+	start := time.Now()
+	status, body, err := send(p)
+	duration := int(time.Since(start).Milliseconds())
+	attempts := p.attempts + 1
+
+	var responseStatus *int
+	if status > 0 {
+		responseStatus = &status
+	}
+
+	if err == nil {
+		_, err := database.DB.Exec(`
+			UPDATE webhook_deliveries
+			SET status = 'succeeded', attempts = $1, response_status = $2, response_body = $3,
+				duration_ms = $4, last_error = NULL, next_attempt_at = NULL, delivered_at = NOW()
+			WHERE id = $5
+		`, attempts, responseStatus, body, duration, p.id)
+		if err != nil {
+			log.Printf("⚠️ Вебхук: доставка %d выполнена, но не записана: %v", p.id, err)
+		}
+		return
+	}
+
+	log.Printf("⚠️ Вебхук: доставка %d на %s, попытка %d: %v", p.id, p.url, attempts, err)
+
+	if attempts >= MaxAttempts {
+		_, dbErr := database.DB.Exec(`
+			UPDATE webhook_deliveries
+			SET status = 'failed', attempts = $1, response_status = $2, response_body = $3,
+				duration_ms = $4, last_error = $5, next_attempt_at = NULL
+			WHERE id = $6
+		`, attempts, responseStatus, body, duration, err.Error(), p.id)
+		if dbErr != nil {
+			log.Printf("⚠️ Вебхук: ошибка записи неудачной доставки %d: %v", p.id, dbErr)
+		}
+		return
+	}
+
+	_, dbErr := database.DB.Exec(`
+		UPDATE webhook_deliveries
+		SET attempts = $1, response_status = $2, response_body = $3, duration_ms = $4, last_error = $5,
+			next_attempt_at = NOW() + $6 * INTERVAL '1 second'
+		WHERE id = $7
+	`, attempts, responseStatus, body, duration, err.Error(), int(backoff(attempts).Seconds()), p.id)
+	if dbErr != nil {
+		log.Printf("⚠️ Вебхук: ошибка планирования повтора доставки %d: %v", p.id, dbErr)
+	}
+}
+
+// send отправляет подписанный запрос; успехом считается любой ответ 2xx
+func send(p pending) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(p.payload))
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Hermes-Webhooks/1.0")
+	req.Header.Set("X-Hermes-Event", p.eventType)
+	req.Header.Set("X-Hermes-Delivery", strconv.Itoa(p.id))
+	req.Header.Set("X-Hermes-Timestamp", timestamp)
+	req.Header.Set("X-Hermes-Signature", Sign(p.secret, timestamp, p.payload))
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	body := strings.ToValidUTF8(string(data), "")
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, body, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, body, nil
+}
+
+// backoff — экспоненциальная задержка: 30 с, 1 мин, 2 мин ... не больше 6 часов
+func backoff(attempts int) time.Duration {
+	d := time.Duration(1<<uint(attempts-1)) * 30 * time.Second
+	if d > 6*time.Hour {
+		return 6 * time.Hour
+	}
+	return d
+}
@@ -0,0 +1,121 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hermes-carpooling/database"
+	"hermes-carpooling/events"
+	"time"
+)
+
+// EventTypes — доменные события, которые можно получать по вебхуку,
+// и их внешние имена
+var EventTypes = map[string]string{
+	events.TripCreated:      "trip.created",
+	events.TripCancelled:    "trip.cancelled",
+	events.TripCompleted:    "trip.completed",
+	events.BookingCreated:   "booking.created",
+	events.BookingConfirmed: "booking.confirmed",
+	events.BookingCancelled: "booking.cancelled",
+}
+
+// ValidEventType проверяет внешнее имя события
+func ValidEventType(name string) bool {
+	for _, external := range EventTypes {
+		if external == name {
+			return true
+		}
+	}
+	return false
+}
+
+// envelope — тело запроса, отправляемого партнёру
+type envelope struct {
+	Event     string          `json:"event"`
+	EventID   int64           `json:"eventId"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NewSecret генерирует секрет для подписи запросов
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign вычисляет подпись HMAC-SHA256 от "timestamp.body".
+// Партнёр проверяет её тем же секретом; timestamp защищает от повторной отправки.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue — подписчик шины событий: ставит событие в очередь доставки
+// на вебхуки участников (водителя и пассажиров) и партнёров, у которых
+// они числятся сотрудниками, если вебхук подписан на этот тип
+func Enqueue(e events.Event) error {
+	name, ok := EventTypes[e.Type]
+	if !ok {
+		return nil
+	}
+
+	userIDs, err := participants(e)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(envelope{Event: name, EventID: e.ID, CreatedAt: e.CreatedAt.UTC(), Data: e.Payload})
+	if err != nil {
+		return err
+	}
+
+	// Повторная обработка события не должна дублировать доставки
+	for _, userID := range userIDs {
+		_, err := database.DB.Exec(`
+			INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+			SELECT w.id, $1, $2, $3
+			FROM webhooks w
+			WHERE w.active
+			  AND (w.user_id = $4 OR w.user_id IN (SELECT partner_id FROM partner_members WHERE user_id = $4))
+			  AND (w.event_types = '[]'::jsonb OR w.event_types ? $2)
+			  AND NOT EXISTS (
+				SELECT 1 FROM webhook_deliveries d
+				WHERE d.webhook_id = w.id AND d.event_id = $1 AND d.redelivery_of IS NULL
+			  )
+		`, e.ID, name, string(body), userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	Wake()
+	return nil
+}
+
+// participants возвращает пользователей, которых касается событие
+func participants(e events.Event) ([]int, error) {
+	switch e.AggregateType {
+	case "trip":
+		var p events.TripPayload
+		if err := e.Decode(&p); err != nil {
+			return nil, err
+		}
+		return append([]int{p.DriverID}, p.PassengerIDs...), nil
+	case "booking":
+		var p events.BookingPayload
+		if err := e.Decode(&p); err != nil {
+			return nil, err
+		}
+		return []int{p.DriverID, p.PassengerID}, nil
+	}
+	return nil, nil
+}
@@ -94,4 +94,556 @@ CREATE TRIGGER update_trips_updated_at BEFORE UPDATE ON trips
 CREATE TRIGGER update_bookings_updated_at BEFORE UPDATE ON bookings
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 CREATE UNIQUE INDEX idx_reviews_unique_author_trip ON reviews(author_id, trip_id);
-ALTER TABLE reviews ADD CONSTRAINT unique_review_per_trip UNIQUE (author_id, trip_id);
\ No newline at end of file
+ALTER TABLE reviews ADD CONSTRAINT unique_review_per_trip UNIQUE (author_id, trip_id);
+-- Уведомления (входящие пользователя)
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT,
+    data JSONB,
+    is_read BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_notifications_user_id ON notifications(user_id, created_at DESC);
+
+-- Сохранённые поиски и совпавшие с ними поездки
+CREATE TABLE saved_searches (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_city VARCHAR(100),
+    to_city VARCHAR(100),
+    date_from DATE,
+    date_to DATE,
+    max_price INTEGER CHECK (max_price >= 0),
+    min_seats INTEGER CHECK (min_seats BETWEEN 1 AND 8),
+    no_smoking BOOLEAN DEFAULT FALSE,
+    animals_allowed BOOLEAN DEFAULT FALSE,
+    music_allowed BOOLEAN DEFAULT FALSE,
+    digest BOOLEAN DEFAULT FALSE,
+    last_digest_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE saved_search_matches (
+    saved_search_id INTEGER NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    trip_id INTEGER NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    notified_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (saved_search_id, trip_id)
+);
+
+CREATE INDEX idx_saved_searches_user_id ON saved_searches(user_id);
+
+-- Момент отправления с учётом часового пояса города отправления.
+-- trip_date и trip_time остаются местными датой и временем для отображения.
+ALTER TABLE trips ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'Europe/Moscow';
+ALTER TABLE trips ADD COLUMN departure_at TIMESTAMPTZ;
+UPDATE trips SET departure_at = (trip_date + trip_time) AT TIME ZONE timezone;
+ALTER TABLE trips ALTER COLUMN departure_at SET NOT NULL;
+
+CREATE INDEX idx_trips_departure_at ON trips(departure_at);
+
+-- Расчётные расстояние, время в пути и прибытие (по справочнику городов или провайдеру маршрутов).
+-- Старый столбец duration больше не заполняется.
+ALTER TABLE trips ADD COLUMN distance_km NUMERIC(7,1) CHECK (distance_km >= 0);
+ALTER TABLE trips ADD COLUMN duration_minutes INTEGER CHECK (duration_minutes >= 0);
+ALTER TABLE trips ADD COLUMN arrival_at TIMESTAMPTZ;
+
+-- Дополнительные удобства поездки (ключи из справочника models.AmenityCatalog)
+ALTER TABLE trips ADD COLUMN amenities JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE users ADD COLUMN default_amenities JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_trips_amenities ON trips USING GIN (amenities);
+
+-- Переписка водителя с пассажиром по поездке
+CREATE TABLE conversations (
+    id SERIAL PRIMARY KEY,
+    trip_id INTEGER NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    booking_id INTEGER REFERENCES bookings(id) ON DELETE SET NULL,
+    driver_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    passenger_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(trip_id, passenger_id)
+);
+
+CREATE TABLE messages (
+    id SERIAL PRIMARY KEY,
+    conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_conversations_driver_id ON conversations(driver_id);
+CREATE INDEX idx_conversations_passenger_id ON conversations(passenger_id);
+CREATE INDEX idx_messages_conversation_id ON messages(conversation_id, created_at);
+
+-- Язык уведомлений и настройки каналов доставки.
+-- event_type = '*' относится ко всем типам уведомлений.
+ALTER TABLE users ADD COLUMN locale VARCHAR(5) NOT NULL DEFAULT 'ru';
+
+CREATE TABLE notification_preferences (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'push', 'sms')),
+    event_type VARCHAR(50) NOT NULL DEFAULT '*',
+    enabled BOOLEAN NOT NULL,
+
+    PRIMARY KEY (user_id, channel, event_type)
+);
+
+-- Transactional outbox: события пишутся в одной транзакции с изменением
+-- и доставляются подписчикам фоновым диспетчером
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    aggregate_type VARCHAR(30) NOT NULL,
+    aggregate_id INTEGER NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP,
+    failed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Какие подписчики уже обработали событие (чтобы повтор не дублировал эффекты)
+CREATE TABLE outbox_deliveries (
+    event_id BIGINT NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+    subscriber VARCHAR(50) NOT NULL,
+    delivered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (event_id, subscriber)
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events(next_attempt_at)
+    WHERE processed_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_events_aggregate ON outbox_events(aggregate_type, aggregate_id);
+
+-- Вебхуки партнёров: события о поездках и бронированиях владельца вебхука.
+-- event_types — JSON-массив внешних имён событий, пустой массив — все события.
+CREATE TABLE webhooks (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url VARCHAR(500) NOT NULL,
+    description VARCHAR(200) NOT NULL DEFAULT '',
+    event_types JSONB NOT NULL DEFAULT '[]',
+    secret VARCHAR(100) NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Журнал доставок; повторная отправка создаёт новую запись со ссылкой на исходную
+CREATE TABLE webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id BIGINT REFERENCES outbox_events(id) ON DELETE SET NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    response_body TEXT,
+    last_error TEXT,
+    duration_ms INTEGER,
+    redelivery_of INTEGER REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhooks_user_id ON webhooks(user_id);
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, id);
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at)
+    WHERE status = 'pending';
+
+-- Платежи: стоимость бронирования блокируется при подтверждении,
+-- списывается после завершения поездки и выплачивается водителю за вычетом комиссии
+ALTER TABLE bookings ADD COLUMN payment_status VARCHAR(20) NOT NULL DEFAULT 'unpaid';
+
+CREATE TABLE payouts (
+    id SERIAL PRIMARY KEY,
+    driver_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    trip_id INTEGER NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    gross_amount INTEGER NOT NULL,
+    fee INTEGER NOT NULL,
+    amount INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'failed')),
+    provider VARCHAR(30),
+    provider_ref VARCHAR(100),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    paid_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE payments (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    provider VARCHAR(30) NOT NULL,
+    provider_ref VARCHAR(100) NOT NULL,
+    amount INTEGER NOT NULL,
+    captured_amount INTEGER NOT NULL DEFAULT 0,
+    refunded_amount INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,
+    payout_id INTEGER REFERENCES payouts(id) ON DELETE SET NULL,
+    captured_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_payments_booking_id ON payments(booking_id);
+CREATE INDEX idx_payments_payout_pending ON payments(captured_at) WHERE payout_id IS NULL;
+CREATE INDEX idx_payouts_driver_id ON payouts(driver_id);
+
+-- Политики отмены: водитель выбирает политику для поездки,
+-- каждая отмена записывается с рассчитанными удержанием и возвратом
+ALTER TABLE trips ADD COLUMN cancellation_policy VARCHAR(20) NOT NULL DEFAULT 'moderate';
+
+-- Штрафы водителя удерживаются из следующих выплат (settled_amount — сколько уже удержано)
+CREATE TABLE cancellations (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER REFERENCES bookings(id) ON DELETE CASCADE,
+    trip_id INTEGER NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    cancelled_by INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    party VARCHAR(20) NOT NULL CHECK (party IN ('passenger', 'driver')),
+    rule VARCHAR(30) NOT NULL,
+    policy VARCHAR(20),
+    hours_before_departure NUMERIC(8,2),
+    fee INTEGER NOT NULL DEFAULT 0,
+    refund INTEGER NOT NULL DEFAULT 0,
+    settled_amount INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE payouts ADD COLUMN penalty INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_cancellations_booking_id ON cancellations(booking_id);
+CREATE INDEX idx_cancellations_driver_debt ON cancellations(cancelled_by) WHERE party = 'driver' AND fee > settled_amount;
+
+-- Промокоды. Использования считаются по неотменённым бронированиям.
+-- Коды заводятся операторами напрямую в базе.
+CREATE TABLE promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(30) UNIQUE NOT NULL,
+    discount_type VARCHAR(10) NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    discount_value INTEGER NOT NULL CHECK (discount_value > 0),
+    max_discount INTEGER,
+    usage_limit INTEGER,
+    per_user_limit INTEGER NOT NULL DEFAULT 1,
+    first_ride_only BOOLEAN NOT NULL DEFAULT FALSE,
+    valid_until TIMESTAMP,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE promo_redemptions (
+    id SERIAL PRIMARY KEY,
+    promo_code_id INTEGER NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    booking_id INTEGER NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    discount INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Скидка и оплата бонусами; к оплате картой: total_price - discount_amount - wallet_amount.
+-- Эту разницу сервис компенсирует водителю при выплате (payments.subsidy)
+ALTER TABLE bookings ADD COLUMN discount_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE bookings ADD COLUMN wallet_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN subsidy INTEGER NOT NULL DEFAULT 0;
+
+-- Реферальная программа и бонусный кошелёк
+ALTER TABLE users ADD COLUMN referral_code VARCHAR(20) UNIQUE;
+ALTER TABLE users ADD COLUMN referred_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+UPDATE users SET referral_code = UPPER(SUBSTRING(MD5(id::TEXT || email) FOR 8)) WHERE referral_code IS NULL;
+
+CREATE TABLE referral_rewards (
+    referee_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    referrer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE wallet_transactions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    booking_id INTEGER REFERENCES bookings(id) ON DELETE SET NULL,
+    description VARCHAR(200),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_promo_redemptions_promo ON promo_redemptions(promo_code_id);
+CREATE INDEX idx_wallet_transactions_user ON wallet_transactions(user_id, created_at);
+CREATE INDEX idx_wallet_transactions_booking ON wallet_transactions(booking_id);
+
+-- Отзывы: окно отзывов отсчитывается от завершения поездки
+ALTER TABLE trips ADD COLUMN completed_at TIMESTAMPTZ;
+UPDATE trips SET completed_at = updated_at WHERE status = 'completed';
+
+-- Водитель оценивает каждого пассажира поездки: один отзыв на пару «автор → адресат»
+DROP INDEX idx_reviews_unique_author_trip;
+ALTER TABLE reviews DROP CONSTRAINT unique_review_per_trip;
+ALTER TABLE reviews ADD CONSTRAINT unique_review_per_trip_target UNIQUE (trip_id, author_id, target_id);
+
+-- Отзывы «вслепую»: отзыв виден и учитывается в рейтинге после встречного отзыва
+-- или закрытия окна отзывов
+ALTER TABLE reviews ADD COLUMN published_at TIMESTAMPTZ;
+UPDATE reviews SET published_at = created_at;
+CREATE INDEX idx_reviews_unpublished ON reviews(trip_id) WHERE published_at IS NULL;
+
+-- Администраторы (модерация и бэк-офис); назначаются вручную в базе
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Ответ адресата на отзыв и скрытие отзыва модератором
+ALTER TABLE reviews ADD COLUMN reply TEXT;
+ALTER TABLE reviews ADD COLUMN replied_at TIMESTAMPTZ;
+ALTER TABLE reviews ADD COLUMN hidden_at TIMESTAMPTZ;
+ALTER TABLE reviews ADD COLUMN hidden_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE reviews ADD COLUMN hidden_reason TEXT;
+
+-- Жалобы на отзывы; одна жалоба от пользователя на отзыв
+CREATE TABLE review_reports (
+    id SERIAL PRIMARY KEY,
+    review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    reporter_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason VARCHAR(30) NOT NULL,
+    comment TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'dismissed')),
+    resolved_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(review_id, reporter_id)
+);
+
+CREATE INDEX idx_review_reports_status ON review_reports(status, review_id);
+
+-- Оценки отзыва по критериям (ключ → 1..5) и готовые метки
+ALTER TABLE reviews ADD COLUMN scores JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE reviews ADD COLUMN tags JSONB NOT NULL DEFAULT '[]';
+
+-- Рейтинг: rating и reviews_count — сырое среднее и число отзывов,
+-- rating_score — сглаженный рейтинг с учётом давности для ранжирования
+ALTER TABLE users ADD COLUMN rating_score NUMERIC(4,3);
+ALTER TABLE users ADD COLUMN rating_updated_at TIMESTAMPTZ;
+UPDATE users u SET rating_score = s.score, rating_updated_at = NOW()
+FROM (
+    SELECT target_id,
+           (5 * 4.0 + SUM(w * rating)) / (5 + SUM(w)) AS score
+    FROM (
+        SELECT target_id, rating,
+               POWER(0.5, EXTRACT(EPOCH FROM NOW() - published_at) / 86400 / 365) AS w
+        FROM reviews
+        WHERE published_at IS NOT NULL AND hidden_at IS NULL
+    ) r
+    GROUP BY target_id
+) s
+WHERE u.id = s.target_id;
+CREATE INDEX idx_users_rating_score ON users(rating_score DESC NULLS LAST);
+
+-- Чёрный список: заблокированные пользователи не едут вместе с заблокировавшим
+CREATE TABLE user_blocks (
+    blocker_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked ON user_blocks(blocked_id);
+
+-- Жалобы на пользователей для модерации
+CREATE TABLE user_reports (
+    id SERIAL PRIMARY KEY,
+    reporter_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reported_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category VARCHAR(30) NOT NULL,
+    description TEXT,
+    trip_id INTEGER REFERENCES trips(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'dismissed')),
+    resolution TEXT,
+    resolved_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_reports_status ON user_reports(status, reported_id);
+
+-- Статус аккаунта: приостановка до suspended_until или бессрочная блокировка
+ALTER TABLE users ADD COLUMN account_status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (account_status IN ('active', 'suspended', 'banned'));
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN status_reason TEXT;
+
+-- Отмены администратором: полный возврат пассажирам, без штрафа водителю
+ALTER TABLE cancellations DROP CONSTRAINT cancellations_party_check;
+ALTER TABLE cancellations ADD CONSTRAINT cancellations_party_check CHECK (party IN ('passenger', 'driver', 'admin'));
+
+-- Журнал действий администраторов
+CREATE TABLE admin_actions (
+    id SERIAL PRIMARY KEY,
+    admin_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(40) NOT NULL,
+    target_type VARCHAR(20) NOT NULL,
+    target_id INTEGER NOT NULL,
+    reason TEXT,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_admin_actions_target ON admin_actions(target_type, target_id);
+CREATE INDEX idx_admin_actions_admin ON admin_actions(admin_id);
+
+-- Журнал изменений поездок, бронирований, отзывов и пользователей. Записи пишут
+-- триггеры, поэтому в журнал попадают и правки напрямую в базе. Автора и ID запроса
+-- приложение передаёт настройками транзакции audit.actor_id и audit.request_id.
+-- Журнал только дополняется: изменить или удалить запись нельзя.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    entity_type VARCHAR(20) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('insert', 'update', 'delete')),
+    actor_id INTEGER, -- без внешнего ключа: удаление пользователя не должно менять журнал
+    request_id VARCHAR(64),
+    old_values JSONB NOT NULL DEFAULT '{}',
+    new_values JSONB NOT NULL DEFAULT '{}',
+    user_ids INTEGER[] NOT NULL DEFAULT '{}', -- кого касается изменение (история пользователя)
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor_id);
+CREATE INDEX idx_audit_log_request ON audit_log(request_id);
+CREATE INDEX idx_audit_log_user_ids ON audit_log USING GIN (user_ids);
+
+-- Аргументы триггера — колонки, которые не попадают в журнал (служебные и производные)
+CREATE OR REPLACE FUNCTION audit_row_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    old_row JSONB;
+    new_row JSONB;
+    row_data JSONB;
+    old_diff JSONB := '{}';
+    new_diff JSONB := '{}';
+    related_ids INTEGER[];
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD) - TG_ARGV;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW) - TG_ARGV;
+    END IF;
+    row_data := COALESCE(new_row, old_row);
+
+    IF TG_OP = 'UPDATE' THEN
+        SELECT COALESCE(jsonb_object_agg(o.key, o.value), '{}'),
+               COALESCE(jsonb_object_agg(o.key, new_row -> o.key), '{}')
+        INTO old_diff, new_diff
+        FROM jsonb_each(old_row) o
+        WHERE o.value IS DISTINCT FROM new_row -> o.key;
+
+        -- Изменились только исключённые колонки
+        IF old_diff = '{}' THEN
+            RETURN NULL;
+        END IF;
+    ELSIF TG_OP = 'INSERT' THEN
+        new_diff := new_row;
+    ELSE
+        old_diff := old_row;
+    END IF;
+
+    related_ids := CASE TG_TABLE_NAME
+        WHEN 'users' THEN ARRAY[(row_data ->> 'id')::INTEGER]
+        WHEN 'trips' THEN ARRAY[(row_data ->> 'driver_id')::INTEGER]
+        WHEN 'bookings' THEN ARRAY[
+            (row_data ->> 'passenger_id')::INTEGER,
+            (SELECT driver_id FROM trips WHERE id = (row_data ->> 'trip_id')::INTEGER)
+        ]
+        -- Адресат не видит отзыв до публикации, поэтому история только у автора
+        WHEN 'reviews' THEN ARRAY[(row_data ->> 'author_id')::INTEGER]
+    END;
+
+    INSERT INTO audit_log (entity_type, entity_id, action, actor_id, request_id,
+                           old_values, new_values, user_ids)
+    VALUES (TG_TABLE_NAME, (row_data ->> 'id')::INTEGER, LOWER(TG_OP),
+            NULLIF(current_setting('audit.actor_id', TRUE), '')::INTEGER,
+            NULLIF(current_setting('audit.request_id', TRUE), ''),
+            old_diff, new_diff, array_remove(related_ids, NULL));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_trips AFTER INSERT OR UPDATE OR DELETE ON trips
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('updated_at');
+
+CREATE TRIGGER audit_bookings AFTER INSERT OR UPDATE OR DELETE ON bookings
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('updated_at');
+
+-- Кто скрыл отзыв, видно по автору записи; ID модератора в значения не попадает,
+-- чтобы не оказаться в истории автора отзыва
+CREATE TRIGGER audit_reviews AFTER INSERT OR UPDATE OR DELETE ON reviews
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('updated_at', 'hidden_by');
+
+-- Пароль не записывается; рейтинг пересчитывается из отзывов и сам по себе не является изменением
+CREATE TRIGGER audit_users AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('updated_at', 'password_hash',
+        'rating', 'reviews_count', 'rating_score', 'rating_updated_at');
+
+CREATE OR REPLACE FUNCTION audit_log_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- Одноразовые билеты для подключения к потоку событий (EventSource не передаёт заголовки)
+CREATE TABLE stream_tickets (
+    ticket VARCHAR(64) PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Возврат на кошелёк по бронированию не больше одного: повторная доставка события его не дублирует
+CREATE UNIQUE INDEX idx_wallet_transactions_booking_refund
+    ON wallet_transactions(booking_id) WHERE kind = 'booking_refund';
+
+-- Доступ к партнёрскому API (вебхукам) выдаёт администратор
+ALTER TABLE users ADD COLUMN is_partner BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE users SET is_partner = TRUE WHERE id IN (SELECT DISTINCT user_id FROM webhooks);
+
+-- Часть удержания за позднюю отмену, не покрытая картой и оставленная из бонусов пассажира
+ALTER TABLE payments ADD COLUMN wallet_fee INTEGER NOT NULL DEFAULT 0;
+
+-- Одна открытая жалоба от автора на пользователя: повторные ждут решения по первой
+UPDATE user_reports r SET status = 'dismissed', resolution = 'Повторная жалоба', resolved_at = NOW()
+WHERE r.status = 'open' AND EXISTS (
+    SELECT 1 FROM user_reports o
+    WHERE o.reporter_id = r.reporter_id AND o.reported_id = r.reported_id
+      AND o.status = 'open' AND o.id < r.id
+);
+CREATE UNIQUE INDEX idx_user_reports_open_pair ON user_reports(reporter_id, reported_id) WHERE status = 'open';
+
+-- Сотрудники партнёра: события их поездок и бронирований доставляются на вебхуки партнёра
+CREATE TABLE partner_members (
+    partner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (partner_id, user_id)
+);
+CREATE INDEX idx_partner_members_user_id ON partner_members(user_id);
@@ -236,12 +236,25 @@ class ApiClient {
         });
     }
 
+    // Загружает все страницы списка отзывов
+    async getAllReviews(endpoint) {
+        const limit = 100;
+        const reviews = [];
+        for (let offset = 0; ; offset += limit) {
+            const data = await this.request(`${endpoint}?limit=${limit}&offset=${offset}`);
+            reviews.push(...data.reviews);
+            if (data.reviews.length < limit || reviews.length >= data.total) {
+                return reviews;
+            }
+        }
+    }
+
     async getUserReviews(userId) {
-        return this.request(`/reviews/user/${userId}`);
+        return this.getAllReviews(`/reviews/user/${userId}`);
     }
 
     async getMyReviews() {
-        return this.request('/reviews/my-reviews');
+        return this.getAllReviews('/reviews/my-reviews');
     }
 
     async createPassengerReview(reviewData) {
@@ -251,7 +264,7 @@ class ApiClient {
         });
     }
     async getMyWrittenReviews() {
-        return this.request('/reviews/my-written-reviews');
+        return this.getAllReviews('/reviews/my-written-reviews');
     }
     async updateReview(reviewId, reviewData) {  
         return this.request(`/reviews/${reviewId}`, {